@@ -7,6 +7,8 @@ import * as acm from "@aws-cdk/aws-certificatemanager";
 import * as lambda from "@aws-cdk/aws-lambda";
 import * as api from "@aws-cdk/aws-apigateway";
 import * as es from '@aws-cdk/aws-elasticsearch';
+import * as events from "@aws-cdk/aws-events";
+import * as targets from "@aws-cdk/aws-events-targets";
 import { AnyPrincipal } from "@aws-cdk/aws-iam";
 import * as logs from '@aws-cdk/aws-logs';
 
@@ -16,15 +18,78 @@ export class WaterColorSiteStack extends cdk.Stack {
 
     const systemEnv = process.env.SYSTEM_ENV ? process.env.SYSTEM_ENV : "prod";
     const bucketName = `wcs-bucket-${systemEnv}`;
+    const exportBucketName = `wcs-export-bucket-${systemEnv}`;
     const wcs = new lambda.Function(this, `wcs-${systemEnv}`, {
       functionName: `wcs-${systemEnv}`,
       runtime: lambda.Runtime.GO_1_X,
       handler: "main",
       code: lambda.Code.fromAsset("../lambda-go/bin"),
       environment: {
-        'BUCKET_NAME': bucketName
+        'SYSTEM_ENV': systemEnv,
+        'BUCKET_NAME': bucketName,
+        'EXPORT_BUCKET_NAME': exportBucketName,
+        'FRONT_URL': process.env.FRONT_URL ? process.env.FRONT_URL : "",
+        'API_URL': process.env.API_URL ? process.env.API_URL : "",
+        'ADMIN_USER_IDS': process.env.ADMIN_USER_IDS ? process.env.ADMIN_USER_IDS : "",
+        'TWITTER_CONSUMER_KEY': process.env.TWITTER_CONSUMER_KEY ? process.env.TWITTER_CONSUMER_KEY : "",
+        'TWITTER_CONSUMER_SECRET': process.env.TWITTER_CONSUMER_SECRET ? process.env.TWITTER_CONSUMER_SECRET : "",
+        'GOOGLE_CLIENT_ID': process.env.GOOGLE_CLIENT_ID ? process.env.GOOGLE_CLIENT_ID : "",
+        'GOOGLE_CLIENT_SECRET': process.env.GOOGLE_CLIENT_SECRET ? process.env.GOOGLE_CLIENT_SECRET : "",
+        'GITHUB_CLIENT_ID': process.env.GITHUB_CLIENT_ID ? process.env.GITHUB_CLIENT_ID : "",
+        'GITHUB_CLIENT_SECRET': process.env.GITHUB_CLIENT_SECRET ? process.env.GITHUB_CLIENT_SECRET : "",
       },
     });
+    // same binary as the API, started as the background job runner.
+    const scheduler = new lambda.Function(this, `wcs-scheduler-${systemEnv}`, {
+      functionName: `wcs-scheduler-${systemEnv}`,
+      runtime: lambda.Runtime.GO_1_X,
+      handler: "main",
+      code: lambda.Code.fromAsset("../lambda-go/bin"),
+      timeout: cdk.Duration.minutes(5),
+      environment: {
+        'SYSTEM_ENV': systemEnv,
+        'BUCKET_NAME': bucketName,
+        'EXPORT_BUCKET_NAME': exportBucketName,
+        'FRONT_URL': process.env.FRONT_URL ? process.env.FRONT_URL : "",
+        'API_URL': process.env.API_URL ? process.env.API_URL : "",
+        'LAMBDA_HANDLER': "scheduler",
+      },
+    });
+    new events.Rule(this, `wcs-publish-${systemEnv}`, {
+      ruleName: `wcs-publish-${systemEnv}`,
+      schedule: events.Schedule.rate(cdk.Duration.minutes(5)),
+      targets: [new targets.LambdaFunction(scheduler, {
+        event: events.RuleTargetInput.fromObject({ job: "publish" }),
+      })],
+    });
+    new events.Rule(this, `wcs-close-challenges-${systemEnv}`, {
+      ruleName: `wcs-close-challenges-${systemEnv}`,
+      schedule: events.Schedule.rate(cdk.Duration.hours(1)),
+      targets: [new targets.LambdaFunction(scheduler, {
+        event: events.RuleTargetInput.fromObject({ job: "close-challenges" }),
+      })],
+    });
+    new events.Rule(this, `wcs-rank-paintings-${systemEnv}`, {
+      ruleName: `wcs-rank-paintings-${systemEnv}`,
+      schedule: events.Schedule.rate(cdk.Duration.hours(1)),
+      targets: [new targets.LambdaFunction(scheduler, {
+        event: events.RuleTargetInput.fromObject({ job: "rank-paintings" }),
+      })],
+    });
+    new events.Rule(this, `wcs-build-exports-${systemEnv}`, {
+      ruleName: `wcs-build-exports-${systemEnv}`,
+      schedule: events.Schedule.rate(cdk.Duration.minutes(5)),
+      targets: [new targets.LambdaFunction(scheduler, {
+        event: events.RuleTargetInput.fromObject({ job: "build-exports" }),
+      })],
+    });
+    new events.Rule(this, `wcs-delete-accounts-${systemEnv}`, {
+      ruleName: `wcs-delete-accounts-${systemEnv}`,
+      schedule: events.Schedule.rate(cdk.Duration.hours(1)),
+      targets: [new targets.LambdaFunction(scheduler, {
+        event: events.RuleTargetInput.fromObject({ job: "delete-accounts" }),
+      })],
+    });
 
 
     const table = new dynamodb.Table(this, `wcs-table-${systemEnv}`, {
@@ -34,9 +99,104 @@ export class WaterColorSiteStack extends cdk.Stack {
       readCapacity: 1,
       writeCapacity: 1,
     });
+    table.addGlobalSecondaryIndex({
+      indexName: `wcs-table-${systemEnv}-by-schedule`,
+      partitionKey: { name: "Schedule", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "PublishAt", type: dynamodb.AttributeType.NUMBER },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    table.addGlobalSecondaryIndex({
+      indexName: `wcs-table-${systemEnv}-by-id`,
+      partitionKey: { name: "PaintingId", type: dynamodb.AttributeType.STRING },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    table.addGlobalSecondaryIndex({
+      indexName: `wcs-table-${systemEnv}-by-ranking`,
+      partitionKey: { name: "Ranking", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "LastActive", type: dynamodb.AttributeType.NUMBER },
+      projectionType: dynamodb.ProjectionType.INCLUDE,
+      // what the ranking job computes the scores from.
+      nonKeyAttributes: ["Likes", "Favorits", "Comments", "Views", "PublishAt", "Created", "Draft", "Hidden", "Visibility"],
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    table.addGlobalSecondaryIndex({
+      indexName: `wcs-table-${systemEnv}-by-date`,
+      partitionKey: { name: "Date", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "Timestamp", type: dynamodb.AttributeType.STRING },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
     const sessionTable = new dynamodb.Table(this, `wcs-session-table-${systemEnv}`, {
       partitionKey: { name: "SessionId", type: dynamodb.AttributeType.STRING },
       tableName: `wcs-session-table-${systemEnv}`,
+      timeToLiveAttribute: "ExpiresAt",
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    sessionTable.addGlobalSecondaryIndex({
+      indexName: `wcs-session-table-${systemEnv}-by-user`,
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const identityTable = new dynamodb.Table(this, `wcs-identity-table-${systemEnv}`, {
+      partitionKey: { name: "IdentityKey", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-identity-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    identityTable.addGlobalSecondaryIndex({
+      indexName: `wcs-identity-table-${systemEnv}-by-user`,
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const tokenTable = new dynamodb.Table(this, `wcs-token-table-${systemEnv}`, {
+      partitionKey: { name: "TokenHash", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-token-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    tokenTable.addGlobalSecondaryIndex({
+      indexName: `wcs-token-table-${systemEnv}-by-user`,
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const auditTable = new dynamodb.Table(this, `wcs-audit-table-${systemEnv}`, {
+      partitionKey: { name: "Month", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "AuditId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-audit-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const reportTable = new dynamodb.Table(this, `wcs-report-table-${systemEnv}`, {
+      partitionKey: { name: "ItemId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-report-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const moderationTable = new dynamodb.Table(this, `wcs-moderation-table-${systemEnv}`, {
+      partitionKey: { name: "ItemId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-moderation-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    moderationTable.addGlobalSecondaryIndex({
+      indexName: `wcs-moderation-table-${systemEnv}-by-state`,
+      partitionKey: { name: "State", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "LastReported", type: dynamodb.AttributeType.NUMBER },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const relationTable = new dynamodb.Table(this, `wcs-relation-table-${systemEnv}`, {
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "RelationKey", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-relation-table-${systemEnv}`,
       readCapacity: 1,
       writeCapacity: 1,
     });
@@ -46,7 +206,113 @@ export class WaterColorSiteStack extends cdk.Stack {
       readCapacity: 1,
       writeCapacity: 1,
     });
+    // only users waiting for deletion have Deletion set, so the index stays small.
+    userTable.addGlobalSecondaryIndex({
+      indexName: `wcs-user-table-${systemEnv}-by-deletion`,
+      partitionKey: { name: "Deletion", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "DeleteAt", type: dynamodb.AttributeType.NUMBER },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const exportTable = new dynamodb.Table(this, `wcs-export-table-${systemEnv}`, {
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "ExportId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-export-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    exportTable.addGlobalSecondaryIndex({
+      indexName: `wcs-export-table-${systemEnv}-by-state`,
+      partitionKey: { name: "State", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "Created", type: dynamodb.AttributeType.NUMBER },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const followTable = new dynamodb.Table(this, `wcs-follow-table-${systemEnv}`, {
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "FolloweeId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-follow-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    followTable.addGlobalSecondaryIndex({
+      indexName: `wcs-follow-table-${systemEnv}-by-followee`,
+      partitionKey: { name: "FolloweeId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const notificationTable = new dynamodb.Table(this, `wcs-notification-table-${systemEnv}`, {
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "NotificationId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-notification-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const collectionTable = new dynamodb.Table(this, `wcs-collection-table-${systemEnv}`, {
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "CollectionId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-collection-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const challengeTable = new dynamodb.Table(this, `wcs-challenge-table-${systemEnv}`, {
+      partitionKey: { name: "ChallengeId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-challenge-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const challengeEntryTable = new dynamodb.Table(this, `wcs-challenge-entry-table-${systemEnv}`, {
+      partitionKey: { name: "ChallengeId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "EntryId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-challenge-entry-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const challengeVoteTable = new dynamodb.Table(this, `wcs-challenge-vote-table-${systemEnv}`, {
+      partitionKey: { name: "EntryKey", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-challenge-vote-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const reactionTable = new dynamodb.Table(this, `wcs-reaction-table-${systemEnv}`, {
+      partitionKey: { name: "PaintingId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "ReactionKey", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-reaction-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    reactionTable.addGlobalSecondaryIndex({
+      indexName: `wcs-reaction-table-${systemEnv}-by-user`,
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "Created", type: dynamodb.AttributeType.NUMBER },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    const commentTable = new dynamodb.Table(this, `wcs-comment-table-${systemEnv}`, {
+      partitionKey: { name: "PaintingId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "CommentId", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-comment-table-${systemEnv}`,
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
+    commentTable.addGlobalSecondaryIndex({
+      indexName: `wcs-comment-table-${systemEnv}-by-user`,
+      partitionKey: { name: "UserId", type: dynamodb.AttributeType.STRING },
+      sortKey: { name: "CommentId", type: dynamodb.AttributeType.STRING },
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
 
+    // responses to requests sent with an Idempotency-Key header, kept for a day.
+    const idempotencyTable = new dynamodb.Table(this, `wcs-idempotency-table-${systemEnv}`, {
+      partitionKey: { name: "IdempotencyKey", type: dynamodb.AttributeType.STRING },
+      tableName: `wcs-idempotency-table-${systemEnv}`,
+      timeToLiveAttribute: "ExpiresAt",
+      readCapacity: 1,
+      writeCapacity: 1,
+    });
     const northeast1certificate = acm.Certificate.fromCertificateArn(
       this,
       "wcs-certificate-northeast-1",
@@ -77,7 +343,46 @@ export class WaterColorSiteStack extends cdk.Stack {
     table.grantFullAccess(wcs);
     sessionTable.grantFullAccess(wcs);
     userTable.grantFullAccess(wcs);
+    identityTable.grantFullAccess(wcs);
+    tokenTable.grantFullAccess(wcs);
+    auditTable.grantFullAccess(wcs);
+    reportTable.grantFullAccess(wcs);
+    moderationTable.grantFullAccess(wcs);
+    relationTable.grantFullAccess(wcs);
+    followTable.grantFullAccess(wcs);
+    notificationTable.grantFullAccess(wcs);
+    collectionTable.grantFullAccess(wcs);
+    challengeTable.grantFullAccess(wcs);
+    challengeEntryTable.grantFullAccess(wcs);
+    challengeVoteTable.grantFullAccess(wcs);
+    reactionTable.grantFullAccess(wcs);
+    commentTable.grantFullAccess(wcs);
+    exportTable.grantFullAccess(wcs);
+    idempotencyTable.grantReadWriteData(wcs);
+    challengeTable.grantFullAccess(scheduler);
+    challengeEntryTable.grantReadData(scheduler);
+    challengeVoteTable.grantReadData(scheduler);
+    table.grantFullAccess(scheduler);
+    followTable.grantReadData(scheduler);
+    notificationTable.grantFullAccess(scheduler);
+    // building exports and deleting accounts touch most of the user's data.
+    userTable.grantFullAccess(scheduler);
+    sessionTable.grantFullAccess(scheduler);
+    identityTable.grantFullAccess(scheduler);
+    tokenTable.grantFullAccess(scheduler);
+    relationTable.grantFullAccess(scheduler);
+    followTable.grantWriteData(scheduler);
+    collectionTable.grantFullAccess(scheduler);
+    reactionTable.grantFullAccess(scheduler);
+    commentTable.grantFullAccess(scheduler);
+    exportTable.grantFullAccess(scheduler);
     esDomain.grantReadWrite(wcs);
+    esDomain.grantReadWrite(scheduler);
+    // prod reaches the domain through its custom endpoint, which the code already knows.
+    if (systemEnv != "prod") {
+      wcs.addEnvironment('ES_URL', `https://${esDomain.domainEndpoint}`);
+      scheduler.addEnvironment('ES_URL', `https://${esDomain.domainEndpoint}`);
+    }
 
 
     const restApiLogAccessLogGroup = new logs.LogGroup(
@@ -104,8 +409,10 @@ export class WaterColorSiteStack extends cdk.Stack {
 
     const corsOption = {
       allowOrigins: ["https://watercolor.site"], //静的なサイトのURL。ここからならOK。
-      allowHeaders: ["Content-Type"],
-      allowMethods: ["POST", "GET", "PATCH"],
+      allowHeaders: ["Content-Type", "X-CSRF-Token", "Idempotency-Key", "If-Match"],
+      // only for the preflight. The lambda answers every other request and exposes ETag itself.
+      exposeHeaders: ["ETag"],
+      allowMethods: ["POST", "GET", "PUT", "PATCH", "DELETE"],
       allowCredentials: true,
     }
 
@@ -117,13 +424,140 @@ export class WaterColorSiteStack extends cdk.Stack {
     const getUser = restapi.root.addResource("getUser");
     getUser.addMethod("GET", new api.LambdaIntegration(wcs));
 
+    const csrf = restapi.root.addResource("csrf");
+    csrf.addMethod("GET", new api.LambdaIntegration(wcs));
+
     const paintingOfUser = wcsRoot.addResource("{id}");
     paintingOfUser.addMethod("GET", new api.LambdaIntegration(wcs));
-    const aPainting = paintingOfUser.addResource("{timestamp}");
-    aPainting.addMethod("GET", new api.LambdaIntegration(wcs));
-    const paintingImage = aPainting.addResource("images");
-    paintingImage.addCorsPreflight(corsOption)
-    paintingImage.addMethod("PATCH", new api.LambdaIntegration(wcs));
+    // a painting is served both at /wcs/{id}/{timestamp} and at /paintings/{paintingId}, with the
+    // same routes under each.
+    const addPaintingRoutes = (aPainting: api.Resource) => {
+      aPainting.addCorsPreflight(corsOption)
+      aPainting.addMethod("GET", new api.LambdaIntegration(wcs));
+      aPainting.addMethod("PATCH", new api.LambdaIntegration(wcs));
+      const paintingImage = aPainting.addResource("images");
+      paintingImage.addCorsPreflight(corsOption)
+      paintingImage.addMethod("PATCH", new api.LambdaIntegration(wcs));
+      for (const name of ["like", "favorite"]) {
+        const reaction = aPainting.addResource(name);
+        reaction.addCorsPreflight(corsOption)
+        reaction.addMethod("PUT", new api.LambdaIntegration(wcs));
+        reaction.addMethod("DELETE", new api.LambdaIntegration(wcs));
+      }
+      const comments = aPainting.addResource("comments");
+      comments.addCorsPreflight(corsOption)
+      comments.addMethod("GET", new api.LambdaIntegration(wcs));
+      comments.addMethod("POST", new api.LambdaIntegration(wcs));
+      const aComment = comments.addResource("{commentId}");
+      aComment.addCorsPreflight(corsOption)
+      aComment.addMethod("DELETE", new api.LambdaIntegration(wcs));
+      const paintingPublish = aPainting.addResource("publish");
+      paintingPublish.addCorsPreflight(corsOption)
+      paintingPublish.addMethod("POST", new api.LambdaIntegration(wcs));
+      paintingPublish.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    }
+    addPaintingRoutes(paintingOfUser.addResource("{timestamp}"));
+    addPaintingRoutes(restapi.root.addResource("paintings").addResource("{paintingId}"));
+    const archive = restapi.root.addResource("archive").addResource("{yyyymm}");
+    archive.addMethod("GET", new api.LambdaIntegration(wcs));
+
+    const notifications = restapi.root.addResource("notifications");
+    notifications.addMethod("GET", new api.LambdaIntegration(wcs), {
+      requestParameters: {
+        'method.request.querystring.before': false,
+      }
+    });
+
+    const usersRoot = restapi.root.addResource("users");
+    const aUser = usersRoot.addResource("{id}");
+    aUser.addMethod("GET", new api.LambdaIntegration(wcs));
+    const profile = restapi.root.addResource("profile");
+    profile.addCorsPreflight(corsOption)
+    profile.addMethod("PATCH", new api.LambdaIntegration(wcs));
+    for (const kind of ["avatar", "header"]) {
+      const profileImage = profile.addResource(kind);
+      profileImage.addCorsPreflight(corsOption)
+      profileImage.addMethod("PUT", new api.LambdaIntegration(wcs));
+      profileImage.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    }
+    const exportRoot = restapi.root.addResource("export");
+    exportRoot.addCorsPreflight(corsOption)
+    exportRoot.addMethod("GET", new api.LambdaIntegration(wcs));
+    exportRoot.addMethod("POST", new api.LambdaIntegration(wcs));
+    const deletion = restapi.root.addResource("account").addResource("deletion");
+    deletion.addCorsPreflight(corsOption)
+    deletion.addMethod("POST", new api.LambdaIntegration(wcs));
+    deletion.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    const follow = aUser.addResource("follow");
+    follow.addCorsPreflight(corsOption)
+    follow.addMethod("PUT", new api.LambdaIntegration(wcs));
+    follow.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    const userCollections = aUser.addResource("collections");
+    userCollections.addMethod("GET", new api.LambdaIntegration(wcs));
+    const block = aUser.addResource("block");
+    block.addCorsPreflight(corsOption)
+    block.addMethod("PUT", new api.LambdaIntegration(wcs));
+    block.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    const mute = aUser.addResource("mute");
+    mute.addCorsPreflight(corsOption)
+    mute.addMethod("PUT", new api.LambdaIntegration(wcs));
+    mute.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    const blocks = restapi.root.addResource("blocks");
+    blocks.addMethod("GET", new api.LambdaIntegration(wcs));
+    const mutes = restapi.root.addResource("mutes");
+    mutes.addMethod("GET", new api.LambdaIntegration(wcs));
+
+    const challengesRoot = restapi.root.addResource("challenges");
+    challengesRoot.addCorsPreflight(corsOption)
+    challengesRoot.addMethod("GET", new api.LambdaIntegration(wcs));
+    challengesRoot.addMethod("POST", new api.LambdaIntegration(wcs));
+    const aChallenge = challengesRoot.addResource("{challengeId}");
+    aChallenge.addMethod("GET", new api.LambdaIntegration(wcs));
+    const challengeEntries = aChallenge.addResource("entries");
+    challengeEntries.addCorsPreflight(corsOption)
+    challengeEntries.addMethod("GET", new api.LambdaIntegration(wcs));
+    challengeEntries.addMethod("POST", new api.LambdaIntegration(wcs));
+    const challengeEntry = challengeEntries.addResource("{entryId}");
+    challengeEntry.addCorsPreflight(corsOption)
+    challengeEntry.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    const challengeVote = challengeEntry.addResource("vote");
+    challengeVote.addCorsPreflight(corsOption)
+    challengeVote.addMethod("PUT", new api.LambdaIntegration(wcs));
+    const leaderboard = aChallenge.addResource("leaderboard");
+    leaderboard.addMethod("GET", new api.LambdaIntegration(wcs));
+    const winners = restapi.root.addResource("winners");
+    winners.addMethod("GET", new api.LambdaIntegration(wcs));
+
+    const collectionsRoot = restapi.root.addResource("collections");
+    collectionsRoot.addCorsPreflight(corsOption)
+    collectionsRoot.addMethod("POST", new api.LambdaIntegration(wcs));
+    const aCollection = collectionsRoot.addResource("{userId}").addResource("{collectionId}");
+    aCollection.addCorsPreflight(corsOption)
+    aCollection.addMethod("GET", new api.LambdaIntegration(wcs));
+    aCollection.addMethod("PATCH", new api.LambdaIntegration(wcs));
+    aCollection.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    const collectionPaintings = aCollection.addResource("paintings");
+    collectionPaintings.addCorsPreflight(corsOption)
+    collectionPaintings.addMethod("POST", new api.LambdaIntegration(wcs));
+    const collectionPainting = collectionPaintings.addResource("{id}").addResource("{timestamp}");
+    collectionPainting.addCorsPreflight(corsOption)
+    collectionPainting.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    const collectionOrder = aCollection.addResource("order");
+    collectionOrder.addCorsPreflight(corsOption)
+    collectionOrder.addMethod("PUT", new api.LambdaIntegration(wcs));
+
+    const logout = restapi.root.addResource("logout");
+    logout.addCorsPreflight(corsOption)
+    logout.addMethod("POST", new api.LambdaIntegration(wcs));
+    const logoutAll = logout.addResource("all");
+    logoutAll.addCorsPreflight(corsOption)
+    logoutAll.addMethod("POST", new api.LambdaIntegration(wcs));
+
+    const sessions = restapi.root.addResource("sessions");
+    sessions.addMethod("GET", new api.LambdaIntegration(wcs));
+    const aSession = sessions.addResource("{handle}");
+    aSession.addCorsPreflight(corsOption)
+    aSession.addMethod("DELETE", new api.LambdaIntegration(wcs));
 
     const twitterRoot = restapi.root.addResource("twitter");
     const twitterSignin = twitterRoot.addResource("signin");
@@ -138,6 +572,79 @@ export class WaterColorSiteStack extends cdk.Stack {
       }
     });
 
+    const authRoot = restapi.root.addResource("auth");
+    authRoot.addMethod("GET", new api.LambdaIntegration(wcs));
+    const authProvider = authRoot.addResource("{provider}");
+    const authSignin = authProvider.addResource("signin");
+    authSignin.addMethod("GET", new api.LambdaIntegration(wcs));
+    const authCallback = authProvider.addResource("callback");
+    authCallback.addMethod("GET", new api.LambdaIntegration(wcs), {
+      requestParameters: {
+        'method.request.querystring.code': false,
+        'method.request.querystring.state': false,
+        'method.request.querystring.error': false,
+      }
+    });
+
+    const admin = restapi.root.addResource("admin");
+    const adminAudit = admin.addResource("audit");
+    adminAudit.addMethod("GET", new api.LambdaIntegration(wcs), {
+      requestParameters: {
+        'method.request.querystring.month': false,
+      }
+    });
+    const adminInvalidate = admin.addResource("invalidate");
+    adminInvalidate.addCorsPreflight(corsOption)
+    adminInvalidate.addMethod("POST", new api.LambdaIntegration(wcs));
+    const adminPainting = admin.addResource("paintings").addResource("{id}").addResource("{timestamp}");
+    const adminHidden = adminPainting.addResource("hidden");
+    adminHidden.addCorsPreflight(corsOption)
+    adminHidden.addMethod("PUT", new api.LambdaIntegration(wcs));
+    adminHidden.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    const adminReindex = adminPainting.addResource("reindex");
+    adminReindex.addCorsPreflight(corsOption)
+    adminReindex.addMethod("POST", new api.LambdaIntegration(wcs));
+    const adminUser = admin.addResource("users").addResource("{id}");
+    const adminSuspended = adminUser.addResource("suspended");
+    adminSuspended.addCorsPreflight(corsOption)
+    adminSuspended.addMethod("PUT", new api.LambdaIntegration(wcs));
+    adminSuspended.addMethod("DELETE", new api.LambdaIntegration(wcs));
+    const adminRole = adminUser.addResource("role");
+    adminRole.addCorsPreflight(corsOption)
+    adminRole.addMethod("PUT", new api.LambdaIntegration(wcs));
+
+    const reports = restapi.root.addResource("reports");
+    reports.addCorsPreflight(corsOption)
+    reports.addMethod("POST", new api.LambdaIntegration(wcs));
+
+    const moderation = restapi.root.addResource("moderation");
+    moderation.addMethod("GET", new api.LambdaIntegration(wcs), {
+      requestParameters: {
+        'method.request.querystring.state': false,
+      }
+    });
+    const aModerationItem = moderation.addResource("{itemId}");
+    aModerationItem.addMethod("GET", new api.LambdaIntegration(wcs));
+    for (const action of ["claim", "resolve", "restore"]) {
+      const moderationAction = aModerationItem.addResource(action);
+      moderationAction.addCorsPreflight(corsOption)
+      moderationAction.addMethod("POST", new api.LambdaIntegration(wcs));
+    }
+
+    const tokens = restapi.root.addResource("tokens");
+    tokens.addCorsPreflight(corsOption)
+    tokens.addMethod("GET", new api.LambdaIntegration(wcs));
+    tokens.addMethod("POST", new api.LambdaIntegration(wcs));
+    const aToken = tokens.addResource("{tokenId}");
+    aToken.addCorsPreflight(corsOption)
+    aToken.addMethod("DELETE", new api.LambdaIntegration(wcs));
+
+    const identities = restapi.root.addResource("identities");
+    identities.addMethod("GET", new api.LambdaIntegration(wcs));
+    const anIdentity = identities.addResource("{provider}");
+    anIdentity.addCorsPreflight(corsOption)
+    anIdentity.addMethod("DELETE", new api.LambdaIntegration(wcs));
+
     const equipmentRoot = restapi.root.addResource("equipments");
     equipmentRoot.addMethod("GET", new api.LambdaIntegration(wcs), {
       requestParameters: {
@@ -161,6 +668,17 @@ export class WaterColorSiteStack extends cdk.Stack {
     });
 
     bucket.grantPut(wcs);
+    bucket.grantReadWrite(scheduler);
+    bucket.grantDelete(scheduler);
+
+    // personal data exports. Never behind the CDN; users download them through presigned links.
+    const exportBucket = new s3.Bucket(this, `wcs-export-bucket-${systemEnv}`, {
+      bucketName: exportBucketName,
+      blockPublicAccess: s3.BlockPublicAccess.BLOCK_ALL,
+      lifecycleRules: [{ expiration: cdk.Duration.days(7) }],
+    });
+    exportBucket.grantPut(scheduler);
+    exportBucket.grantRead(wcs);
 
     const oai = new cloudfront.OriginAccessIdentity(
       this,
@@ -184,7 +702,7 @@ export class WaterColorSiteStack extends cdk.Stack {
       "wcs-certificate",
       "arn:aws:acm:us-east-1:XXXXXXXXXXXX:certificate/XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX" // us-east-1においてある *.watercolor.site の証明書。CloudFrontはリージョンまたがる存在である一方、CertificateManagerは各リージョンにいるので、便宜上us-east-1を決め打ちで参照するようだ。
     );
-    new cloudfront.CloudFrontWebDistribution(
+    const distribution = new cloudfront.CloudFrontWebDistribution(
       this,
       `wcs-distribution-${systemEnv}`,
       {
@@ -238,5 +756,16 @@ export class WaterColorSiteStack extends cdk.Stack {
         ],
       }
     );
+    // admins can drop cached images, for example after hiding a painting.
+    wcs.addEnvironment('DISTRIBUTION_ID', distribution.distributionId);
+    if (systemEnv != "prod") {
+      wcs.addEnvironment('IMAGE_URL', `https://${distribution.distributionDomainName}`);
+      scheduler.addEnvironment('IMAGE_URL', `https://${distribution.distributionDomainName}`);
+    }
+    wcs.addToRolePolicy(new iam.PolicyStatement({
+      effect: iam.Effect.ALLOW,
+      actions: ["cloudfront:CreateInvalidation"],
+      resources: [`arn:aws:cloudfront::${this.account}:distribution/${distribution.distributionId}`],
+    }));
   }
 }
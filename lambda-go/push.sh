@@ -13,4 +13,5 @@ then
     echo "sh lbuild.sh <env>"
 else
     aws lambda update-function-code --function-name wcs-$env --zip-file fileb://bin/main.zip
+    aws lambda update-function-code --function-name wcs-scheduler-$env --zip-file fileb://bin/main.zip
 fi
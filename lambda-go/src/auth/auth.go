@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sort"
+
+	"github.com/hirosato/wcs/model"
+)
+
+// Provider signs a user in with an external account.
+type Provider interface {
+	// Name is the provider's key in routes and in linked identities, such as "twitter".
+	Name() string
+	// Begin starts signing in. It keeps what it needs to verify the callback on the session, which
+	// the caller saves, and returns the URL to send the browser to.
+	Begin(s *model.Session, callbackURL string) (redirectURL string, err error)
+	// Complete verifies the callback request against the session and returns the external account.
+	// Only Provider, Subject, DisplayName and AvatarURL of the identity are set.
+	Complete(s *model.Session, r *http.Request, callbackURL string) (model.Identity, error)
+}
+
+// Registry holds the providers users can sign in with.
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	registry := &Registry{providers: map[string]Provider{}}
+	for _, provider := range providers {
+		registry.providers[provider.Name()] = provider
+	}
+	return registry
+}
+
+func (registry *Registry) Get(name string) (Provider, bool) {
+	provider, ok := registry.providers[name]
+	return provider, ok
+}
+
+// Names lists the registered providers for the login page.
+func (registry *Registry) Names() []string {
+	names := []string{}
+	for name := range registry.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func randomString() (string, error) {
+	var buf [32]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf[:]), nil
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hirosato/wcs/model"
+)
+
+// OAuth2Provider signs in with the authorization code flow and PKCE, then reads the account from
+// the provider's user info endpoint.
+type OAuth2Provider struct {
+	name         string
+	clientId     string
+	clientSecret string
+	scopes       []string
+	parseUser    func(body []byte) (model.Identity, error)
+	client       *http.Client
+
+	// OIDC providers only know their issuer up front and discover the endpoints on first use.
+	issuer      string
+	mu          sync.Mutex
+	authURL     string
+	tokenURL    string
+	userInfoURL string
+}
+
+// NewOIDCProvider creates a provider for any OpenID Connect issuer.
+func NewOIDCProvider(name string, issuer string, clientId string, clientSecret string) *OAuth2Provider {
+	return &OAuth2Provider{
+		name:         name,
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		scopes:       []string{"openid", "profile"},
+		parseUser:    parseOIDCUser,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func NewGoogleProvider(clientId string, clientSecret string) *OAuth2Provider {
+	return NewOIDCProvider("google", "https://accounts.google.com", clientId, clientSecret)
+}
+
+// NewGitHubProvider creates a provider for GitHub, which speaks plain OAuth2 rather than OIDC.
+func NewGitHubProvider(clientId string, clientSecret string) *OAuth2Provider {
+	return &OAuth2Provider{
+		name:         "github",
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		scopes:       []string{"read:user"},
+		parseUser:    parseGitHubUser,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		authURL:      "https://github.com/login/oauth/authorize",
+		tokenURL:     "https://github.com/login/oauth/access_token",
+		userInfoURL:  "https://api.github.com/user",
+	}
+}
+
+func (p *OAuth2Provider) Name() string {
+	return p.name
+}
+
+func (p *OAuth2Provider) endpoints() (authURL string, tokenURL string, userInfoURL string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.authURL == "" && p.issuer != "" {
+		var config struct {
+			AuthorizationEndpoint string `json:"authorization_endpoint"`
+			TokenEndpoint         string `json:"token_endpoint"`
+			UserinfoEndpoint      string `json:"userinfo_endpoint"`
+		}
+		if err := p.getJSON(p.issuer+"/.well-known/openid-configuration", "", &config); err != nil {
+			return "", "", "", err
+		}
+		p.authURL, p.tokenURL, p.userInfoURL = config.AuthorizationEndpoint, config.TokenEndpoint, config.UserinfoEndpoint
+	}
+	return p.authURL, p.tokenURL, p.userInfoURL, nil
+}
+
+func (p *OAuth2Provider) Begin(s *model.Session, callbackURL string) (string, error) {
+	authURL, _, _, err := p.endpoints()
+	if err != nil {
+		return "", err
+	}
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	s.OAuthProvider = p.name
+	s.OAuthState = state
+	s.OAuthVerifier = verifier
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.clientId},
+		"redirect_uri":          {callbackURL},
+		"scope":                 {strings.Join(p.scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	return authURL + "?" + q.Encode(), nil
+}
+
+func (p *OAuth2Provider) Complete(s *model.Session, r *http.Request, callbackURL string) (model.Identity, error) {
+	if s.OAuthProvider != p.name || s.OAuthState == "" || r.FormValue("state") != s.OAuthState {
+		return model.Identity{}, errors.New("unknown state")
+	}
+	if e := r.FormValue("error"); e != "" {
+		return model.Identity{}, errors.New("sign in failed: " + e)
+	}
+	_, tokenURL, userInfoURL, err := p.endpoints()
+	if err != nil {
+		return model.Identity{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {r.FormValue("code")},
+		"redirect_uri":  {callbackURL},
+		"client_id":     {p.clientId},
+		"client_secret": {p.clientSecret},
+		"code_verifier": {s.OAuthVerifier},
+	}
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return model.Identity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := p.doJSON(req, &token); err != nil {
+		return model.Identity{}, err
+	}
+	if token.AccessToken == "" {
+		return model.Identity{}, errors.New("no access token: " + token.Error)
+	}
+	var body json.RawMessage
+	if err := p.getJSON(userInfoURL, token.AccessToken, &body); err != nil {
+		return model.Identity{}, err
+	}
+	identity, err := p.parseUser(body)
+	if err != nil {
+		return model.Identity{}, err
+	}
+	identity.Provider = p.name
+	return identity, nil
+}
+
+func (p *OAuth2Provider) getJSON(url string, accessToken string, out interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return p.doJSON(req, out)
+}
+
+func (p *OAuth2Provider) doJSON(req *http.Request, out interface{}) error {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+func parseOIDCUser(body []byte) (model.Identity, error) {
+	var user struct {
+		Sub     string `json:"sub"`
+		Name    string `json:"name"`
+		Picture string `json:"picture"`
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return model.Identity{}, err
+	}
+	if user.Sub == "" {
+		return model.Identity{}, errors.New("user info has no sub")
+	}
+	return model.Identity{Subject: user.Sub, DisplayName: user.Name, AvatarURL: user.Picture}, nil
+}
+
+func parseGitHubUser(body []byte) (model.Identity, error) {
+	var user struct {
+		Id        int64  `json:"id"`
+		Login     string `json:"login"`
+		AvatarUrl string `json:"avatar_url"`
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return model.Identity{}, err
+	}
+	if user.Id == 0 {
+		return model.Identity{}, errors.New("user info has no id")
+	}
+	return model.Identity{Subject: strconv.FormatInt(user.Id, 10), DisplayName: user.Login, AvatarURL: user.AvatarUrl}, nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hirosato/wcs/model"
+)
+
+// fakeIssuer is a minimal OpenID Connect provider that accepts any user and remembers the PKCE
+// challenge of the last authorization request.
+func fakeIssuer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	var challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		challenge = r.FormValue("code_challenge")
+		q := url.Values{"code": {"the-code"}, "state": {r.FormValue("state")}}
+		http.Redirect(w, r, r.FormValue("redirect_uri")+"?"+q.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != "the-code" || r.FormValue("client_secret") != "secret" ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "the-token", "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer the-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"sub": "248289761001", "name": "Jane Doe", "picture": "https://example.com/jane.png"})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// authorize follows the provider's redirect like a browser and returns the callback request.
+func authorize(t *testing.T, redirectURL string) *http.Request {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(redirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return httptest.NewRequest("GET", res.Header.Get("Location"), nil)
+}
+
+func TestOIDCProvider(t *testing.T) {
+	const callback = "http://localhost:8080/auth/fake/callback"
+	server := fakeIssuer(t)
+
+	t.Run("signs in through discovery, PKCE and user info", func(t *testing.T) {
+		provider := NewOIDCProvider("fake", server.URL, "client", "secret")
+		var s model.Session
+		redirectURL, err := provider.Begin(&s, callback)
+		if err != nil {
+			t.Fatal(err)
+		}
+		identity, err := provider.Complete(&s, authorize(t, redirectURL), callback)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := model.Identity{Provider: "fake", Subject: "248289761001", DisplayName: "Jane Doe", AvatarURL: "https://example.com/jane.png"}
+		if identity != want {
+			t.Fatalf("got %+v, want %+v", identity, want)
+		}
+	})
+
+	t.Run("rejects a callback with another state", func(t *testing.T) {
+		provider := NewOIDCProvider("fake", server.URL, "client", "secret")
+		var s model.Session
+		if _, err := provider.Begin(&s, callback); err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest("GET", callback+"?code=the-code&state=forged", nil)
+		if _, err := provider.Complete(&s, r, callback); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("rejects a session started with another provider", func(t *testing.T) {
+		provider := NewOIDCProvider("fake", server.URL, "client", "secret")
+		var s model.Session
+		redirectURL, err := provider.Begin(&s, callback)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := authorize(t, redirectURL)
+		s.OAuthProvider = "other"
+		if _, err := provider.Complete(&s, r, callback); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("fails when the verifier does not match the challenge", func(t *testing.T) {
+		provider := NewOIDCProvider("fake", server.URL, "client", "secret")
+		var s model.Session
+		redirectURL, err := provider.Begin(&s, callback)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := authorize(t, redirectURL)
+		s.OAuthVerifier = "something else"
+		if _, err := provider.Complete(&s, r, callback); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
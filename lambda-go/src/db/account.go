@@ -0,0 +1,133 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+// ScheduleDeletion marks the user for deletion at deleteAt.
+func (s *Store) ScheduleDeletion(userId string, deleteAt uint64) error {
+	err := s.userTable.Update("UserId", userId).
+		Set("Deletion", model.DeletionScheduled).
+		Set("DeleteAt", deleteAt).
+		If("attribute_exists('UserId')").
+		Run()
+	return storeError(err)
+}
+
+func (s *Store) CancelDeletion(userId string) error {
+	return s.userTable.Update("UserId", userId).Remove("Deletion", "DeleteAt").Run()
+}
+
+// GetUsersDueForDeletion returns users whose grace period has run out.
+func (s *Store) GetUsersDueForDeletion(now uint64) ([]model.User, error) {
+	var result []model.User
+	err := s.userTable.Get("Deletion", model.DeletionScheduled).
+		Range("DeleteAt", dynamo.LessOrEqual, now).
+		Index(indexName(s.userTable, "deletion")).
+		All(&result)
+	return result, err
+}
+
+func (s *Store) DeleteUser(userId string) error {
+	return s.userTable.Delete("UserId", userId).Run()
+}
+
+func (s *Store) DeletePainting(painting *model.Painting) error {
+	return s.paintingTable.Delete("UserId", painting.UserId).Range("Timestamp", painting.Timestamp).Run()
+}
+
+// ListUserComments returns the comments userId wrote on any painting.
+func (s *Store) ListUserComments(userId string) ([]model.Comment, error) {
+	result := []model.Comment{}
+	err := s.commentTable.Get("UserId", userId).Index(indexName(s.commentTable, "user")).All(&result)
+	return result, err
+}
+
+// AnonymizeComment detaches the comment from its author and keeps it, so threads stay readable.
+func (s *Store) AnonymizeComment(comment *model.Comment) error {
+	return s.commentTable.Update("PaintingId", comment.PaintingId).Range("CommentId", comment.CommentId).
+		Remove("UserId").
+		Run()
+}
+
+// ListUserReactions returns the likes and favorites userId gave.
+func (s *Store) ListUserReactions(userId string) ([]model.Reaction, error) {
+	result := []model.Reaction{}
+	err := s.reactionTable.Get("UserId", userId).Index(indexName(s.reactionTable, "user")).All(&result)
+	return result, err
+}
+
+// DeletePaintingActivity removes every reaction and comment on the painting.
+func (s *Store) DeletePaintingActivity(paintingId string) error {
+	var reactions []model.Reaction
+	if err := s.reactionTable.Get("PaintingId", paintingId).All(&reactions); err != nil {
+		return err
+	}
+	keys := []dynamo.Keyed{}
+	for _, reaction := range reactions {
+		keys = append(keys, dynamo.Keys{reaction.PaintingId, reaction.ReactionKey})
+	}
+	if len(keys) > 0 {
+		if _, err := s.reactionTable.Batch("PaintingId", "ReactionKey").Write().Delete(keys...).Run(); err != nil {
+			return err
+		}
+	}
+	comments, err := s.ListComments(paintingId)
+	if err != nil {
+		return err
+	}
+	keys = []dynamo.Keyed{}
+	for _, comment := range comments {
+		keys = append(keys, dynamo.Keys{comment.PaintingId, comment.CommentId})
+	}
+	if len(keys) > 0 {
+		if _, err := s.commentTable.Batch("PaintingId", "CommentId").Write().Delete(keys...).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) ListFollowing(userId string) ([]model.Follow, error) {
+	var result []model.Follow
+	err := s.followTable.Get("UserId", userId).All(&result)
+	return result, err
+}
+
+// DeleteNotifications removes every notification of userId.
+func (s *Store) DeleteNotifications(userId string) error {
+	var notifications []model.Notification
+	if err := s.notificationTable.Get("UserId", userId).All(&notifications); err != nil {
+		return err
+	}
+	keys := []dynamo.Keyed{}
+	for _, notification := range notifications {
+		keys = append(keys, dynamo.Keys{notification.UserId, notification.NotificationId})
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := s.notificationTable.Batch("UserId", "NotificationId").Write().Delete(keys...).Run()
+	return err
+}
+
+func (s *Store) PutExport(export model.Export) error {
+	return s.exportTable.Put(export).Run()
+}
+
+func (s *Store) ListExports(userId string) ([]model.Export, error) {
+	result := []model.Export{}
+	err := s.exportTable.Get("UserId", userId).Order(dynamo.Descending).All(&result)
+	return result, err
+}
+
+func (s *Store) GetPendingExports() ([]model.Export, error) {
+	var result []model.Export
+	err := s.exportTable.Get("State", model.ExportPending).Index(indexName(s.exportTable, "state")).All(&result)
+	return result, err
+}
+
+func (s *Store) DeleteExport(userId string, exportId string) error {
+	return s.exportTable.Delete("UserId", userId).Range("ExportId", exportId).Run()
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) SetPaintingHidden(userId string, timestamp string, hidden bool, reason string) error {
+	u := s.paintingTable.Update("UserId", userId).Range("Timestamp", timestamp).If("attribute_exists('UserId')")
+	if hidden {
+		u = u.Set("Hidden", true).Set("HiddenReason", reason)
+	} else {
+		u = u.Set("Hidden", false).Remove("HiddenReason")
+	}
+	return storeError(u.Run())
+}
+
+func (s *Store) SetUserSuspended(userId string, suspended bool, reason string) error {
+	u := s.userTable.Update("UserId", userId).If("attribute_exists('UserId')")
+	if suspended {
+		u = u.Set("Suspended", true).Set("SuspendedReason", reason)
+	} else {
+		u = u.Set("Suspended", false).Remove("SuspendedReason")
+	}
+	return storeError(u.Run())
+}
+
+func (s *Store) SetUserRole(userId string, role model.Role) error {
+	return storeError(s.userTable.Update("UserId", userId).Set("Role", role).If("attribute_exists('UserId')").Run())
+}
+
+func (s *Store) PutAuditEntry(entry model.AuditEntry) error {
+	return s.auditTable.Put(entry).Run()
+}
+
+// ListAuditEntries returns the month's entries, newest first.
+func (s *Store) ListAuditEntries(month string) ([]model.AuditEntry, error) {
+	var result []model.AuditEntry
+	err := s.auditTable.Get("Month", month).Order(dynamo.Descending).All(&result)
+	return result, err
+}
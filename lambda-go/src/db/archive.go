@@ -0,0 +1,31 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) dateIndex() string {
+	return indexName(s.paintingTable, "date")
+}
+
+// ListDatePaintings returns up to limit paintings submitted on date older than before, newest
+// first. Like ListUserPaintings it does not filter by visibility.
+func (s *Store) ListDatePaintings(date string, before string, limit int64) ([]model.Painting, error) {
+	var result []model.Painting
+	q := s.paintingTable.Get("Date", date).Index(s.dateIndex()).Order(dynamo.Descending).Limit(limit)
+	if before != "" {
+		q = q.Range("Timestamp", dynamo.Less, before)
+	}
+	err := q.All(&result)
+	return result, err
+}
+
+// CountPublicDatePaintings counts the paintings submitted on date that anyone may see. Paintings
+// stored before Visibility existed have no such attribute and count as public.
+func (s *Store) CountPublicDatePaintings(date string) (int64, error) {
+	return s.paintingTable.Get("Date", date).Index(s.dateIndex()).
+		Filter("(attribute_not_exists('Draft') OR 'Draft' = ?) AND (attribute_not_exists('Hidden') OR 'Hidden' = ?)", false, false).
+		Filter("attribute_not_exists('Visibility') OR 'Visibility' = ?", model.VisibilityPublic).
+		Count()
+}
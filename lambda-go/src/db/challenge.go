@@ -0,0 +1,76 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) PutChallenge(challenge *model.Challenge) error {
+	return storeError(s.challengeTable.Put(challenge).If("attribute_not_exists(ChallengeId)").Run())
+}
+
+func (s *Store) GetChallenge(challengeId string) (model.Challenge, error) {
+	var result model.Challenge
+	err := s.challengeTable.Get("ChallengeId", challengeId).One(&result)
+	return result, storeError(err)
+}
+
+// ListChallenges scans the whole table. There is about one challenge a month, so it stays small.
+func (s *Store) ListChallenges() ([]model.Challenge, error) {
+	result := []model.Challenge{}
+	err := s.challengeTable.Scan().All(&result)
+	return result, err
+}
+
+// CloseChallenge freezes the results. Only the first call for a challenge has any effect.
+func (s *Store) CloseChallenge(challengeId string, results []model.ChallengeResult) error {
+	err := s.challengeTable.Update("ChallengeId", challengeId).
+		Set("Closed", true).
+		Set("Results", results).
+		If("attribute_not_exists(Closed) OR Closed = ?", false).
+		Run()
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) PutChallengeEntry(entry *model.ChallengeEntry) error {
+	return storeError(s.challengeEntryTable.Put(entry).If("attribute_not_exists(EntryId)").Run())
+}
+
+func (s *Store) GetChallengeEntry(challengeId string, entryId string) (model.ChallengeEntry, error) {
+	var result model.ChallengeEntry
+	err := s.challengeEntryTable.Get("ChallengeId", challengeId).Range("EntryId", dynamo.Equal, entryId).One(&result)
+	return result, storeError(err)
+}
+
+func (s *Store) DeleteChallengeEntry(challengeId string, entryId string) error {
+	return s.challengeEntryTable.Delete("ChallengeId", challengeId).Range("EntryId", entryId).Run()
+}
+
+func (s *Store) ListChallengeEntries(challengeId string) ([]model.ChallengeEntry, error) {
+	result := []model.ChallengeEntry{}
+	err := s.challengeEntryTable.Get("ChallengeId", challengeId).All(&result)
+	return result, err
+}
+
+// PutChallengeVote records a vote and bumps the entry's running count. It returns false when the
+// user has already voted for the entry.
+func (s *Store) PutChallengeVote(challengeId string, entryId string, vote model.ChallengeVote) (bool, error) {
+	err := s.challengeVoteTable.Put(vote).If("attribute_not_exists(UserId)").Run()
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	err = s.challengeEntryTable.Update("ChallengeId", challengeId).Range("EntryId", entryId).Add("Votes", 1).Run()
+	return true, err
+}
+
+// CountChallengeVotes counts the vote items themselves, which are authoritative over the running count.
+func (s *Store) CountChallengeVotes(challengeId string, entryId string) (uint32, error) {
+	count, err := s.challengeVoteTable.Get("EntryKey", model.ChallengeEntryKey(challengeId, entryId)).Count()
+	return uint32(count), err
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+// AddCollection stores a new collection. It returns domain.ErrConflict instead of overwriting a
+// collection with the same key.
+func (s *Store) AddCollection(collection *model.Collection) error {
+	return storeError(s.collectionTable.Put(collection).If("attribute_not_exists('UserId')").Run())
+}
+
+func (s *Store) PutCollection(collection *model.Collection) error {
+	return s.collectionTable.Put(collection).Run()
+}
+
+func (s *Store) GetCollection(userId string, collectionId string) (model.Collection, error) {
+	var result model.Collection
+	err := s.collectionTable.Get("UserId", userId).Range("CollectionId", dynamo.Equal, collectionId).One(&result)
+	return result, storeError(err)
+}
+
+func (s *Store) ListCollections(userId string) ([]model.Collection, error) {
+	result := []model.Collection{}
+	err := s.collectionTable.Get("UserId", userId).Order(dynamo.Descending).All(&result)
+	return result, err
+}
+
+func (s *Store) DeleteCollection(userId string, collectionId string) error {
+	return s.collectionTable.Delete("UserId", userId).Range("CollectionId", collectionId).Run()
+}
+
+// GetPaintings loads the referenced paintings keyed by painting id. Paintings that have been deleted
+// are simply missing from the result.
+func (s *Store) GetPaintings(refs []model.PaintingRef) (map[string]model.Painting, error) {
+	result := map[string]model.Painting{}
+	if len(refs) == 0 {
+		return result, nil
+	}
+	seen := map[model.PaintingRef]bool{}
+	keys := []dynamo.Keyed{}
+	for _, ref := range refs {
+		if !seen[ref] {
+			seen[ref] = true
+			keys = append(keys, dynamo.Keys{ref.UserId, ref.Timestamp})
+		}
+	}
+	var paintings []model.Painting
+	err := s.paintingTable.Batch("UserId", "Timestamp").Get(keys...).All(&paintings)
+	if err != nil && err != dynamo.ErrNotFound {
+		return result, err
+	}
+	for _, painting := range paintings {
+		result[painting.GetId()] = painting
+	}
+	return result, nil
+}
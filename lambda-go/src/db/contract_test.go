@@ -0,0 +1,541 @@
+package db_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hirosato/wcs/db"
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/env"
+	"github.com/hirosato/wcs/memory"
+	"github.com/hirosato/wcs/model"
+)
+
+// The contract tests run the same cases against the in-memory store and, when
+// WCS_TEST_DYNAMO_ENDPOINT names a DynamoDB Local, against the real store on throwaway tables
+// created like the CDK stack's. Likewise WCS_TEST_ES_URL runs the search cases against a local
+// Elasticsearch or OpenSearch, whose wcs index they drop first. See lambda-go/docker-compose.yml.
+
+type store interface {
+	domain.PaintingRepository
+	domain.UserRepository
+	domain.SessionStore
+	domain.FollowRepository
+	domain.ReactionRepository
+	domain.IdentityRepository
+	domain.TokenRepository
+	domain.ReportRepository
+	domain.ExportRepository
+	domain.IdempotencyStore
+	domain.CollectionRepository
+}
+
+func stores(t *testing.T) map[string]func(t *testing.T) store {
+	result := map[string]func(t *testing.T) store{
+		"memory": func(t *testing.T) store { return memory.NewStore() },
+	}
+	endpoint := os.Getenv("WCS_TEST_DYNAMO_ENDPOINT")
+	if endpoint == "" {
+		t.Log("WCS_TEST_DYNAMO_ENDPOINT is not set; skipping DynamoDB")
+		return result
+	}
+	result["dynamodb"] = func(t *testing.T) store {
+		// DynamoDB Local takes any credentials, but the SDK will not sign without some.
+		if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+			setenv(t, "AWS_ACCESS_KEY_ID", "local")
+			setenv(t, "AWS_SECRET_ACCESS_KEY", "local")
+		}
+		config := env.Config{
+			SystemEnv:      fmt.Sprintf("test-%d", time.Now().UnixNano()),
+			Region:         "ap-northeast-1",
+			DynamoEndpoint: endpoint,
+		}
+		createTables(t, config)
+		return db.NewStore(config)
+	}
+	return result
+}
+
+// setenv is t.Setenv, which Go 1.16 does not have yet.
+func setenv(t *testing.T, key string, value string) {
+	previous, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestStoreContract(t *testing.T) {
+	for name, open := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			t.Run("paintings of a day come from the date index", func(t *testing.T) {
+				s := open(t)
+				for _, painting := range []model.Painting{
+					{UserId: "a", Timestamp: "20210810150726359", Date: "20210810"},
+					{UserId: "b", Timestamp: "20210810090000000", Date: "20210810", Visibility: model.VisibilityPublic},
+					{UserId: "c", Timestamp: "20210810100000000", Date: "20210810", Draft: true},
+					{UserId: "a", Timestamp: "20210811000000000", Date: "20210811"},
+				} {
+					if err := s.AddPainting(&painting); err != nil {
+						t.Fatal(err)
+					}
+				}
+				paintings, err := s.ListDatePaintings("20210810", "", 1)
+				if err != nil || len(paintings) != 1 || paintings[0].UserId != "a" {
+					t.Fatalf("unexpected first page %+v %v", paintings, err)
+				}
+				paintings, err = s.ListDatePaintings("20210810", paintings[0].Timestamp, 10)
+				if err != nil || len(paintings) != 2 || paintings[0].UserId != "c" || paintings[1].UserId != "b" {
+					t.Fatalf("unexpected second page %+v %v", paintings, err)
+				}
+				if count, err := s.CountPublicDatePaintings("20210810"); err != nil || count != 2 {
+					t.Fatalf("expected 2 public paintings, got %d %v", count, err)
+				}
+			})
+
+			t.Run("paintings are added once and found by id", func(t *testing.T) {
+				s := open(t)
+				painting := model.Painting{PaintingId: "01ARYZ6S41TSV4RRFFQ69G5FAV", UserId: "u", Timestamp: "1", Title: "first"}
+				if err := s.AddPainting(&painting); err != nil {
+					t.Fatal(err)
+				}
+				again := model.Painting{PaintingId: "01ARYZ6S41TSV4RRFFQ69G5FAW", UserId: "u", Timestamp: "1", Title: "second"}
+				if err := s.AddPainting(&again); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a failed condition, got %v", err)
+				}
+				if found, err := s.GetPaintingById(painting.PaintingId); err != nil || found.Title != "first" {
+					t.Fatalf("unexpected painting %+v %v", found, err)
+				}
+				if _, err := s.GetPaintingById(again.PaintingId); !errors.Is(err, domain.ErrNotFound) {
+					t.Fatalf("expected not found, got %v", err)
+				}
+
+				s.AddPainting(&model.Painting{UserId: "u", Timestamp: "2"})
+				if err := s.SetPaintingId("u", "2", "01ARYZ6S41TSV4RRFFQ69G5FAX"); err != nil {
+					t.Fatal(err)
+				}
+				for _, timestamp := range []string{"2", "3"} {
+					if err := s.SetPaintingId("u", timestamp, "01ARYZ6S41TSV4RRFFQ69G5FAY"); !errors.Is(err, domain.ErrConflict) {
+						t.Fatalf("expected a failed condition for %s, got %v", timestamp, err)
+					}
+				}
+				if found, err := s.GetPaintingById("01ARYZ6S41TSV4RRFFQ69G5FAX"); err != nil || found.Timestamp != "2" {
+					t.Fatalf("unexpected painting %+v %v", found, err)
+				}
+			})
+
+			t.Run("collections are added once", func(t *testing.T) {
+				s := open(t)
+				collection := model.Collection{UserId: "u", CollectionId: "1", Title: "first", Paintings: []model.PaintingRef{}}
+				if err := s.AddCollection(&collection); err != nil {
+					t.Fatal(err)
+				}
+				again := model.Collection{UserId: "u", CollectionId: "1", Title: "second", Paintings: []model.PaintingRef{}}
+				if err := s.AddCollection(&again); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a failed condition, got %v", err)
+				}
+				if found, err := s.GetCollection("u", "1"); err != nil || found.Title != "first" {
+					t.Fatalf("unexpected collection %+v %v", found, err)
+				}
+			})
+
+			t.Run("signals put paintings on the ranking index until they settle", func(t *testing.T) {
+				s := open(t)
+				s.AddPainting(&model.Painting{UserId: "u", Timestamp: "1"})
+				s.AddPainting(&model.Painting{UserId: "u", Timestamp: "2"})
+				if err := s.IncrementPaintingCounter("u", "1", "Likes", 1); err != nil {
+					t.Fatal(err)
+				}
+				ranked, err := s.ListRankedPaintings()
+				if err != nil || len(ranked) != 1 || ranked[0].Timestamp != "1" || ranked[0].Likes != 1 || ranked[0].LastActive == 0 {
+					t.Fatalf("unexpected ranked paintings %+v %v", ranked, err)
+				}
+				settled := ranked[0]
+				settled.Ranking = ""
+				settled.TrendingAll = 1
+				stale := settled
+				stale.LastActive--
+				if err := s.PutPaintingScores(&stale); err != nil {
+					t.Fatal(err)
+				}
+				if ranked, _ := s.ListRankedPaintings(); len(ranked) != 1 {
+					t.Fatalf("a painting active since it was read left the index: %+v", ranked)
+				}
+				if err := s.PutPaintingScores(&settled); err != nil {
+					t.Fatal(err)
+				}
+				if ranked, _ := s.ListRankedPaintings(); len(ranked) != 0 {
+					t.Fatalf("expected the settled painting off the index, got %+v", ranked)
+				}
+				if painting, _ := s.GetPainting("u", "1"); painting.TrendingAll != 1 || painting.Likes != 1 {
+					t.Fatalf("unexpected painting %+v", painting)
+				}
+				if err := s.MarkPaintingRanked("u", "2", 5); err != nil {
+					t.Fatal(err)
+				}
+				if ranked, _ := s.ListRankedPaintings(); len(ranked) != 1 || ranked[0].Timestamp != "2" || ranked[0].LastActive != 5 {
+					t.Fatalf("unexpected ranked paintings %+v", ranked)
+				}
+			})
+
+			t.Run("user paintings page newest first", func(t *testing.T) {
+				s := open(t)
+				for _, timestamp := range []string{"20210101000000001", "20210101000000003", "20210101000000002"} {
+					if err := s.AddPainting(&model.Painting{UserId: "u", Timestamp: timestamp}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				page, err := s.ListUserPaintings("u", "", 2)
+				if err != nil || len(page) != 2 || page[0].Timestamp != "20210101000000003" {
+					t.Fatalf("unexpected first page %+v %v", page, err)
+				}
+				page, err = s.ListUserPaintings("u", page[1].Timestamp, 2)
+				if err != nil || len(page) != 1 || page[0].Timestamp != "20210101000000001" {
+					t.Fatalf("unexpected second page %+v %v", page, err)
+				}
+			})
+
+			t.Run("due drafts come from the schedule index and publish once", func(t *testing.T) {
+				s := open(t)
+				painting := model.Painting{UserId: "u", Timestamp: "1", Draft: true, Schedule: model.ScheduleWaiting, PublishAt: 100}
+				later := model.Painting{UserId: "u", Timestamp: "2", Draft: true, Schedule: model.ScheduleWaiting, PublishAt: 300}
+				s.AddPainting(&painting)
+				s.AddPainting(&later)
+				due, err := s.GetDuePaintings(200)
+				if err != nil || len(due) != 1 || due[0].Timestamp != "1" {
+					t.Fatalf("unexpected due paintings %+v %v", due, err)
+				}
+				for i, want := range []bool{true, false} {
+					if ok, err := s.StartPublishing(&due[0], 200); err != nil || ok != want {
+						t.Fatalf("start %d: got %v %v", i, ok, err)
+					}
+				}
+				if err := s.CancelSchedule(&model.Painting{UserId: "u", Timestamp: "1"}); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a failed condition, got %v", err)
+				}
+			})
+
+			t.Run("owner edits are conditional on the version", func(t *testing.T) {
+				s := open(t)
+				s.AddPainting(&model.Painting{UserId: "u", Timestamp: "1", Title: "old", Likes: 3})
+				first := model.Painting{UserId: "u", Timestamp: "1", Title: "first"}
+				if err := s.UpdatePainting(&first); err != nil {
+					t.Fatal(err)
+				}
+				if first.Version != 1 || first.Likes != 3 {
+					t.Fatalf("unexpected painting %+v", first)
+				}
+				second := model.Painting{UserId: "u", Timestamp: "1", Title: "second"}
+				if err := s.UpdatePainting(&second); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a failed condition, got %v", err)
+				}
+				if err := s.UpdatePainting(&model.Painting{UserId: "u", Timestamp: "2"}); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a failed condition for a missing painting, got %v", err)
+				}
+				if _, err := s.GetPainting("u", "2"); !errors.Is(err, domain.ErrNotFound) {
+					t.Fatalf("the failed update created a painting: %v", err)
+				}
+
+				first.Draft, first.Schedule, first.PublishAt = true, model.ScheduleWaiting, 100
+				if err := s.UpdatePainting(&first); err != nil || first.Version != 2 {
+					t.Fatalf("schedule: %+v %v", first, err)
+				}
+				stale := first
+				if ok, err := s.StartPublishing(&first, 200); err != nil || !ok || first.Version != 3 {
+					t.Fatalf("start: %+v %v %v", first, ok, err)
+				}
+				if err := s.UpdatePainting(&stale); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("a stale edit undid the publish: %v", err)
+				}
+				s.FinishPublishing(&first)
+				if stored, err := s.GetPainting("u", "1"); err != nil || stored.Version != 4 || stored.Draft || stored.Title != "first" {
+					t.Fatalf("unexpected painting %+v %v", stored, err)
+				}
+			})
+
+			t.Run("missing items and failed conditions", func(t *testing.T) {
+				s := open(t)
+				if _, err := s.GetPainting("u", "1"); !errors.Is(err, domain.ErrNotFound) {
+					t.Fatalf("expected not found, got %v", err)
+				}
+				if err := s.SetPaintingHidden("u", "1", true, "spam"); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a failed condition, got %v", err)
+				}
+				identity := model.Identity{Provider: "memory", Subject: "dev", UserId: "u"}
+				if err := s.LinkIdentity(identity); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.LinkIdentity(identity); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a failed condition, got %v", err)
+				}
+			})
+
+			t.Run("sessions, identities and tokens by user", func(t *testing.T) {
+				s := open(t)
+				for _, session := range []model.Session{{SessionId: "1", UserId: "u"}, {SessionId: "2", UserId: "u"}, {SessionId: "3"}} {
+					if _, err := s.PutSession(session); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if sessions, err := s.ListUserSessions("u"); err != nil || len(sessions) != 2 {
+					t.Fatalf("unexpected sessions %+v %v", sessions, err)
+				}
+				s.LinkIdentity(model.Identity{Provider: "memory", Subject: "dev", UserId: "u"})
+				if identities, err := s.ListIdentities("u"); err != nil || len(identities) != 1 {
+					t.Fatalf("unexpected identities %+v %v", identities, err)
+				}
+				s.PutApiToken(model.ApiToken{TokenHash: model.HashApiToken("secret"), TokenId: "t", UserId: "u"})
+				if tokens, err := s.ListApiTokens("u"); err != nil || len(tokens) != 1 {
+					t.Fatalf("unexpected tokens %+v %v", tokens, err)
+				}
+			})
+
+			t.Run("touching a session keeps its token and never brings it back", func(t *testing.T) {
+				s := open(t)
+				// the token was issued after the session was read for the touch.
+				s.PutSession(model.Session{SessionId: "1", UserId: "u", Created: 1, LastSeen: 1, ExpiresAt: 2, CsrfToken: "token"})
+				if err := s.TouchSession(model.Session{SessionId: "1", UserId: "u", Created: 1, LastSeen: 10, ExpiresAt: 20}); err != nil {
+					t.Fatal(err)
+				}
+				if session, err := s.GetSession("1"); err != nil || session.CsrfToken != "token" || session.LastSeen != 10 || session.ExpiresAt != 20 {
+					t.Fatalf("unexpected session %+v %v", session, err)
+				}
+				s.DeleteSession("1")
+				if err := s.TouchSession(model.Session{SessionId: "1", UserId: "u", LastSeen: 30, ExpiresAt: 40}); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a conflict, got %v", err)
+				}
+				if _, err := s.GetSession("1"); !errors.Is(err, domain.ErrNotFound) {
+					t.Fatalf("the touch brought the session back: %v", err)
+				}
+			})
+
+			t.Run("a csrf token is set once and never brings a session back", func(t *testing.T) {
+				s := open(t)
+				s.PutSession(model.Session{SessionId: "1", UserId: "u", LastSeen: 1, ExpiresAt: 2})
+				if token, err := s.SetSessionCsrfToken("1", "first"); err != nil || token != "first" {
+					t.Fatalf("unexpected token %q %v", token, err)
+				}
+				if token, err := s.SetSessionCsrfToken("1", "second"); err != nil || token != "first" {
+					t.Fatalf("the token was replaced: %q %v", token, err)
+				}
+				if session, err := s.GetSession("1"); err != nil || session.CsrfToken != "first" || session.UserId != "u" {
+					t.Fatalf("unexpected session %+v %v", session, err)
+				}
+				s.DeleteSession("1")
+				if _, err := s.SetSessionCsrfToken("1", "third"); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a conflict, got %v", err)
+				}
+				if _, err := s.GetSession("1"); !errors.Is(err, domain.ErrNotFound) {
+					t.Fatalf("the token brought the session back: %v", err)
+				}
+			})
+
+			t.Run("followers come from the followee index", func(t *testing.T) {
+				s := open(t)
+				s.PutFollow(model.Follow{UserId: "a", FolloweeId: "c"})
+				s.PutFollow(model.Follow{UserId: "b", FolloweeId: "c"})
+				s.PutFollow(model.Follow{UserId: "a", FolloweeId: "d"})
+				if followers, err := s.GetFollowers("c"); err != nil || len(followers) != 2 {
+					t.Fatalf("unexpected followers %+v %v", followers, err)
+				}
+			})
+
+			t.Run("reactions and comments by user", func(t *testing.T) {
+				s := open(t)
+				painting := model.Painting{UserId: "u", Timestamp: "1"}
+				s.AddPainting(&painting)
+				reaction := model.Reaction{PaintingId: painting.GetId(), Kind: model.ReactionLike, UserId: "v", PaintingUserId: "u", PaintingTimestamp: "1", Created: 1}
+				for i, want := range []bool{true, false} {
+					if ok, err := s.PutReaction(reaction); err != nil || ok != want {
+						t.Fatalf("put %d: got %v %v", i, ok, err)
+					}
+				}
+				if reactions, err := s.ListUserReactions("v"); err != nil || len(reactions) != 1 {
+					t.Fatalf("unexpected reactions %+v %v", reactions, err)
+				}
+				stored, _ := s.GetPainting("u", "1")
+				if stored.Likes != 1 {
+					t.Fatalf("expected 1 like, got %d", stored.Likes)
+				}
+				s.PutComment(&model.Comment{PaintingId: painting.GetId(), CommentId: "1", UserId: "v", Body: "nice"})
+				if comments, err := s.ListUserComments("v"); err != nil || len(comments) != 1 {
+					t.Fatalf("unexpected comments %+v %v", comments, err)
+				}
+			})
+
+			t.Run("idempotency keys are held until completed, released or abandoned", func(t *testing.T) {
+				s := open(t)
+				record := model.IdempotencyRecord{IdempotencyKey: "u#k", Fingerprint: "f", Created: 100, ExpiresAt: 100 + model.IdempotencyLifetime}
+				if ok, err := s.ReserveIdempotencyKey(record, 100); err != nil || !ok {
+					t.Fatalf("reserve: %v %v", ok, err)
+				}
+				if ok, err := s.ReserveIdempotencyKey(record, 101); err != nil || ok {
+					t.Fatalf("reserved a pending key: %v %v", ok, err)
+				}
+				abandoned := record
+				abandoned.Created = 100 + model.IdempotencyPendingTimeout
+				if ok, err := s.ReserveIdempotencyKey(abandoned, abandoned.Created); err != nil || !ok {
+					t.Fatalf("reserve an abandoned key: %v %v", ok, err)
+				}
+				record.Status, record.Body = 200, []byte(`{"ok":true}`)
+				if err := s.CompleteIdempotencyRecord(record); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("completed a record reserved again: %v", err)
+				}
+				abandoned.Status, abandoned.Body = 200, []byte(`{"ok":true}`)
+				if err := s.CompleteIdempotencyRecord(abandoned); err != nil {
+					t.Fatal(err)
+				}
+				if stored, err := s.GetIdempotencyRecord("u#k"); err != nil || stored.Status != 200 || string(stored.Body) != `{"ok":true}` {
+					t.Fatalf("unexpected record %+v %v", stored, err)
+				}
+				if ok, err := s.ReserveIdempotencyKey(record, abandoned.Created+model.IdempotencyPendingTimeout); err != nil || ok {
+					t.Fatalf("reserved a completed key: %v %v", ok, err)
+				}
+				if ok, err := s.ReserveIdempotencyKey(record, abandoned.ExpiresAt+model.IdempotencyLifetime); err != nil || !ok {
+					t.Fatalf("reserve an expired key: %v %v", ok, err)
+				}
+				s.ReleaseIdempotencyKey("u#k")
+				if _, err := s.GetIdempotencyRecord("u#k"); !errors.Is(err, domain.ErrNotFound) {
+					t.Fatalf("expected not found, got %v", err)
+				}
+			})
+
+			t.Run("a resolution is taken back only by its moderator", func(t *testing.T) {
+				s := open(t)
+				s.RecordReport(model.ModerationItem{ItemId: "painting#u-1", TargetKind: model.ReportPainting, TargetUserId: "u"}, 1)
+				s.ClaimModerationItem("painting#u-1", "m", 2)
+				if err := s.ReopenModerationItem("painting#u-1", "m"); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("reopened an open item: %v", err)
+				}
+				if _, err := s.ResolveModerationItem("painting#u-1", "m", model.ModerationActioned, "hide", "spam", 3); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.ReopenModerationItem("painting#u-1", "other"); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("reopened someone else's resolution: %v", err)
+				}
+				if err := s.ReopenModerationItem("painting#u-1", "m"); err != nil {
+					t.Fatal(err)
+				}
+				item, err := s.GetModerationItem("painting#u-1")
+				if err != nil || item.State != model.ModerationOpen || item.ClaimedBy != "m" || item.Resolution != "" || item.ResolvedBy != "" {
+					t.Fatalf("unexpected item %+v %v", item, err)
+				}
+			})
+
+			t.Run("queues come from their state indexes", func(t *testing.T) {
+				s := open(t)
+				item := model.ModerationItem{ItemId: "painting#u-1", TargetKind: model.ReportPainting, TargetUserId: "u"}
+				for _, now := range []uint64{1, 2} {
+					if err := s.RecordReport(item, now); err != nil {
+						t.Fatal(err)
+					}
+				}
+				items, err := s.ListModerationItems(model.ModerationOpen)
+				if err != nil || len(items) != 1 || items[0].ReportCount != 2 {
+					t.Fatalf("unexpected moderation items %+v %v", items, err)
+				}
+
+				s.PutUser(model.User{UserId: "u"})
+				s.PutUser(model.User{UserId: "v"})
+				if err := s.ScheduleDeletion("u", 100); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.ScheduleDeletion("missing", 100); !errors.Is(err, domain.ErrConflict) {
+					t.Fatalf("expected a failed condition, got %v", err)
+				}
+				if users, err := s.GetUsersDueForDeletion(200); err != nil || len(users) != 1 || users[0].UserId != "u" {
+					t.Fatalf("unexpected users %+v %v", users, err)
+				}
+
+				s.PutExport(model.Export{UserId: "u", ExportId: "1", State: model.ExportPending, Created: 1})
+				s.PutExport(model.Export{UserId: "u", ExportId: "2", State: model.ExportReady, Created: 2})
+				if exports, err := s.GetPendingExports(); err != nil || len(exports) != 1 || exports[0].ExportId != "1" {
+					t.Fatalf("unexpected exports %+v %v", exports, err)
+				}
+			})
+		})
+	}
+}
+
+func searchIndexes(t *testing.T) map[string]func(t *testing.T) domain.SearchIndex {
+	result := map[string]func(t *testing.T) domain.SearchIndex{
+		"memory": func(t *testing.T) domain.SearchIndex { return memory.NewSearchIndex() },
+	}
+	url := os.Getenv("WCS_TEST_ES_URL")
+	if url == "" {
+		t.Log("WCS_TEST_ES_URL is not set; skipping Elasticsearch")
+		return result
+	}
+	result["elasticsearch"] = func(t *testing.T) domain.SearchIndex {
+		esRequest(t, http.MethodDelete, url+"/wcs")
+		index, err := db.NewSearchIndex(env.Config{EsUrl: url})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return refreshingIndex{index, func() { esRequest(t, http.MethodPost, url+"/wcs/_refresh") }}
+	}
+	return result
+}
+
+// refreshingIndex makes writes visible to the next search, which Elasticsearch only does about
+// once a second by itself.
+type refreshingIndex struct {
+	*db.SearchIndex
+	refresh func()
+}
+
+func (index refreshingIndex) ListWaterColorSite(offset int, trending string, excludedUserIds []string) []model.Painting {
+	index.refresh()
+	return index.SearchIndex.ListWaterColorSite(offset, trending, excludedUserIds)
+}
+
+func esRequest(t *testing.T, method string, url string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+}
+
+func TestSearchIndexContract(t *testing.T) {
+	for name, open := range searchIndexes(t) {
+		t.Run(name, func(t *testing.T) {
+			index := open(t)
+			for _, painting := range []model.Painting{
+				{UserId: "a", Timestamp: "1", TrendingToday: 2},
+				{UserId: "b", Timestamp: "2"},
+				{UserId: "c", Timestamp: "3", Visibility: model.VisibilityPrivate},
+			} {
+				if err := index.PutEsPainting(&painting); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if newest := index.ListWaterColorSite(0, "", nil); len(newest) != 2 || newest[0].UserId != "b" {
+				t.Fatalf("unexpected newest listing %+v", newest)
+			}
+			if trending := index.ListWaterColorSite(0, domain.TrendingToday, nil); len(trending) != 2 || trending[0].UserId != "a" {
+				t.Fatalf("unexpected trending listing %+v", trending)
+			}
+			if excluded := index.ListWaterColorSite(0, "", []string{"b"}); len(excluded) != 1 || excluded[0].UserId != "a" {
+				t.Fatalf("excluded author listed %+v", excluded)
+			}
+			index.DeleteEsPainting(&model.Painting{UserId: "a", Timestamp: "1"})
+			if remaining := index.ListWaterColorSite(0, "", nil); len(remaining) != 1 || remaining[0].UserId != "b" {
+				t.Fatalf("deleted painting listed %+v", remaining)
+			}
+		})
+	}
+}
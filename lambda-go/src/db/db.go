@@ -8,14 +8,17 @@ import (
 	"fmt"
 	"log"
 	"reflect"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/go-gorp/gorp"
 	"github.com/guregu/dynamo"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/hirosato/wcs/domain"
 	"github.com/hirosato/wcs/env"
 	"github.com/hirosato/wcs/model"
 )
@@ -27,48 +30,82 @@ type DBConfig struct {
 	TableName  string
 }
 
-var DB *dynamo.DB
-var WaterColorSiteTable dynamo.Table
-var SessionTable dynamo.Table
-var UserTable dynamo.Table
-var sqlite *sql.DB
-var dbmap *gorp.DbMap
-
-func init() {
-	if env.IsLocal {
-		DB = dynamo.New(session.New(), &aws.Config{
-			Region:   aws.String("ap-northeast-1"),
-			Endpoint: aws.String("http://XXX.XXX.XXX.XXX:9000/"), //開発環境のDynamo
-		})
-		var err error
-		sqlite, err = sql.Open("sqlite3", "../sqlite/db.sqlite")
-		if err != nil {
-			log.Fatal(err)
-		}
-		dbmap = &gorp.DbMap{Db: sqlite, Dialect: gorp.SqliteDialect{}}
+// Store keeps paintings, users, sessions and everything else the site writes in DynamoDB. It
+// implements every repository of the domain package.
+type Store struct {
+	paintingTable       dynamo.Table
+	sessionTable        dynamo.Table
+	userTable           dynamo.Table
+	followTable         dynamo.Table
+	notificationTable   dynamo.Table
+	collectionTable     dynamo.Table
+	challengeTable      dynamo.Table
+	challengeEntryTable dynamo.Table
+	challengeVoteTable  dynamo.Table
+	reactionTable       dynamo.Table
+	commentTable        dynamo.Table
+	identityTable       dynamo.Table
+	apiTokenTable       dynamo.Table
+	auditTable          dynamo.Table
+	reportTable         dynamo.Table
+	moderationTable     dynamo.Table
+	relationTable       dynamo.Table
+	exportTable         dynamo.Table
+	idempotencyTable    dynamo.Table
+}
 
-	} else {
-		DB = dynamo.New(session.New(), &aws.Config{
-			Region: aws.String("ap-northeast-1"),
-		})
-		var err error
-		sqlite, err = sql.Open("sqlite3", "./db.sqlite")
-		if err != nil {
-			log.Fatal(err)
-		}
-		dbmap = &gorp.DbMap{Db: sqlite, Dialect: gorp.SqliteDialect{}}
+// NewStore returns the store for the tables of config.SystemEnv. Nothing is sent to DynamoDB
+// until the first read or write.
+func NewStore(config env.Config) *Store {
+	awsConfig := &aws.Config{
+		Region: aws.String(config.Region),
+	}
+	if config.DynamoEndpoint != "" {
+		awsConfig.Endpoint = aws.String(config.DynamoEndpoint)
+	}
+	db := dynamo.New(session.New(), awsConfig)
+	return &Store{
+		paintingTable:       db.Table(config.TableName("")),
+		sessionTable:        db.Table(config.TableName("session")),
+		userTable:           db.Table(config.TableName("user")),
+		followTable:         db.Table(config.TableName("follow")),
+		notificationTable:   db.Table(config.TableName("notification")),
+		collectionTable:     db.Table(config.TableName("collection")),
+		challengeTable:      db.Table(config.TableName("challenge")),
+		challengeEntryTable: db.Table(config.TableName("challenge-entry")),
+		challengeVoteTable:  db.Table(config.TableName("challenge-vote")),
+		reactionTable:       db.Table(config.TableName("reaction")),
+		commentTable:        db.Table(config.TableName("comment")),
+		identityTable:       db.Table(config.TableName("identity")),
+		apiTokenTable:       db.Table(config.TableName("token")),
+		auditTable:          db.Table(config.TableName("audit")),
+		reportTable:         db.Table(config.TableName("report")),
+		moderationTable:     db.Table(config.TableName("moderation")),
+		relationTable:       db.Table(config.TableName("relation")),
+		exportTable:         db.Table(config.TableName("export")),
+		idempotencyTable:    db.Table(config.TableName("idempotency")),
+	}
+}
+
+// PigmentCatalog looks up paints and papers in the SQLite file shipped with the lambda.
+type PigmentCatalog struct {
+	dbmap *gorp.DbMap
+}
+
+func OpenPigmentCatalog(path string) (*PigmentCatalog, error) {
+	sqlite, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
 	}
-	WaterColorSiteTable = DB.Table("wcs-table-prod")
-	SessionTable = DB.Table("wcs-session-table-prod")
-	UserTable = DB.Table("wcs-user-table-prod")
+	return &PigmentCatalog{dbmap: &gorp.DbMap{Db: sqlite, Dialect: gorp.SqliteDialect{}}}, nil
 }
 
-func GetPigment(lang model.SupportedLang, filtergroup int32, name string) (*[]model.Pigment, error) {
+func (catalog *PigmentCatalog) GetPigment(lang model.SupportedLang, filtergroup int32, name string) (*[]model.Pigment, error) {
 	var equipments []model.Pigment
 	if !lang.IsSupportedLang() {
 		return &equipments, nil
 	}
-	_, err := dbmap.Select(&equipments,
+	_, err := catalog.dbmap.Select(&equipments,
 		"select key, name from pigments_"+lang.String()+
 			" where filtergroup=? and name like ? order by case when name = ? then 1 else 2 end, key desc limit 10;", filtergroup, name+"%", name)
 	if err != nil {
@@ -78,41 +115,212 @@ func GetPigment(lang model.SupportedLang, filtergroup int32, name string) (*[]mo
 	return &equipments, nil
 }
 
-func GetPainting(userId string, timestamp string) (model.Painting, error) {
-	var result model.Painting
-	err := WaterColorSiteTable.Get("UserId", userId).Range("Timestamp", dynamo.Equal, timestamp).One(&result)
-	return result, err
+// GetPigmentsByKeys returns the catalog entries with the given keys. Unknown keys are left out.
+func (catalog *PigmentCatalog) GetPigmentsByKeys(lang model.SupportedLang, keys []int32) ([]model.Pigment, error) {
+	equipments := []model.Pigment{}
+	if len(keys) == 0 {
+		return equipments, nil
+	}
+	placeholders := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		placeholders[i] = "?"
+		args[i] = key
+	}
+	_, err := catalog.dbmap.Select(&equipments,
+		"select key, name from pigments_"+lang.String()+" where key in ("+strings.Join(placeholders, ",")+");", args...)
+	return equipments, err
 }
-func GetWaterColorSite(date string) (*[]model.Painting, error) {
-	var result []model.Painting
-	err := WaterColorSiteTable.Get("Date", date).Range("Timestamp", dynamo.Between, "", "").Index("wcs-table-prod-by-date").Limit(10).All(&result)
-	return &result, err
+
+func (s *Store) GetPainting(userId string, timestamp string) (model.Painting, error) {
+	var result model.Painting
+	err := s.paintingTable.Get("UserId", userId).Range("Timestamp", dynamo.Equal, timestamp).One(&result)
+	return result, storeError(err)
 }
 
-func GetSession(sessionId string) (model.Session, error) {
+func (s *Store) GetSession(sessionId string) (model.Session, error) {
 	var result model.Session
-	err := SessionTable.Get("SessionId", sessionId).One(&result)
-	return result, err
+	err := s.sessionTable.Get("SessionId", sessionId).One(&result)
+	return result, storeError(err)
 }
 
-func PutSession(session model.Session) (model.Session, error) {
-	err := SessionTable.Put(session).Run()
+func (s *Store) PutSession(session model.Session) (model.Session, error) {
+	err := s.sessionTable.Put(session).Run()
 	return session, err
 }
 
-func PutUser(user model.User) (model.User, error) {
-	err := UserTable.Put(user).Run()
+// TouchSession writes only the session's LastSeen and ExpiresAt, and Created when it has none, so a
+// CSRF token issued meanwhile is kept. It returns domain.ErrConflict instead of bringing back a
+// session that was deleted meanwhile.
+func (s *Store) TouchSession(session model.Session) error {
+	err := s.sessionTable.Update("SessionId", session.SessionId).
+		SetIfNotExists("Created", session.Created).
+		Set("LastSeen", session.LastSeen).
+		Set("ExpiresAt", session.ExpiresAt).
+		If("attribute_exists('SessionId')").
+		Run()
+	return storeError(err)
+}
+
+// SetSessionCsrfToken gives the session token unless it already has one, and returns the one it
+// ends up with. It returns domain.ErrConflict instead of bringing back a deleted session.
+func (s *Store) SetSessionCsrfToken(sessionId string, token string) (string, error) {
+	var stored model.Session
+	err := s.sessionTable.Update("SessionId", sessionId).
+		SetIfNotExists("CsrfToken", token).
+		If("attribute_exists('SessionId')").
+		Value(&stored)
+	return stored.CsrfToken, storeError(err)
+}
+
+func (s *Store) DeleteSession(sessionId string) error {
+	return s.sessionTable.Delete("SessionId", sessionId).Run()
+}
+
+// ListUserSessions returns every session logged in as the user. Sessions that have not logged in
+// yet have no UserId and are not in the index.
+func (s *Store) ListUserSessions(userId string) ([]model.Session, error) {
+	var result []model.Session
+	err := s.sessionTable.Get("UserId", userId).Index(indexName(s.sessionTable, "user")).All(&result)
+	return result, err
+}
+
+func (s *Store) PutUser(user model.User) (model.User, error) {
+	err := s.userTable.Put(user).Run()
 	return user, err
 }
 
-func GetUser(userId string) (model.User, error) {
+// RefreshUserFromProvider writes the name and avatar a login brings in, leaving every other field
+// alone so edits made in between are kept.
+func (s *Store) RefreshUserFromProvider(user model.User) error {
+	err := s.userTable.Update("UserId", user.UserId).
+		Set("DisplayName", user.DisplayName).
+		Set("AvatarURL", user.AvatarURL).
+		Set("ProviderAvatarURL", user.ProviderAvatarURL).
+		If("attribute_exists('UserId')").
+		Run()
+	return storeError(err)
+}
+
+// SetUserImages writes the user's uploaded avatar or header. Removing the avatar puts the
+// provider's back.
+func (s *Store) SetUserImages(user model.User, kind model.ProfileImageKind) error {
+	u := s.userTable.Update("UserId", user.UserId).If("attribute_exists('UserId')")
+	if kind == model.ProfileAvatar {
+		u = u.Set("Avatars", user.Avatars).
+			Set("AvatarURL", user.AvatarURL).
+			Set("AvatarURLEdited", user.AvatarURLEdited).
+			Set("ProviderAvatarURL", user.ProviderAvatarURL)
+	} else {
+		u = u.Set("Headers", user.Headers)
+	}
+	return storeError(u.Run())
+}
+
+// UpdateUserProfile writes the fields users edit themselves.
+func (s *Store) UpdateUserProfile(user model.User) error {
+	err := s.userTable.Update("UserId", user.UserId).
+		Set("DisplayName", user.DisplayName).
+		Set("DisplayNameEdited", user.DisplayNameEdited).
+		Set("Bio", user.Bio).
+		Set("Website", user.Website).
+		Set("Location", user.Location).
+		Set("Language", user.Language).
+		Set("FavoriteEquipments", user.FavoriteEquipments).
+		If("attribute_exists('UserId')").
+		Run()
+	return storeError(err)
+}
+
+func (s *Store) GetUser(userId string) (model.User, error) {
 	var result model.User
-	err := UserTable.Get("UserId", userId).One(&result)
+	err := s.userTable.Get("UserId", userId).One(&result)
+	return result, storeError(err)
+}
+
+// GetPaintingById looks the painting up by its PaintingId in the by-id index.
+func (s *Store) GetPaintingById(paintingId string) (model.Painting, error) {
+	var result model.Painting
+	err := s.paintingTable.Get("PaintingId", paintingId).Index(indexName(s.paintingTable, "id")).One(&result)
+	return result, storeError(err)
+}
+
+// AddPainting stores a new painting. It returns domain.ErrConflict instead of overwriting a
+// painting with the same key.
+func (s *Store) AddPainting(painting *model.Painting) error {
+	return storeError(s.paintingTable.Put(painting).If("attribute_not_exists('UserId')").Run())
+}
+
+// SetPaintingId gives an existing painting its PaintingId. It returns domain.ErrConflict when the
+// painting is gone or already has one.
+func (s *Store) SetPaintingId(userId string, timestamp string, paintingId string) error {
+	err := s.paintingTable.Update("UserId", userId).Range("Timestamp", timestamp).
+		Set("PaintingId", paintingId).
+		If("attribute_exists('UserId') AND attribute_not_exists('PaintingId')").
+		Run()
+	return storeError(err)
+}
+
+// UpdatePainting writes the fields the owner edits and publishes, bumps the version and updates
+// painting in place. It returns domain.ErrConflict when the stored painting is no longer at
+// painting.Version.
+// Counters and scores are left alone, so likes and views that came in meanwhile are kept.
+func (s *Store) UpdatePainting(painting *model.Painting) error {
+	u := s.paintingTable.Update("UserId", painting.UserId).Range("Timestamp", painting.Timestamp).
+		Set("Title", painting.Title).
+		Set("Description", painting.Description).
+		Set("Visibility", painting.Visibility).
+		Set("Draft", painting.Draft).
+		Set("PublishAt", painting.PublishAt).
+		Set("Schedule", painting.Schedule).
+		Set("Updated", painting.Updated).
+		Set("Version", painting.Version+1)
+	return storeError(ifVersion(u, painting.Version).Value(painting))
+}
+
+// ifVersion makes u conditional on the painting being at version. Paintings without a Version are
+// at 0.
+func ifVersion(u *dynamo.Update, version uint64) *dynamo.Update {
+	if version == 0 {
+		return u.If("attribute_exists('UserId') AND (attribute_not_exists('Version') OR 'Version' = ?)", version)
+	}
+	return u.If("'Version' = ?", version)
+}
+
+// ListUserPaintings returns up to limit paintings of userId older than before, newest first.
+// Visibility is not filtered here, so callers have to check each item for the viewer.
+func (s *Store) ListUserPaintings(userId string, before string, limit int64) ([]model.Painting, error) {
+	var result []model.Painting
+	q := s.paintingTable.Get("UserId", userId).Order(dynamo.Descending).Limit(limit)
+	if before != "" {
+		q = q.Range("Timestamp", dynamo.Less, before)
+	}
+	err := q.All(&result)
 	return result, err
 }
 
-func PutPainting(painting *model.Painting) error {
-	err := WaterColorSiteTable.Put(painting).Run()
+// indexName returns the name of the table's global secondary index by key, such as "user".
+func indexName(table dynamo.Table, key string) string {
+	return table.Name() + "-by-" + key
+}
+
+// isConditionalCheckFailed reports whether a conditional write was rejected because its condition did not hold.
+func isConditionalCheckFailed(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+	}
+	return false
+}
+
+// storeError turns dynamo's not-found and failed-condition errors into domain.ErrNotFound and
+// domain.ErrConflict, and passes any other error through.
+func storeError(err error) error {
+	switch {
+	case err == dynamo.ErrNotFound:
+		return domain.ErrNotFound
+	case isConditionalCheckFailed(err):
+		return domain.ErrConflict
+	}
 	return err
 }
 
@@ -395,3 +603,22 @@ func (dbc DBConfig) FindByGsi(value string, indexName string, indexPk string, da
 	}
 	return err
 }
+
+var (
+	_ domain.PaintingRepository     = (*Store)(nil)
+	_ domain.UserRepository         = (*Store)(nil)
+	_ domain.SessionStore           = (*Store)(nil)
+	_ domain.FollowRepository       = (*Store)(nil)
+	_ domain.NotificationRepository = (*Store)(nil)
+	_ domain.ReactionRepository     = (*Store)(nil)
+	_ domain.CollectionRepository   = (*Store)(nil)
+	_ domain.ChallengeRepository    = (*Store)(nil)
+	_ domain.IdentityRepository     = (*Store)(nil)
+	_ domain.TokenRepository        = (*Store)(nil)
+	_ domain.AuditLog               = (*Store)(nil)
+	_ domain.ReportRepository       = (*Store)(nil)
+	_ domain.RelationRepository     = (*Store)(nil)
+	_ domain.ExportRepository       = (*Store)(nil)
+	_ domain.SearchIndex            = (*SearchIndex)(nil)
+	_ domain.PigmentCatalog         = (*PigmentCatalog)(nil)
+)
@@ -10,24 +10,31 @@ import (
 
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/hirosato/wcs/domain"
 	"github.com/hirosato/wcs/env"
 	"github.com/hirosato/wcs/model"
 )
 
-var es *elasticsearch.Client
+// SearchIndex is the Elasticsearch index the public listings are served from.
+type SearchIndex struct {
+	client *elasticsearch.Client
+}
 
-func init() {
-	config := elasticsearch.Config{
-		Addresses: []string{env.GetEsUrl()},
+func NewSearchIndex(config env.Config) (*SearchIndex, error) {
+	esConfig := elasticsearch.Config{
+		Addresses: []string{config.EsUrl},
 	}
-	// if !env.IsLocal {
-	config.Transport = NewAmazonESTransport()
-	// }
-
-	es, _ = elasticsearch.NewClient(config)
+	if config.SignEsRequests {
+		esConfig.Transport = NewAmazonESTransport(config.Region)
+	}
+	client, err := elasticsearch.NewClient(esConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &SearchIndex{client: client}, nil
 }
 
-func PutEsPainting(painting *model.Painting) error {
+func (index *SearchIndex) PutEsPainting(painting *model.Painting) error {
 	log.Printf("EVENT: put ES start")
 	paintingByte, err := json.Marshal(painting)
 	if err != nil {
@@ -40,7 +47,7 @@ func PutEsPainting(painting *model.Painting) error {
 		DocumentID: painting.GetId(),
 		Pretty:     true,
 	}
-	res, err := req.Do(context.Background(), es)
+	res, err := req.Do(context.Background(), index.client)
 	log.Printf("EVENT: put ES req.Do done")
 	if err != nil {
 		log.Fatal(err)
@@ -60,6 +67,96 @@ func PutEsPainting(painting *model.Painting) error {
 	return nil
 }
 
+// DeleteEsPainting removes the painting from the public index. A missing document is not an error,
+// because most paintings that are not public were never indexed.
+func (index *SearchIndex) DeleteEsPainting(painting *model.Painting) error {
+	req := esapi.DeleteRequest{
+		Index:      "wcs",
+		DocumentID: painting.GetId(),
+	}
+	res, err := req.Do(context.Background(), index.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return errors.New("something went wrong:" + res.Status())
+	}
+	return nil
+}
+
+// UpdateEsScores writes the trending scores into the painting's document. Paintings that are not
+// indexed are left alone.
+func (index *SearchIndex) UpdateEsScores(painting *model.Painting) error {
+	var buf bytes.Buffer
+	doc := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"trending_today": painting.TrendingToday,
+			"trending_week":  painting.TrendingWeek,
+			"trending_all":   painting.TrendingAll,
+		},
+	}
+	if err := json.NewEncoder(&buf).Encode(doc); err != nil {
+		return err
+	}
+	req := esapi.UpdateRequest{
+		Index:      "wcs",
+		DocumentID: painting.GetId(),
+		Body:       &buf,
+	}
+	res, err := req.Do(context.Background(), index.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() && res.StatusCode != 404 {
+		return errors.New("something went wrong:" + res.Status())
+	}
+	return nil
+}
+
+var trendingFields = map[string]string{
+	domain.TrendingToday: "trending_today",
+	domain.TrendingWeek:  "trending_week",
+	domain.TrendingAll:   "trending_all",
+}
+
+// sortOrder puts the highest score of the trending mode first, and newest first within a score.
+// Documents indexed before the ranking job last ran have no score yet and sort last.
+func sortOrder(trending string) []interface{} {
+	newest := map[string]interface{}{
+		"timestamp.keyword": map[string]interface{}{"order": "desc"},
+	}
+	field, ok := trendingFields[trending]
+	if !ok {
+		return []interface{}{newest}
+	}
+	return []interface{}{
+		map[string]interface{}{
+			field: map[string]interface{}{"order": "desc", "missing": "_last", "unmapped_type": "float"},
+		},
+		newest,
+	}
+}
+
+// visibleFilter keeps documents that are not public out of search results. Only public paintings
+// are indexed, so this guards against stale documents left behind by a failed delete.
+func visibleFilter() []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"term": map[string]interface{}{"draft": true},
+		},
+		map[string]interface{}{
+			"term": map[string]interface{}{"hidden": true},
+		},
+		map[string]interface{}{
+			"terms": map[string]interface{}{
+				"visibility.keyword": []model.Visibility{model.VisibilityUnlisted, model.VisibilityFollowers, model.VisibilityPrivate},
+			},
+		},
+	}
+}
+
 type searchResult struct {
 	Hits resultHits `json:"hits"`
 }
@@ -71,25 +168,42 @@ type resultHits struct {
 	} `json:"hits"`
 }
 
-func ListWaterColorSite(offset int) []model.Painting {
+// excludeAuthors leaves out the paintings of the given users inside the query, so a page is still
+// full after the filter. Every listing of the viewer's should add it to must_not.
+func excludeAuthors(mustNot []interface{}, userIds []string) []interface{} {
+	if len(userIds) == 0 {
+		return mustNot
+	}
+	return append(mustNot, map[string]interface{}{
+		"terms": map[string]interface{}{"user_id.keyword": userIds},
+	})
+}
+
+// ListWaterColorSite lists a page of public paintings, without the ones by excludedUserIds.
+func (index *SearchIndex) ListWaterColorSite(offset int, trending string, excludedUserIds []string) []model.Painting {
 	var buf bytes.Buffer
 	query := map[string]interface{}{
 		"query": map[string]interface{}{
-			"match_all": map[string]interface{}{},
+			"bool": map[string]interface{}{
+				"must": map[string]interface{}{
+					"match_all": map[string]interface{}{},
+				},
+				"must_not": excludeAuthors(visibleFilter(), excludedUserIds),
+			},
 		},
+		"sort": sortOrder(trending),
 	}
 	if err := json.NewEncoder(&buf).Encode(query); err != nil {
 		log.Fatalf("Error encoding query: %s", err)
 	}
 	log.Printf("EVENT: access to ES start")
-	res, err := es.Search(
-		es.Search.WithContext(context.TODO()),
-		es.Search.WithIndex("wcs"),
-		es.Search.WithBody(&buf),
-		es.Search.WithPretty(),
-		es.Search.WithFrom(offset),
-		es.Search.WithSize(10),
-		es.Search.WithSort("timestamp.keyword:desc"),
+	res, err := index.client.Search(
+		index.client.Search.WithContext(context.TODO()),
+		index.client.Search.WithIndex("wcs"),
+		index.client.Search.WithBody(&buf),
+		index.client.Search.WithPretty(),
+		index.client.Search.WithFrom(offset),
+		index.client.Search.WithSize(10),
 	)
 	log.Printf("EVENT: access to ES end")
 	if err != nil {
@@ -13,25 +13,22 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	signer "github.com/aws/aws-sdk-go/aws/signer/v4"
-	"github.com/hirosato/wcs/env"
 )
 
 type amazonESTransport struct {
 	awsSigner *signer.Signer
+	region    string
 }
 
-func NewAmazonESTransport() *amazonESTransport {
+func NewAmazonESTransport(region string) *amazonESTransport {
 	t := new(amazonESTransport)
+	t.region = region
 	// 環境変数から認証情報を取得し、AWS署名バージョン4の署名者を作成
 	t.awsSigner = signer.NewSigner(credentials.NewEnvCredentials())
 	return t
 }
 
 func (t *amazonESTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if env.IsLocal {
-		return http.DefaultTransport.RoundTrip(req)
-	}
-
 	const service = "es"
 
 	if h, ok := req.Header["Authorization"]; ok && len(h) > 0 && strings.HasPrefix(h[0], "AWS4") {
@@ -49,14 +46,14 @@ func (t *amazonESTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	switch req.Body {
 	case nil:
 		log.Println("Bodyなしの署名を行います.")
-		_, err = t.awsSigner.Sign(req, nil, service, env.Region, now)
+		_, err = t.awsSigner.Sign(req, nil, service, t.region, now)
 	default:
 		var b []byte
 		b, err = ioutil.ReadAll(req.Body)
 		if err == nil {
 			req.Body = ioutil.NopCloser(bytes.NewReader(b))
 			log.Println("Body付きの署名を行います.")
-			_, err = t.awsSigner.Sign(req, bytes.NewReader(b), service, env.Region, now)
+			_, err = t.awsSigner.Sign(req, bytes.NewReader(b), service, t.region, now)
 		}
 	}
 	if err != nil {
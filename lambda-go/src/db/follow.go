@@ -0,0 +1,29 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) PutFollow(follow model.Follow) error {
+	return s.followTable.Put(follow).Run()
+}
+
+func (s *Store) DeleteFollow(userId string, followeeId string) error {
+	return s.followTable.Delete("UserId", userId).Range("FolloweeId", followeeId).Run()
+}
+
+func (s *Store) IsFollowing(userId string, followeeId string) (bool, error) {
+	var follow model.Follow
+	err := s.followTable.Get("UserId", userId).Range("FolloweeId", dynamo.Equal, followeeId).One(&follow)
+	if err == dynamo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) GetFollowers(followeeId string) ([]model.Follow, error) {
+	var result []model.Follow
+	err := s.followTable.Get("FolloweeId", followeeId).Index(indexName(s.followTable, "followee")).All(&result)
+	return result, err
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"github.com/hirosato/wcs/model"
+)
+
+// ReserveIdempotencyKey stores the record of a request that is about to run. It returns false when
+// the key is taken, unless the record holding it has expired or its request was abandoned.
+func (s *Store) ReserveIdempotencyKey(record model.IdempotencyRecord, now int64) (bool, error) {
+	err := s.idempotencyTable.Put(record).
+		If("attribute_not_exists('IdempotencyKey') OR 'ExpiresAt' <= ? OR ('Status' = ? AND 'Created' <= ?)", now, 0, now-model.IdempotencyPendingTimeout).
+		Run()
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) GetIdempotencyRecord(key string) (model.IdempotencyRecord, error) {
+	var result model.IdempotencyRecord
+	err := s.idempotencyTable.Get("IdempotencyKey", key).One(&result)
+	return result, storeError(err)
+}
+
+// CompleteIdempotencyRecord stores the response of the reserved record's request.
+func (s *Store) CompleteIdempotencyRecord(record model.IdempotencyRecord) error {
+	err := s.idempotencyTable.Update("IdempotencyKey", record.IdempotencyKey).
+		Set("Status", record.Status).
+		Set("Body", record.Body).
+		If("'Fingerprint' = ? AND 'Created' = ?", record.Fingerprint, record.Created).
+		Run()
+	return storeError(err)
+}
+
+// ReleaseIdempotencyKey forgets the key, so the request can be retried with it.
+func (s *Store) ReleaseIdempotencyKey(key string) error {
+	return s.idempotencyTable.Delete("IdempotencyKey", key).Run()
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+// GetIdentity returns the identity linked to the external account, or ok=false when none is.
+func (s *Store) GetIdentity(provider string, subject string) (identity model.Identity, ok bool, err error) {
+	err = s.identityTable.Get("IdentityKey", model.IdentityKey(provider, subject)).One(&identity)
+	if err == dynamo.ErrNotFound {
+		return identity, false, nil
+	}
+	return identity, err == nil, err
+}
+
+// LinkIdentity stores a new link. It fails with domain.ErrConflict when the external
+// account is already linked, so two sign-ins racing cannot link it to two users.
+func (s *Store) LinkIdentity(identity model.Identity) error {
+	identity.IdentityKey = model.IdentityKey(identity.Provider, identity.Subject)
+	return storeError(s.identityTable.Put(identity).If("attribute_not_exists('IdentityKey')").Run())
+}
+
+func (s *Store) PutIdentity(identity model.Identity) error {
+	identity.IdentityKey = model.IdentityKey(identity.Provider, identity.Subject)
+	return s.identityTable.Put(identity).Run()
+}
+
+func (s *Store) ListIdentities(userId string) ([]model.Identity, error) {
+	var result []model.Identity
+	err := s.identityTable.Get("UserId", userId).Index(indexName(s.identityTable, "user")).All(&result)
+	return result, err
+}
+
+func (s *Store) DeleteIdentity(provider string, subject string) error {
+	return s.identityTable.Delete("IdentityKey", model.IdentityKey(provider, subject)).Run()
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+// NotifyFollowers tells the painter's followers about a published painting. Notification ids are
+// derived from the painting, so calling it again overwrites the same items instead of duplicating them.
+func (s *Store) NotifyFollowers(painting *model.Painting) error {
+	followers, err := s.GetFollowers(painting.UserId)
+	if err != nil {
+		return err
+	}
+	if len(followers) == 0 {
+		return nil
+	}
+	notifications := make([]interface{}, len(followers))
+	for i, follower := range followers {
+		notifications[i] = model.Notification{
+			UserId:            follower.UserId,
+			NotificationId:    fmt.Sprintf("%013d-%s-%s", painting.PublishAt, model.NotificationPublished, painting.GetId()),
+			Kind:              model.NotificationPublished,
+			ActorId:           painting.UserId,
+			PaintingUserId:    painting.UserId,
+			PaintingTimestamp: painting.Timestamp,
+			Created:           painting.PublishAt,
+		}
+	}
+	_, err = s.notificationTable.Batch("UserId", "NotificationId").Write().Put(notifications...).Run()
+	return err
+}
+
+// ListNotifications returns up to limit notifications of userId older than before, newest first.
+func (s *Store) ListNotifications(userId string, before string, limit int64) ([]model.Notification, error) {
+	var result []model.Notification
+	q := s.notificationTable.Get("UserId", userId).Order(dynamo.Descending).Limit(limit)
+	if before != "" {
+		q = q.Range("NotificationId", dynamo.Less, before)
+	}
+	err := q.All(&result)
+	return result, err
+}
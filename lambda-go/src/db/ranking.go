@@ -0,0 +1,58 @@
+package db
+
+import (
+	"github.com/hirosato/wcs/model"
+)
+
+// only paintings with recent reactions or views carry the Ranking attribute, so this index stays
+// small. It projects what the ranking job reads and nothing more.
+func (s *Store) rankingIndex() string {
+	return indexName(s.paintingTable, "ranking")
+}
+
+// ScanPaintings reads every painting. Only the one-off backfill jobs do this.
+func (s *Store) ScanPaintings() ([]model.Painting, error) {
+	var result []model.Painting
+	err := s.paintingTable.Scan().All(&result)
+	return result, err
+}
+
+// MarkPaintingRanked puts the painting on the ranking index, as of now.
+func (s *Store) MarkPaintingRanked(userId string, timestamp string, now uint64) error {
+	err := s.paintingTable.Update("UserId", userId).Range("Timestamp", timestamp).
+		Set("Ranking", model.RankingActive).
+		Set("LastActive", now).
+		If("attribute_exists(UserId)").
+		Run()
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// ListRankedPaintings returns the paintings on the ranking index, with only the fields the scores
+// are computed from.
+func (s *Store) ListRankedPaintings() ([]model.Painting, error) {
+	var result []model.Painting
+	err := s.paintingTable.Get("Ranking", model.RankingActive).Index(s.rankingIndex()).All(&result)
+	return result, err
+}
+
+// PutPaintingScores stores the painting's trending scores. When the ranking job cleared
+// painting.Ranking the painting also leaves the ranking index, unless a reaction or view came in
+// since it was read.
+func (s *Store) PutPaintingScores(painting *model.Painting) error {
+	u := s.paintingTable.Update("UserId", painting.UserId).Range("Timestamp", painting.Timestamp).
+		Set("TrendingToday", painting.TrendingToday).
+		Set("TrendingWeek", painting.TrendingWeek).
+		Set("TrendingAll", painting.TrendingAll).
+		If("attribute_exists(UserId)")
+	if painting.Ranking == "" {
+		u = u.Remove("Ranking").If("'LastActive' = ?", painting.LastActive)
+	}
+	err := u.Run()
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+// counter attribute of the painting item for each reaction kind.
+var reactionCounters = map[model.ReactionKind]string{
+	model.ReactionLike:     "Likes",
+	model.ReactionFavorite: "Favorits",
+}
+
+// PutReaction records a like or favorite and bumps the painting's counter. It returns false when
+// the user had already reacted the same way.
+func (s *Store) PutReaction(reaction model.Reaction) (bool, error) {
+	reaction.ReactionKey = model.ReactionKey(reaction.Kind, reaction.UserId)
+	err := s.reactionTable.Put(reaction).If("attribute_not_exists(ReactionKey)").Run()
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, s.IncrementPaintingCounter(reaction.PaintingUserId, reaction.PaintingTimestamp, reactionCounters[reaction.Kind], 1)
+}
+
+// DeleteReaction removes a like or favorite. It returns false when there was nothing to remove.
+func (s *Store) DeleteReaction(painting *model.Painting, kind model.ReactionKind, userId string) (bool, error) {
+	var old model.Reaction
+	err := s.reactionTable.Delete("PaintingId", painting.GetId()).Range("ReactionKey", model.ReactionKey(kind, userId)).OldValue(&old)
+	if err == dynamo.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, s.IncrementPaintingCounter(painting.UserId, painting.Timestamp, reactionCounters[kind], -1)
+}
+
+// IncrementPaintingCounter adds delta to one of the painting's counters and puts the painting on
+// the ranking index. Counters never go below zero.
+func (s *Store) IncrementPaintingCounter(userId string, timestamp string, counter string, delta int) error {
+	update := s.paintingTable.Update("UserId", userId).Range("Timestamp", timestamp).
+		Add(counter, delta).
+		Set("Ranking", model.RankingActive).
+		Set("LastActive", util.GetUnixMilli()).
+		If("attribute_exists(UserId)")
+	if delta < 0 {
+		update = update.If("$ >= ?", counter, -delta)
+	}
+	err := update.Run()
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) PutComment(comment *model.Comment) error {
+	if err := s.commentTable.Put(comment).Run(); err != nil {
+		return err
+	}
+	return s.IncrementPaintingCounter(comment.PaintingUserId, comment.PaintingTimestamp, "Comments", 1)
+}
+
+func (s *Store) GetComment(paintingId string, commentId string) (model.Comment, error) {
+	var result model.Comment
+	err := s.commentTable.Get("PaintingId", paintingId).Range("CommentId", dynamo.Equal, commentId).One(&result)
+	return result, storeError(err)
+}
+
+func (s *Store) ListComments(paintingId string) ([]model.Comment, error) {
+	result := []model.Comment{}
+	err := s.commentTable.Get("PaintingId", paintingId).All(&result)
+	return result, err
+}
+
+func (s *Store) DeleteComment(comment *model.Comment) error {
+	if err := s.commentTable.Delete("PaintingId", comment.PaintingId).Range("CommentId", comment.CommentId).Run(); err != nil {
+		return err
+	}
+	return s.IncrementPaintingCounter(comment.PaintingUserId, comment.PaintingTimestamp, "Comments", -1)
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) PutRelation(relation model.Relation) error {
+	relation.RelationKey = model.RelationKey(relation.Kind, relation.TargetId)
+	return s.relationTable.Put(relation).Run()
+}
+
+func (s *Store) DeleteRelation(userId string, kind model.RelationKind, targetId string) error {
+	return s.relationTable.Delete("UserId", userId).Range("RelationKey", model.RelationKey(kind, targetId)).Run()
+}
+
+func (s *Store) HasRelation(userId string, kind model.RelationKind, targetId string) (bool, error) {
+	var relation model.Relation
+	err := s.relationTable.Get("UserId", userId).Range("RelationKey", dynamo.Equal, model.RelationKey(kind, targetId)).One(&relation)
+	if err == dynamo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ListRelations returns the users userId has blocked or muted, or both when kind is empty.
+func (s *Store) ListRelations(userId string, kind model.RelationKind) ([]model.Relation, error) {
+	result := []model.Relation{}
+	q := s.relationTable.Get("UserId", userId)
+	if kind != "" {
+		q = q.Range("RelationKey", dynamo.BeginsWith, string(kind)+"#")
+	}
+	err := q.All(&result)
+	return result, err
+}
+
+// IsBlockedBetween reports whether either user has blocked the other.
+func (s *Store) IsBlockedBetween(userId string, otherId string) (bool, error) {
+	blocked, err := s.HasRelation(userId, model.RelationBlock, otherId)
+	if err != nil || blocked {
+		return blocked, err
+	}
+	return s.HasRelation(otherId, model.RelationBlock, userId)
+}
+
+// ListExcludedAuthors returns the users whose paintings userId does not want to see: everyone they
+// muted or blocked.
+func (s *Store) ListExcludedAuthors(userId string) ([]string, error) {
+	relations, err := s.ListRelations(userId, "")
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, relation := range relations {
+		result = append(result, relation.TargetId)
+	}
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"github.com/hirosato/wcs/model"
+)
+
+// PutReport stores the report unless the user already reported the target.
+func (s *Store) PutReport(report model.Report) (bool, error) {
+	err := s.reportTable.Put(report).If("attribute_not_exists('ItemId')").Run()
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) ListReports(itemId string) ([]model.Report, error) {
+	result := []model.Report{}
+	err := s.reportTable.Get("ItemId", itemId).All(&result)
+	return result, err
+}
+
+// RecordReport adds a report to the target's queue item, creating the item on the first report.
+// An item that was dismissed is opened again, since the new report may tell something new.
+func (s *Store) RecordReport(item model.ModerationItem, now uint64) error {
+	u := s.moderationTable.Update("ItemId", item.ItemId).
+		SetIfNotExists("TargetKind", item.TargetKind).
+		SetIfNotExists("TargetUserId", item.TargetUserId).
+		SetIfNotExists("State", model.ModerationOpen).
+		SetIfNotExists("Created", now).
+		Set("LastReported", now).
+		Add("ReportCount", 1)
+	if item.PaintingId != "" {
+		u = u.SetIfNotExists("PaintingId", item.PaintingId).SetIfNotExists("PaintingTimestamp", item.PaintingTimestamp)
+	}
+	if item.CommentId != "" {
+		u = u.SetIfNotExists("CommentId", item.CommentId)
+	}
+	if err := u.Run(); err != nil {
+		return err
+	}
+	err := s.moderationTable.Update("ItemId", item.ItemId).
+		Set("State", model.ModerationOpen).
+		Remove("ClaimedBy", "ClaimedAt").
+		If("'State' = ?", model.ModerationDismissed).
+		Run()
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) GetModerationItem(itemId string) (model.ModerationItem, error) {
+	var result model.ModerationItem
+	err := s.moderationTable.Get("ItemId", itemId).One(&result)
+	return result, storeError(err)
+}
+
+// ListModerationItems returns the items in state, the ones reported longest ago first.
+func (s *Store) ListModerationItems(state model.ModerationState) ([]model.ModerationItem, error) {
+	result := []model.ModerationItem{}
+	err := s.moderationTable.Get("State", state).Index(indexName(s.moderationTable, "state")).All(&result)
+	return result, err
+}
+
+// ClaimModerationItem assigns an open item to the moderator unless someone else claimed it first.
+func (s *Store) ClaimModerationItem(itemId string, userId string, now uint64) (model.ModerationItem, error) {
+	var result model.ModerationItem
+	err := s.moderationTable.Update("ItemId", itemId).
+		Set("ClaimedBy", userId).
+		Set("ClaimedAt", now).
+		If("'State' = ? AND (attribute_not_exists('ClaimedBy') OR 'ClaimedBy' = ?)", model.ModerationOpen, userId).
+		Value(&result)
+	return result, storeError(err)
+}
+
+// ResolveModerationItem closes an item the moderator has claimed.
+func (s *Store) ResolveModerationItem(itemId string, userId string, state model.ModerationState, resolution string, note string, now uint64) (model.ModerationItem, error) {
+	var result model.ModerationItem
+	err := s.moderationTable.Update("ItemId", itemId).
+		Set("State", state).
+		Set("Resolution", resolution).
+		Set("Note", note).
+		Set("ResolvedBy", userId).
+		Set("Resolved", now).
+		If("'State' = ? AND 'ClaimedBy' = ?", model.ModerationOpen, userId).
+		Value(&result)
+	return result, storeError(err)
+}
+
+// RestoreModerationItem records that an earlier action was undone.
+func (s *Store) RestoreModerationItem(itemId string, userId string, note string, now uint64) (model.ModerationItem, error) {
+	var result model.ModerationItem
+	err := s.moderationTable.Update("ItemId", itemId).
+		Set("State", model.ModerationDismissed).
+		Set("Resolution", "restore").
+		Set("Note", note).
+		Set("ResolvedBy", userId).
+		Set("Resolved", now).
+		If("'State' = ?", model.ModerationActioned).
+		Value(&result)
+	return result, storeError(err)
+}
+
+// ReopenModerationItem takes back the moderator's resolution when its action could not be taken.
+// The item stays claimed by them.
+func (s *Store) ReopenModerationItem(itemId string, userId string) error {
+	err := s.moderationTable.Update("ItemId", itemId).
+		Set("State", model.ModerationOpen).
+		Remove("Resolution", "Note", "ResolvedBy", "Resolved").
+		If("'State' = ? AND 'ResolvedBy' = ?", model.ModerationActioned, userId).
+		Run()
+	return storeError(err)
+}
+
+func (s *Store) SetCommentHidden(paintingId string, commentId string, hidden bool) error {
+	err := s.commentTable.Update("PaintingId", paintingId).Range("CommentId", commentId).
+		Set("Hidden", hidden).
+		If("attribute_exists('PaintingId')").
+		Run()
+	return storeError(err)
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/model"
+)
+
+// only scheduled paintings carry the Schedule attribute, so this index stays small.
+func (s *Store) scheduleIndex() string {
+	return indexName(s.paintingTable, "schedule")
+}
+
+// GetDuePaintings returns drafts whose publish time has come, plus paintings a previous run
+// started to publish but did not finish.
+func (s *Store) GetDuePaintings(now uint64) ([]model.Painting, error) {
+	var due []model.Painting
+	err := s.paintingTable.Get("Schedule", model.ScheduleWaiting).Range("PublishAt", dynamo.LessOrEqual, now).Index(s.scheduleIndex()).All(&due)
+	if err != nil {
+		return nil, err
+	}
+	var publishing []model.Painting
+	err = s.paintingTable.Get("Schedule", model.SchedulePublishing).Index(s.scheduleIndex()).All(&publishing)
+	return append(due, publishing...), err
+}
+
+// StartPublishing takes a due draft out of draft state and updates painting in place.
+// It returns false when another run got there first or the owner cancelled the schedule.
+func (s *Store) StartPublishing(painting *model.Painting, now uint64) (bool, error) {
+	err := s.paintingTable.Update("UserId", painting.UserId).Range("Timestamp", painting.Timestamp).
+		Set("Draft", false).
+		Set("Schedule", model.SchedulePublishing).
+		Add("Version", 1).
+		If("'Schedule' = ? AND 'PublishAt' <= ?", model.ScheduleWaiting, now).
+		Value(painting)
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) FinishPublishing(painting *model.Painting) error {
+	err := s.paintingTable.Update("UserId", painting.UserId).Range("Timestamp", painting.Timestamp).
+		Remove("Schedule").
+		Add("Version", 1).
+		If("'Schedule' = ?", model.SchedulePublishing).
+		Run()
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// CancelSchedule keeps the painting as a draft and updates painting in place. It fails once
+// publishing has started, or when the painting is no longer at painting.Version.
+func (s *Store) CancelSchedule(painting *model.Painting) error {
+	u := s.paintingTable.Update("UserId", painting.UserId).Range("Timestamp", painting.Timestamp).
+		Remove("Schedule", "PublishAt").
+		Set("Version", painting.Version+1).
+		If("'Schedule' = ?", model.ScheduleWaiting)
+	return storeError(ifVersion(u, painting.Version).Value(painting))
+}
@@ -0,0 +1,132 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/hirosato/wcs/env"
+)
+
+// tableSchema is one table of cdk/lib/wcs-stack.ts. Keep the two in step: the contract tests are
+// only as good as the copy of the keys and indexes here.
+type tableSchema struct {
+	name    string
+	hash    key
+	sort    key
+	indexes []indexSchema
+}
+
+type indexSchema struct {
+	key  string
+	hash key
+	sort key
+}
+
+type key struct {
+	name string
+	kind string
+}
+
+func stringKey(name string) key {
+	return key{name, dynamodb.ScalarAttributeTypeS}
+}
+
+func numberKey(name string) key {
+	return key{name, dynamodb.ScalarAttributeTypeN}
+}
+
+var tables = []tableSchema{
+	{name: "", hash: stringKey("UserId"), sort: stringKey("Timestamp"), indexes: []indexSchema{
+		{key: "schedule", hash: stringKey("Schedule"), sort: numberKey("PublishAt")},
+		{key: "date", hash: stringKey("Date"), sort: stringKey("Timestamp")},
+		{key: "id", hash: stringKey("PaintingId")},
+		{key: "ranking", hash: stringKey("Ranking"), sort: numberKey("LastActive")},
+	}},
+	{name: "session", hash: stringKey("SessionId"), indexes: []indexSchema{{key: "user", hash: stringKey("UserId")}}},
+	{name: "identity", hash: stringKey("IdentityKey"), indexes: []indexSchema{{key: "user", hash: stringKey("UserId")}}},
+	{name: "token", hash: stringKey("TokenHash"), indexes: []indexSchema{{key: "user", hash: stringKey("UserId")}}},
+	{name: "audit", hash: stringKey("Month"), sort: stringKey("AuditId")},
+	{name: "report", hash: stringKey("ItemId"), sort: stringKey("UserId")},
+	{name: "moderation", hash: stringKey("ItemId"), indexes: []indexSchema{{key: "state", hash: stringKey("State"), sort: numberKey("LastReported")}}},
+	{name: "relation", hash: stringKey("UserId"), sort: stringKey("RelationKey")},
+	{name: "user", hash: stringKey("UserId"), indexes: []indexSchema{{key: "deletion", hash: stringKey("Deletion"), sort: numberKey("DeleteAt")}}},
+	{name: "export", hash: stringKey("UserId"), sort: stringKey("ExportId"), indexes: []indexSchema{{key: "state", hash: stringKey("State"), sort: numberKey("Created")}}},
+	{name: "follow", hash: stringKey("UserId"), sort: stringKey("FolloweeId"), indexes: []indexSchema{{key: "followee", hash: stringKey("FolloweeId"), sort: stringKey("UserId")}}},
+	{name: "notification", hash: stringKey("UserId"), sort: stringKey("NotificationId")},
+	{name: "collection", hash: stringKey("UserId"), sort: stringKey("CollectionId")},
+	{name: "challenge", hash: stringKey("ChallengeId")},
+	{name: "challenge-entry", hash: stringKey("ChallengeId"), sort: stringKey("EntryId")},
+	{name: "challenge-vote", hash: stringKey("EntryKey"), sort: stringKey("UserId")},
+	{name: "reaction", hash: stringKey("PaintingId"), sort: stringKey("ReactionKey"), indexes: []indexSchema{{key: "user", hash: stringKey("UserId"), sort: numberKey("Created")}}},
+	{name: "idempotency", hash: stringKey("IdempotencyKey")},
+	{name: "comment", hash: stringKey("PaintingId"), sort: stringKey("CommentId"), indexes: []indexSchema{{key: "user", hash: stringKey("UserId"), sort: stringKey("CommentId")}}},
+}
+
+func keySchema(hash key, sort key) []*dynamodb.KeySchemaElement {
+	schema := []*dynamodb.KeySchemaElement{{AttributeName: aws.String(hash.name), KeyType: aws.String(dynamodb.KeyTypeHash)}}
+	if sort.name != "" {
+		schema = append(schema, &dynamodb.KeySchemaElement{AttributeName: aws.String(sort.name), KeyType: aws.String(dynamodb.KeyTypeRange)})
+	}
+	return schema
+}
+
+func throughput() *dynamodb.ProvisionedThroughput {
+	return &dynamodb.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(1), WriteCapacityUnits: aws.Int64(1)}
+}
+
+func (table tableSchema) createInput(config env.Config) *dynamodb.CreateTableInput {
+	name := config.TableName(table.name)
+	attributes := map[string]string{}
+	for _, k := range []key{table.hash, table.sort} {
+		attributes[k.name] = k.kind
+	}
+	input := &dynamodb.CreateTableInput{
+		TableName:             aws.String(name),
+		KeySchema:             keySchema(table.hash, table.sort),
+		ProvisionedThroughput: throughput(),
+	}
+	for _, index := range table.indexes {
+		for _, k := range []key{index.hash, index.sort} {
+			attributes[k.name] = k.kind
+		}
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndex{
+			IndexName:             aws.String(name + "-by-" + index.key),
+			KeySchema:             keySchema(index.hash, index.sort),
+			Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+			ProvisionedThroughput: throughput(),
+		})
+	}
+	for attribute, kind := range attributes {
+		if attribute == "" {
+			continue
+		}
+		input.AttributeDefinitions = append(input.AttributeDefinitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(attribute),
+			AttributeType: aws.String(kind),
+		})
+	}
+	return input
+}
+
+// createTables creates every table of config.SystemEnv on config.DynamoEndpoint and drops them when
+// the test ends.
+func createTables(t *testing.T, config env.Config) {
+	client := dynamodb.New(session.New(), &aws.Config{
+		Region:   aws.String(config.Region),
+		Endpoint: aws.String(config.DynamoEndpoint),
+	})
+	for _, table := range tables {
+		input := table.createInput(config)
+		if _, err := client.CreateTable(input); err != nil {
+			t.Fatalf("create %s: %v", *input.TableName, err)
+		}
+		t.Cleanup(func() {
+			client.DeleteTable(&dynamodb.DeleteTableInput{TableName: input.TableName})
+		})
+		if err := client.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: input.TableName}); err != nil {
+			t.Fatalf("wait for %s: %v", *input.TableName, err)
+		}
+	}
+}
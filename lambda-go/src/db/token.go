@@ -0,0 +1,29 @@
+package db
+
+import (
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) PutApiToken(token model.ApiToken) error {
+	return s.apiTokenTable.Put(token).Run()
+}
+
+func (s *Store) GetApiToken(tokenHash string) (model.ApiToken, error) {
+	var result model.ApiToken
+	err := s.apiTokenTable.Get("TokenHash", tokenHash).One(&result)
+	return result, storeError(err)
+}
+
+func (s *Store) ListApiTokens(userId string) ([]model.ApiToken, error) {
+	var result []model.ApiToken
+	err := s.apiTokenTable.Get("UserId", userId).Index(indexName(s.apiTokenTable, "user")).All(&result)
+	return result, err
+}
+
+func (s *Store) TouchApiToken(tokenHash string, now uint64) error {
+	return storeError(s.apiTokenTable.Update("TokenHash", tokenHash).Set("LastUsed", now).If("attribute_exists('TokenHash')").Run())
+}
+
+func (s *Store) DeleteApiToken(tokenHash string) error {
+	return s.apiTokenTable.Delete("TokenHash", tokenHash).Run()
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "errors"
+
+// Errors every backend returns for the same outcome, so handlers and jobs need not know which one
+// they run on.
+var (
+	// ErrNotFound is returned when a read finds no item.
+	ErrNotFound = errors.New("no item found")
+	// ErrConflict is returned when a conditional write is rejected because its condition did not hold.
+	ErrConflict = errors.New("the conditional request failed")
+)
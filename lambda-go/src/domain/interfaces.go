@@ -1,12 +1,31 @@
 package domain
 
-import "github.com/hirosato/wcs/model"
+import (
+	"image"
+
+	"github.com/hirosato/wcs/model"
+)
 
 type LocalFileRepository interface {
 	Add(userId string, timestamp string, base64image string, imageKind model.ImageKind) (string, error)
+	AddImage(name string, img image.Image) (string, error)
 	Remove(filename string)
 }
 
-type S3Repository interface {
+// BlobStore keeps the images served from the CDN.
+type BlobStore interface {
 	Add(localFilePath string, userId string, timestamp string, filename model.ImageKind) error
+	Put(localFilePath string, key string) error
+	Get(key string) ([]byte, error)
+	DeletePrefix(prefix string) error
+}
+
+// ExportStore keeps personal data exports, which are never served from the public CDN.
+type ExportStore interface {
+	Put(key string, body []byte) error
+	PresignGet(key string) (string, error)
+}
+
+type CdnRepository interface {
+	Invalidate(paths []string) error
 }
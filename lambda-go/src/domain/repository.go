@@ -0,0 +1,188 @@
+package domain
+
+import "github.com/hirosato/wcs/model"
+
+// The repositories below are what handlers and jobs read and write through. db.Store implements
+// all of them on DynamoDB.
+
+type PaintingRepository interface {
+	GetPainting(userId string, timestamp string) (model.Painting, error)
+	GetPaintings(refs []model.PaintingRef) (map[string]model.Painting, error)
+	GetPaintingById(paintingId string) (model.Painting, error)
+	AddPainting(painting *model.Painting) error
+	UpdatePainting(painting *model.Painting) error
+	SetPaintingId(userId string, timestamp string, paintingId string) error
+	DeletePainting(painting *model.Painting) error
+	ListUserPaintings(userId string, before string, limit int64) ([]model.Painting, error)
+	ListDatePaintings(date string, before string, limit int64) ([]model.Painting, error)
+	CountPublicDatePaintings(date string) (int64, error)
+	ScanPaintings() ([]model.Painting, error)
+	SetPaintingHidden(userId string, timestamp string, hidden bool, reason string) error
+	IncrementPaintingCounter(userId string, timestamp string, counter string, delta int) error
+	MarkPaintingRanked(userId string, timestamp string, now uint64) error
+	ListRankedPaintings() ([]model.Painting, error)
+	PutPaintingScores(painting *model.Painting) error
+	GetDuePaintings(now uint64) ([]model.Painting, error)
+	StartPublishing(painting *model.Painting, now uint64) (bool, error)
+	FinishPublishing(painting *model.Painting) error
+	CancelSchedule(painting *model.Painting) error
+}
+
+type UserRepository interface {
+	GetUser(userId string) (model.User, error)
+	PutUser(user model.User) (model.User, error)
+	RefreshUserFromProvider(user model.User) error
+	SetUserImages(user model.User, kind model.ProfileImageKind) error
+	UpdateUserProfile(user model.User) error
+	SetUserSuspended(userId string, suspended bool, reason string) error
+	SetUserRole(userId string, role model.Role) error
+	ScheduleDeletion(userId string, deleteAt uint64) error
+	CancelDeletion(userId string) error
+	GetUsersDueForDeletion(now uint64) ([]model.User, error)
+	DeleteUser(userId string) error
+}
+
+type SessionStore interface {
+	GetSession(sessionId string) (model.Session, error)
+	PutSession(session model.Session) (model.Session, error)
+	TouchSession(session model.Session) error
+	SetSessionCsrfToken(sessionId string, token string) (string, error)
+	DeleteSession(sessionId string) error
+	ListUserSessions(userId string) ([]model.Session, error)
+}
+
+type FollowRepository interface {
+	PutFollow(follow model.Follow) error
+	DeleteFollow(userId string, followeeId string) error
+	IsFollowing(userId string, followeeId string) (bool, error)
+	GetFollowers(followeeId string) ([]model.Follow, error)
+	ListFollowing(userId string) ([]model.Follow, error)
+}
+
+type NotificationRepository interface {
+	NotifyFollowers(painting *model.Painting) error
+	ListNotifications(userId string, before string, limit int64) ([]model.Notification, error)
+	DeleteNotifications(userId string) error
+}
+
+type ReactionRepository interface {
+	PutReaction(reaction model.Reaction) (bool, error)
+	DeleteReaction(painting *model.Painting, kind model.ReactionKind, userId string) (bool, error)
+	ListUserReactions(userId string) ([]model.Reaction, error)
+	PutComment(comment *model.Comment) error
+	GetComment(paintingId string, commentId string) (model.Comment, error)
+	ListComments(paintingId string) ([]model.Comment, error)
+	DeleteComment(comment *model.Comment) error
+	ListUserComments(userId string) ([]model.Comment, error)
+	AnonymizeComment(comment *model.Comment) error
+	SetCommentHidden(paintingId string, commentId string, hidden bool) error
+	DeletePaintingActivity(paintingId string) error
+}
+
+type CollectionRepository interface {
+	AddCollection(collection *model.Collection) error
+	PutCollection(collection *model.Collection) error
+	GetCollection(userId string, collectionId string) (model.Collection, error)
+	ListCollections(userId string) ([]model.Collection, error)
+	DeleteCollection(userId string, collectionId string) error
+}
+
+type ChallengeRepository interface {
+	PutChallenge(challenge *model.Challenge) error
+	GetChallenge(challengeId string) (model.Challenge, error)
+	ListChallenges() ([]model.Challenge, error)
+	CloseChallenge(challengeId string, results []model.ChallengeResult) error
+	PutChallengeEntry(entry *model.ChallengeEntry) error
+	GetChallengeEntry(challengeId string, entryId string) (model.ChallengeEntry, error)
+	DeleteChallengeEntry(challengeId string, entryId string) error
+	ListChallengeEntries(challengeId string) ([]model.ChallengeEntry, error)
+	PutChallengeVote(challengeId string, entryId string, vote model.ChallengeVote) (bool, error)
+	CountChallengeVotes(challengeId string, entryId string) (uint32, error)
+}
+
+type IdentityRepository interface {
+	GetIdentity(provider string, subject string) (identity model.Identity, ok bool, err error)
+	LinkIdentity(identity model.Identity) error
+	PutIdentity(identity model.Identity) error
+	ListIdentities(userId string) ([]model.Identity, error)
+	DeleteIdentity(provider string, subject string) error
+}
+
+type TokenRepository interface {
+	PutApiToken(token model.ApiToken) error
+	GetApiToken(tokenHash string) (model.ApiToken, error)
+	ListApiTokens(userId string) ([]model.ApiToken, error)
+	TouchApiToken(tokenHash string, now uint64) error
+	DeleteApiToken(tokenHash string) error
+}
+
+type AuditLog interface {
+	PutAuditEntry(entry model.AuditEntry) error
+	ListAuditEntries(month string) ([]model.AuditEntry, error)
+}
+
+type ReportRepository interface {
+	PutReport(report model.Report) (bool, error)
+	ListReports(itemId string) ([]model.Report, error)
+	RecordReport(item model.ModerationItem, now uint64) error
+	GetModerationItem(itemId string) (model.ModerationItem, error)
+	ListModerationItems(state model.ModerationState) ([]model.ModerationItem, error)
+	ClaimModerationItem(itemId string, userId string, now uint64) (model.ModerationItem, error)
+	ResolveModerationItem(itemId string, userId string, state model.ModerationState, resolution string, note string, now uint64) (model.ModerationItem, error)
+	RestoreModerationItem(itemId string, userId string, note string, now uint64) (model.ModerationItem, error)
+	ReopenModerationItem(itemId string, userId string) error
+}
+
+type RelationRepository interface {
+	PutRelation(relation model.Relation) error
+	DeleteRelation(userId string, kind model.RelationKind, targetId string) error
+	HasRelation(userId string, kind model.RelationKind, targetId string) (bool, error)
+	ListRelations(userId string, kind model.RelationKind) ([]model.Relation, error)
+	IsBlockedBetween(userId string, otherId string) (bool, error)
+	ListExcludedAuthors(userId string) ([]string, error)
+}
+
+type ExportRepository interface {
+	PutExport(export model.Export) error
+	ListExports(userId string) ([]model.Export, error)
+	GetPendingExports() ([]model.Export, error)
+	DeleteExport(userId string, exportId string) error
+}
+
+// IdempotencyStore keeps the responses to requests sent with an Idempotency-Key header.
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(record model.IdempotencyRecord, now int64) (bool, error)
+	GetIdempotencyRecord(key string) (model.IdempotencyRecord, error)
+	CompleteIdempotencyRecord(record model.IdempotencyRecord) error
+	ReleaseIdempotencyKey(key string) error
+}
+
+// list modes of SearchIndex.ListWaterColorSite besides the default newest first.
+const (
+	TrendingToday = "today"
+	TrendingWeek  = "week"
+	TrendingAll   = "all"
+)
+
+// IsTrendingMode reports whether ListWaterColorSite knows the list mode.
+func IsTrendingMode(trending string) bool {
+	switch trending {
+	case "", TrendingToday, TrendingWeek, TrendingAll:
+		return true
+	default:
+		return false
+	}
+}
+
+// SearchIndex serves the public listings. Only public paintings are put in it.
+type SearchIndex interface {
+	PutEsPainting(painting *model.Painting) error
+	DeleteEsPainting(painting *model.Painting) error
+	UpdateEsScores(painting *model.Painting) error
+	ListWaterColorSite(offset int, trending string, excludedUserIds []string) []model.Painting
+}
+
+type PigmentCatalog interface {
+	GetPigment(lang model.SupportedLang, filtergroup int32, name string) (*[]model.Pigment, error)
+	GetPigmentsByKeys(lang model.SupportedLang, keys []int32) ([]model.Pigment, error)
+}
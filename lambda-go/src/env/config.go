@@ -0,0 +1,278 @@
+package env
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Profiles choose the defaults for where the code runs. Anything a profile sets can still be
+// overridden by the config file and then by environment variables.
+const (
+	ProfileLocal   = "local"
+	ProfileDev     = "dev"
+	ProfileStaging = "staging"
+	ProfileProd    = "prod"
+)
+
+// Modes choose the backends. The memory mode keeps everything in process memory and needs
+// neither AWS nor a network.
+const (
+	ModeAws    = "aws"
+	ModeMemory = "memory"
+)
+
+// Config is everything the API and the scheduler read from their environment.
+type Config struct {
+	Profile string `json:"profile"`
+	Mode    string `json:"mode"`
+	// SystemEnv is the suffix of the tables and buckets, as in wcs-table-<SystemEnv>. The CDK stack
+	// passes its SYSTEM_ENV here, so the code uses the resources of the stack it was deployed with.
+	SystemEnv string `json:"systemEnv"`
+	Region    string `json:"region"`
+
+	FrontUrl     string `json:"frontUrl"`
+	ApiUrl       string `json:"apiUrl"`
+	EsUrl        string `json:"esUrl"`
+	ImageUrl     string `json:"imageUrl"`
+	CookieDomain string `json:"cookieDomain"`
+
+	// DynamoEndpoint points at DynamoDB Local or similar. Empty means the region's DynamoDB.
+	DynamoEndpoint string `json:"dynamoEndpoint"`
+	// SignEsRequests signs Elasticsearch requests with the lambda's credentials.
+	SignEsRequests bool   `json:"signEsRequests"`
+	SqlitePath     string `json:"sqlitePath"`
+
+	BucketName       string `json:"bucketName"`
+	ExportBucketName string `json:"exportBucketName"`
+	DistributionId   string `json:"distributionId"`
+
+	// LambdaHandler selects the entry point of the binary: the API by default, or "scheduler" for background jobs.
+	LambdaHandler string `json:"lambdaHandler"`
+	// user ids that are admins whatever their stored role, to bootstrap the first admin.
+	AdminUserIds []string `json:"adminUserIds"`
+
+	// sign-in providers. A provider is offered only when its key or client id is set.
+	TwitterConsumerKey    string `json:"twitterConsumerKey"`
+	TwitterConsumerSecret string `json:"twitterConsumerSecret"`
+	GoogleClientId        string `json:"googleClientId"`
+	GoogleClientSecret    string `json:"googleClientSecret"`
+	GitHubClientId        string `json:"gitHubClientId"`
+	GitHubClientSecret    string `json:"gitHubClientSecret"`
+}
+
+func (config Config) IsLocal() bool {
+	return config.Profile == ProfileLocal
+}
+
+func (config Config) IsMemory() bool {
+	return config.Mode == ModeMemory
+}
+
+// IsAdmin reports whether userId is one of the bootstrap admins.
+func (config Config) IsAdmin(userId string) bool {
+	for _, id := range config.AdminUserIds {
+		if id != "" && id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// TableName returns the name of the DynamoDB table for name, such as "session". The empty name is
+// the paintings table.
+func (config Config) TableName(name string) string {
+	if name == "" {
+		return "wcs-table-" + config.SystemEnv
+	}
+	return "wcs-" + name + "-table-" + config.SystemEnv
+}
+
+func defaults(profile string) Config {
+	config := Config{
+		Profile:        profile,
+		Mode:           ModeAws,
+		SystemEnv:      profile,
+		Region:         "ap-northeast-1",
+		SignEsRequests: true,
+		SqlitePath:     "./db.sqlite",
+	}
+	switch profile {
+	case ProfileLocal:
+		config.FrontUrl = "http://localhost:4200"
+		config.ApiUrl = "http://localhost:8080"
+		config.EsUrl = "http://localhost:9200"
+		config.ImageUrl = "https://img.watercolor.site"
+		config.DynamoEndpoint = "http://localhost:8000"
+		config.SignEsRequests = false
+		config.SqlitePath = "../sqlite/db.sqlite"
+	case ProfileProd:
+		config.FrontUrl = "https://watercolor.site"
+		config.ApiUrl = "https://api.watercolor.site"
+		config.EsUrl = "https://es.watercolor.site"
+		config.ImageUrl = "https://img.watercolor.site"
+		config.CookieDomain = "watercolor.site"
+	}
+	// dev and staging have no hosts of their own yet; their URLs come from the file or environment.
+	return config
+}
+
+// Load builds the config from the profile's defaults, then the JSON file named by WCS_CONFIG_FILE
+// if there is one, then environment variables.
+func Load() (Config, error) {
+	var file []byte
+	if path := os.Getenv("WCS_CONFIG_FILE"); path != "" {
+		var err error
+		if file, err = ioutil.ReadFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	var fromFile struct {
+		Profile string `json:"profile"`
+		Mode    string `json:"mode"`
+	}
+	if file != nil {
+		if err := json.Unmarshal(file, &fromFile); err != nil {
+			return Config{}, fmt.Errorf("%s: %s", os.Getenv("WCS_CONFIG_FILE"), err.Error())
+		}
+	}
+	mode := fromFile.Mode
+	if value := os.Getenv("WCS_MODE"); value != "" {
+		mode = value
+	}
+	config := defaults(profileOf(fromFile.Profile, mode))
+	if file != nil {
+		// fields the file leaves out keep the profile's value.
+		if err := json.Unmarshal(file, &config); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for name, field := range map[string]*string{
+		"WCS_MODE":                &config.Mode,
+		"SYSTEM_ENV":              &config.SystemEnv,
+		"AWS_REGION":              &config.Region,
+		"FRONT_URL":               &config.FrontUrl,
+		"API_URL":                 &config.ApiUrl,
+		"ES_URL":                  &config.EsUrl,
+		"IMAGE_URL":               &config.ImageUrl,
+		"COOKIE_DOMAIN":           &config.CookieDomain,
+		"DYNAMO_ENDPOINT":         &config.DynamoEndpoint,
+		"SQLITE_PATH":             &config.SqlitePath,
+		"BUCKET_NAME":             &config.BucketName,
+		"EXPORT_BUCKET_NAME":      &config.ExportBucketName,
+		"DISTRIBUTION_ID":         &config.DistributionId,
+		"LAMBDA_HANDLER":          &config.LambdaHandler,
+		"TWITTER_CONSUMER_KEY":    &config.TwitterConsumerKey,
+		"TWITTER_CONSUMER_SECRET": &config.TwitterConsumerSecret,
+		"GOOGLE_CLIENT_ID":        &config.GoogleClientId,
+		"GOOGLE_CLIENT_SECRET":    &config.GoogleClientSecret,
+		"GITHUB_CLIENT_ID":        &config.GitHubClientId,
+		"GITHUB_CLIENT_SECRET":    &config.GitHubClientSecret,
+	} {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			*field = value
+		}
+	}
+	if value := os.Getenv("SIGN_ES_REQUESTS"); value != "" {
+		config.SignEsRequests = value == "TRUE"
+	}
+	if value := os.Getenv("ADMIN_USER_IDS"); value != "" {
+		config.AdminUserIds = strings.Split(value, ",")
+	}
+	if config.BucketName == "" {
+		config.BucketName = "wcs-bucket-" + config.SystemEnv
+	}
+	if config.ExportBucketName == "" {
+		config.ExportBucketName = "wcs-export-bucket-" + config.SystemEnv
+	}
+	return config, nil
+}
+
+// profileOf picks the profile: WCS_PROFILE, then the config file's, then IS_LOCAL or the memory
+// mode, then a SYSTEM_ENV that names a profile. Anything else runs as prod.
+func profileOf(fromFile string, mode string) string {
+	if profile := os.Getenv("WCS_PROFILE"); profile != "" {
+		return profile
+	}
+	if fromFile != "" {
+		return fromFile
+	}
+	if os.Getenv("IS_LOCAL") == "TRUE" || mode == ModeMemory {
+		return ProfileLocal
+	}
+	switch systemEnv := os.Getenv("SYSTEM_ENV"); systemEnv {
+	case ProfileDev, ProfileStaging, ProfileProd:
+		return systemEnv
+	}
+	return ProfileProd
+}
+
+// Validate reports everything wrong with the config at once.
+func (config Config) Validate() error {
+	problems := []string{}
+	switch config.Profile {
+	case ProfileLocal, ProfileDev, ProfileStaging, ProfileProd:
+	default:
+		problems = append(problems, "unknown profile: "+config.Profile)
+	}
+	if config.Mode != ModeAws && config.Mode != ModeMemory {
+		problems = append(problems, "unknown mode: "+config.Mode)
+	}
+	if config.SystemEnv == "" {
+		problems = append(problems, "systemEnv is not set")
+	}
+	if config.Region == "" {
+		problems = append(problems, "region is not set")
+	}
+	for _, field := range [][2]string{
+		{"frontUrl", config.FrontUrl},
+		{"apiUrl", config.ApiUrl},
+		{"esUrl", config.EsUrl},
+		{"imageUrl", config.ImageUrl},
+	} {
+		if msg := checkUrl(field[0], field[1]); msg != "" {
+			problems = append(problems, msg)
+		}
+	}
+	if config.DynamoEndpoint != "" {
+		if msg := checkUrl("dynamoEndpoint", config.DynamoEndpoint); msg != "" {
+			problems = append(problems, msg)
+		}
+	}
+	if config.SqlitePath == "" {
+		problems = append(problems, "sqlitePath is not set")
+	}
+	if config.TwitterConsumerKey != "" && config.TwitterConsumerSecret == "" {
+		problems = append(problems, "twitterConsumerSecret is not set")
+	}
+	if config.GoogleClientId != "" && config.GoogleClientSecret == "" {
+		problems = append(problems, "googleClientSecret is not set")
+	}
+	if config.GitHubClientId != "" && config.GitHubClientSecret == "" {
+		problems = append(problems, "gitHubClientSecret is not set")
+	}
+	if !config.IsLocal() && !config.IsMemory() && config.LambdaHandler != "scheduler" && config.TwitterConsumerKey == "" && config.GoogleClientId == "" && config.GitHubClientId == "" {
+		problems = append(problems, "no sign-in provider is configured")
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid config: " + strings.Join(problems, ", "))
+}
+
+func checkUrl(name string, value string) string {
+	if value == "" {
+		return name + " is not set"
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return name + " has to be an http or https URL"
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package env
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("profile defaults, then file, then environment", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := ioutil.WriteFile(path, []byte(`{"profile":"local","esUrl":"http://es:9200","apiUrl":"http://api:8080"}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		setenv(t, "WCS_CONFIG_FILE", path)
+		setenv(t, "API_URL", "http://localhost:9000")
+		config, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.FrontUrl != "http://localhost:4200" || config.EsUrl != "http://es:9200" || config.ApiUrl != "http://localhost:9000" {
+			t.Fatalf("unexpected urls %+v", config)
+		}
+		if config.TableName("session") != "wcs-session-table-local" || config.BucketName != "wcs-bucket-local" {
+			t.Fatalf("unexpected resources %+v", config)
+		}
+		if err := config.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("SYSTEM_ENV selects the profile and the resources", func(t *testing.T) {
+		setenv(t, "IS_LOCAL", "")
+		setenv(t, "SYSTEM_ENV", "staging")
+		config, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Profile != ProfileStaging || config.TableName("") != "wcs-table-staging" {
+			t.Fatalf("unexpected config %+v", config)
+		}
+		if config.Validate() == nil {
+			t.Fatal("staging without urls should not validate")
+		}
+	})
+}
+
+// setenv sets key for the rest of the test and restores it afterwards, as t.Setenv does from Go
+// 1.17 on.
+func setenv(t *testing.T, key string, value string) {
+	previous, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
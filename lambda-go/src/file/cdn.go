@@ -0,0 +1,40 @@
+package file
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudfront"
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/env"
+)
+
+type cloudFrontRepositoryImpl struct {
+	distributionId string
+}
+
+func NewCloudFrontRepository(config env.Config) domain.CdnRepository {
+	return cloudFrontRepositoryImpl{distributionId: config.DistributionId}
+}
+
+// Invalidate drops the paths from the image CDN's cache. Paths may end with * to match a prefix.
+func (impl cloudFrontRepositoryImpl) Invalidate(paths []string) error {
+	if impl.distributionId == "" {
+		return errors.New("DISTRIBUTION_ID is not set")
+	}
+	svc := cloudfront.New(session.Must(session.NewSession()))
+	_, err := svc.CreateInvalidation(&cloudfront.CreateInvalidationInput{
+		DistributionId: aws.String(impl.distributionId),
+		InvalidationBatch: &cloudfront.InvalidationBatch{
+			CallerReference: aws.String(strconv.FormatInt(time.Now().UnixNano(), 10)),
+			Paths: &cloudfront.Paths{
+				Quantity: aws.Int64(int64(len(paths))),
+				Items:    aws.StringSlice(paths),
+			},
+		},
+	})
+	return err
+}
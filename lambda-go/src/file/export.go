@@ -0,0 +1,51 @@
+package file
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/env"
+)
+
+// download links of an export stop working after this long.
+const exportLinkLifetime = 15 * time.Minute
+
+type exportStoreImpl struct {
+	bucketName string
+	region     string
+}
+
+func NewExportStore(config env.Config) domain.ExportStore {
+	return exportStoreImpl{bucketName: config.ExportBucketName, region: config.Region}
+}
+
+func (impl exportStoreImpl) Put(key string, body []byte) error {
+	uploader := s3manager.NewUploader(impl.newSession())
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Bucket:      aws.String(impl.bucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/zip"),
+	})
+	return err
+}
+
+// PresignGet returns a short lived link to download key.
+func (impl exportStoreImpl) PresignGet(key string) (string, error) {
+	req, _ := s3.New(impl.newSession()).GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(impl.bucketName),
+		Key:    aws.String(key),
+	})
+	return req.Presign(exportLinkLifetime)
+}
+
+func (impl exportStoreImpl) newSession() *session.Session {
+	return session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(impl.region),
+	}))
+}
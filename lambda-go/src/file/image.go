@@ -0,0 +1,65 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/vincent-petithory/dataurl"
+)
+
+// uploads larger than this are refused before they are decoded, so a small file cannot claim a
+// huge canvas and exhaust the lambda's memory.
+const maxImagePixels = 25 * 1000 * 1000
+
+// DecodeImage reads an image sent by the front end as a data URL. PNG and JPEG are accepted.
+func DecodeImage(base64image string) (image.Image, error) {
+	dataURL, err := dataurl.DecodeString(base64image)
+	if err != nil {
+		return nil, err
+	}
+	if dataURL.ContentType() != "image/png" && dataURL.ContentType() != "image/jpeg" {
+		return nil, errors.New("only png and jpeg images are accepted")
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(dataURL.Data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, errors.New("the image is too large")
+	}
+	img, _, err := image.Decode(bytes.NewReader(dataURL.Data))
+	return img, err
+}
+
+// CropAndResize cuts crop out of img and scales it to width x height. Each destination pixel is the
+// average of the source pixels it covers, which is good enough for the downscaling avatars need.
+func CropAndResize(img image.Image, crop image.Rectangle, width int, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := crop.Min.Y + y*crop.Dy()/height
+		y1 := crop.Min.Y + (y+1)*crop.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := crop.Min.X + x*crop.Dx()/width
+			x1 := crop.Min.X + (x+1)*crop.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -2,6 +2,8 @@ package file
 
 import (
 	"errors"
+	"image"
+	"image/png"
 	"os"
 
 	"github.com/hirosato/wcs/domain"
@@ -35,6 +37,17 @@ func (impl *localFileRepositoryImpl) Add(userId string, timestamp string, base64
 	return "", errors.New("something went wrong")
 }
 
+// AddImage writes img to a temporary PNG file and returns its path.
+func (impl *localFileRepositoryImpl) AddImage(name string, img image.Image) (string, error) {
+	filename := "/tmp/" + name + ".png"
+	file, err := os.Create(filename)
+	if err != nil {
+		return filename, err
+	}
+	defer file.Close()
+	return filename, png.Encode(file, img)
+}
+
 //ignore remove failure since its on lambda anyway.
 func (impl *localFileRepositoryImpl) Remove(filename string) {
 	if fileExists(filename) {
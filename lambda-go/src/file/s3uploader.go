@@ -5,16 +5,20 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/hirosato/wcs/domain"
 	"github.com/hirosato/wcs/env"
 	"github.com/hirosato/wcs/model"
 )
 
-type s3RepositoryImpl struct{}
+type s3RepositoryImpl struct {
+	bucketName string
+	region     string
+}
 
-func NewS3RepositoryImpl() domain.S3Repository {
-	return s3RepositoryImpl{}
+func NewS3RepositoryImpl(config env.Config) domain.BlobStore {
+	return s3RepositoryImpl{bucketName: config.BucketName, region: config.Region}
 }
 
 func (impl s3RepositoryImpl) Add(localFilePath string, userId string, timestamp string, filename model.ImageKind) error {
@@ -32,11 +36,25 @@ func (impl s3RepositoryImpl) Add(localFilePath string, userId string, timestamp
 }
 
 func (impl s3RepositoryImpl) upload(file *os.File, userId string, timestamp string, filename model.ImageKind) error {
+	return impl.put(file, "/wcs/"+userId+"/"+timestamp+"/"+filename.ToPathString()+".png")
+}
+
+// Put uploads a local file to key in the image bucket.
+func (impl s3RepositoryImpl) Put(localFilePath string, key string) error {
+	file, err := os.Open(localFilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return impl.put(file, key)
+}
+
+func (impl s3RepositoryImpl) put(file *os.File, key string) error {
 	uploader := s3manager.NewUploader(impl.newSession())
 
 	if _, err := uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(env.GetBucketName()),
-		Key:    aws.String("/wcs/" + userId + "/" + timestamp + "/" + filename.ToPathString() + ".png"),
+		Bucket: aws.String(impl.bucketName),
+		Key:    aws.String(key),
 		Body:   file,
 	}); err != nil {
 		return err
@@ -45,8 +63,31 @@ func (impl s3RepositoryImpl) upload(file *os.File, userId string, timestamp stri
 	return nil
 }
 
+// Get downloads key from the image bucket.
+func (impl s3RepositoryImpl) Get(key string) ([]byte, error) {
+	downloader := s3manager.NewDownloader(impl.newSession())
+	buf := aws.NewWriteAtBuffer([]byte{})
+	if _, err := downloader.Download(buf, &s3.GetObjectInput{
+		Bucket: aws.String(impl.bucketName),
+		Key:    aws.String(key),
+	}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DeletePrefix removes every object in the image bucket whose key starts with prefix.
+func (impl s3RepositoryImpl) DeletePrefix(prefix string) error {
+	svc := s3.New(impl.newSession())
+	iter := s3manager.NewDeleteListIterator(svc, &s3.ListObjectsInput{
+		Bucket: aws.String(impl.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	return s3manager.NewBatchDeleteWithClient(svc).Delete(aws.BackgroundContext(), iter)
+}
+
 func (impl s3RepositoryImpl) newSession() *session.Session {
 	return session.Must(session.NewSession(&aws.Config{
-		Region: aws.String("ap-northeast-1"),
+		Region: aws.String(impl.region),
 	}))
 }
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+type exportInfo struct {
+	model.Export
+	URL string `json:"url,omitempty"`
+}
+
+//GET /export
+// the user's exports, newest first, with a download link for the ready ones.
+func (h *Handler) ServeExports(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	exports, err := h.Exports.ListExports(user.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	now := util.GetUnixMilli()
+	results := []exportInfo{}
+	for _, export := range exports {
+		info := exportInfo{Export: export}
+		if export.State == model.ExportReady && export.Finished+model.ExportLifetime > now {
+			if info.URL, err = h.ExportFiles.PresignGet(export.Key); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		results = append(results, info)
+	}
+	c.JSON(200, gin.H{
+		"results": results,
+	})
+}
+
+//POST /export
+// asks for a ZIP of the user's data. It is built in the background; poll GET /export for it.
+func (h *Handler) CreateExport(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	exports, err := h.Exports.ListExports(user.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, export := range exports {
+		if export.State == model.ExportPending {
+			c.JSON(http.StatusConflict, gin.H{"error": "an export is already being built"})
+			return
+		}
+	}
+	_, timestamp := util.GetDateAndTimestamp()
+	export := model.Export{
+		UserId:   user.UserId,
+		ExportId: timestamp,
+		State:    model.ExportPending,
+		Created:  util.GetUnixMilli(),
+	}
+	if err := h.Exports.PutExport(export); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(202, export)
+}
+
+//POST /account/deletion
+// schedules the account to be deleted after the grace period. Until then it can be cancelled.
+func (h *Handler) ScheduleDeletion(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.Deletion == model.DeletionScheduled {
+		c.JSON(200, gin.H{"deleteAt": user.DeleteAt})
+		return
+	}
+	deleteAt := util.GetUnixMilli() + model.DeletionGracePeriod
+	if err := h.Users.ScheduleDeletion(user.UserId, deleteAt); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(202, gin.H{"deleteAt": deleteAt})
+}
+
+//DELETE /account/deletion
+func (h *Handler) CancelDeletion(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Users.CancelDeletion(user.UserId); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+const actorKey = "actor"
+
+// roleOf returns the user's role. Users listed in ADMIN_USER_IDS are admins regardless.
+func (h *Handler) roleOf(user model.User) model.Role {
+	if h.Config.IsAdmin(user.UserId) {
+		return model.RoleAdmin
+	}
+	return user.GetRole()
+}
+
+// RequireRole lets only users with role, or a role that includes it, through to the route.
+func (h *Handler) RequireRole(role model.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := h.GetUser(c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !h.roleOf(user).Includes(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": string(role) + "s only"})
+			return
+		}
+		c.Set(actorKey, user)
+		c.Next()
+	}
+}
+
+func getActor(c *gin.Context) model.User {
+	return c.MustGet(actorKey).(model.User)
+}
+
+// audit records an admin action. It is written before the action is taken, so nothing happens
+// through the admin API without a trail; a failed write stops the action.
+func (h *Handler) audit(c *gin.Context, action string, targetKind string, targetId string, detail string) bool {
+	now := time.Now().UTC()
+	_, timestamp := util.GetDateAndTimestamp()
+	entry := model.AuditEntry{
+		Month:      now.Format("200601"),
+		AuditId:    timestamp + "-" + util.NewId()[:8],
+		ActorId:    getActor(c).UserId,
+		Action:     action,
+		TargetKind: targetKind,
+		TargetId:   targetId,
+		Detail:     detail,
+		Created:    util.GetUnixMilli(),
+	}
+	if err := h.Audit.PutAuditEntry(entry); err != nil {
+		c.JSON(500, gin.H{"error": "could not write the audit log: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+type reasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+// getTargetPainting loads the painting addressed by the URL, whoever owns it.
+func (h *Handler) getTargetPainting(c *gin.Context) (*model.Painting, error) {
+	id := c.Param("id")
+	timestamp := c.Param("timestamp")
+	painting, err := h.Paintings.GetPainting(id, timestamp)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + id + "-" + timestamp,
+		})
+		return nil, err
+	}
+	return &painting, nil
+}
+
+// applyPaintingHidden hides or unhides the painting and brings the ES index in line. Hidden
+// paintings are only dropped from listings, so unhiding restores them as they were.
+func (h *Handler) applyPaintingHidden(painting *model.Painting, hidden bool, reason string) error {
+	if err := h.Paintings.SetPaintingHidden(painting.UserId, painting.Timestamp, hidden, reason); err != nil {
+		return err
+	}
+	painting.Hidden = hidden
+	painting.HiddenReason = reason
+	if !hidden {
+		painting.HiddenReason = ""
+	}
+	return h.indexPainting(painting)
+}
+
+// applyUserSuspended suspends or reinstates the user. Suspending also logs them out everywhere.
+func (h *Handler) applyUserSuspended(userId string, suspended bool, reason string) error {
+	if err := h.Users.SetUserSuspended(userId, suspended, reason); err != nil {
+		return err
+	}
+	if suspended {
+		return h.Sessions.ClearUserSessions(userId)
+	}
+	return nil
+}
+
+func (h *Handler) setPaintingHidden(c *gin.Context, hidden bool) {
+	painting, err := h.getTargetPainting(c)
+	if err != nil {
+		return
+	}
+	var req reasonRequest
+	if hidden {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	action := "unhide-painting"
+	if hidden {
+		action = "hide-painting"
+	}
+	if !h.audit(c, action, "painting", painting.GetId(), req.Reason) {
+		return
+	}
+	if err := h.applyPaintingHidden(painting, hidden, req.Reason); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, painting)
+}
+
+//PUT /admin/paintings/:id/:timestamp/hidden
+func (h *Handler) HidePainting(c *gin.Context) {
+	h.setPaintingHidden(c, true)
+}
+
+//DELETE /admin/paintings/:id/:timestamp/hidden
+func (h *Handler) UnhidePainting(c *gin.Context) {
+	h.setPaintingHidden(c, false)
+}
+
+//POST /admin/paintings/:id/:timestamp/reindex
+// writes the painting to ES again from DynamoDB, or removes it when it should not be listed.
+func (h *Handler) ReindexPainting(c *gin.Context) {
+	painting, err := h.getTargetPainting(c)
+	if err != nil {
+		return
+	}
+	if !h.audit(c, "reindex-painting", "painting", painting.GetId(), "") {
+		return
+	}
+	if err := h.indexPainting(painting); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"indexed": painting.IsPublic(),
+	})
+}
+
+type invalidateRequest struct {
+	Paths []string `json:"paths" binding:"required"`
+}
+
+//POST /admin/invalidate
+// drops paths such as /wcs/:id/:timestamp/* from the image CDN's cache.
+func (h *Handler) InvalidatePainting(c *gin.Context) {
+	var req invalidateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Paths) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "paths is required"})
+		return
+	}
+	for _, path := range req.Paths {
+		if !strings.HasPrefix(path, "/") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "paths have to start with /: " + path})
+			return
+		}
+	}
+	if !h.audit(c, "invalidate-cache", "cache", strings.Join(req.Paths, " "), "") {
+		return
+	}
+	if err := h.Cdn.Invalidate(req.Paths); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
+
+// getTargetUser loads the user addressed by the URL. Admins cannot act on themselves, so nobody
+// can lock themselves out by accident.
+func (h *Handler) getTargetUser(c *gin.Context) (*model.User, error) {
+	userId := c.Param("id")
+	if userId == getActor(c).UserId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot do this to yourself"})
+		return nil, errors.New("self")
+	}
+	user, err := h.Users.GetUser(userId)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + userId,
+		})
+		return nil, err
+	}
+	return &user, nil
+}
+
+// canSuspend refuses to suspend the actor themselves, or a moderator or admin unless the actor is
+// an admin.
+func (h *Handler) canSuspend(c *gin.Context, actor model.User, userId string) bool {
+	if userId == actor.UserId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot do this to yourself"})
+		return false
+	}
+	target, err := h.Users.GetUser(userId)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + userId,
+		})
+		return false
+	}
+	if h.roleOf(target).Includes(model.RoleModerator) && !h.roleOf(actor).Includes(model.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only admins can suspend moderators"})
+		return false
+	}
+	return true
+}
+
+//PUT /admin/users/:id/suspended
+// also logs the user out everywhere.
+func (h *Handler) SuspendUser(c *gin.Context) {
+	user, err := h.getTargetUser(c)
+	if err != nil {
+		return
+	}
+	var req reasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.audit(c, "suspend-user", "user", user.UserId, req.Reason) {
+		return
+	}
+	if err := h.applyUserSuspended(user.UserId, true, req.Reason); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	user.Suspended = true
+	user.SuspendedReason = req.Reason
+	c.JSON(200, user)
+}
+
+//DELETE /admin/users/:id/suspended
+func (h *Handler) UnsuspendUser(c *gin.Context) {
+	user, err := h.getTargetUser(c)
+	if err != nil {
+		return
+	}
+	if !h.audit(c, "unsuspend-user", "user", user.UserId, "") {
+		return
+	}
+	if err := h.applyUserSuspended(user.UserId, false, ""); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	user.Suspended = false
+	user.SuspendedReason = ""
+	c.JSON(200, user)
+}
+
+type roleRequest struct {
+	Role model.Role `json:"role" binding:"required"`
+}
+
+//PUT /admin/users/:id/role
+func (h *Handler) SetUserRole(c *gin.Context) {
+	user, err := h.getTargetUser(c)
+	if err != nil {
+		return
+	}
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role: " + string(req.Role)})
+		return
+	}
+	if !h.audit(c, "set-role", "user", user.UserId, string(user.GetRole())+" -> "+string(req.Role)) {
+		return
+	}
+	if err := h.Users.SetUserRole(user.UserId, req.Role); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	user.Role = req.Role
+	c.JSON(200, user)
+}
+
+//GET /admin/audit?month=yyyymm
+// the current month when month is left out.
+func (h *Handler) ServeAuditLog(c *gin.Context) {
+	month := c.DefaultQuery("month", time.Now().UTC().Format("200601"))
+	if _, err := time.Parse("200601", month); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "month has to be yyyymm"})
+		return
+	}
+	entries, err := h.Audit.ListAuditEntries(month)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"results": entries,
+	})
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+)
+
+// layouts of the dates paintings are filed under, as util.GetDateAndTimestamp writes them.
+const (
+	dayLayout   = "20060102"
+	monthLayout = "200601"
+)
+
+// The daily gallery and the archive read DynamoDB only, so they keep working when Elasticsearch
+// does not.
+
+// ByDate lets daily serve /wcs/date/:yyyymmdd and painting everything else under
+// /wcs/:id/:timestamp. gin cannot route the two side by side, and no user id is "date".
+func ByDate(daily gin.HandlerFunc, painting gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Param("id") != "date" {
+			painting(c)
+			return
+		}
+		c.Params = append(c.Params, gin.Param{Key: "yyyymmdd", Value: c.Param("timestamp")})
+		daily(c)
+	}
+}
+
+//GET /wcs/date/:yyyymmdd?before=
+// public paintings submitted on the day, newest first.
+func (h *Handler) ServeDatePaintings(c *gin.Context) {
+	date := c.Param("yyyymmdd")
+	if _, err := time.Parse(dayLayout, date); err != nil || len(date) != len(dayLayout) {
+		c.JSON(400, gin.H{
+			"message": "bad request",
+		})
+		return
+	}
+	excluded := map[string]bool{}
+	if viewer := h.getViewer(c); viewer != nil {
+		userIds, err := h.Relations.ListExcludedAuthors(viewer.UserId)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		for _, userId := range userIds {
+			excluded[userId] = true
+		}
+	}
+	result, next, err := readGalleryPage(c.Query("before"), func(before string) ([]model.Painting, error) {
+		return h.Paintings.ListDatePaintings(date, before, galleryPageSize)
+	}, func(painting *model.Painting) bool {
+		return painting.IsPublic() && !excluded[painting.UserId]
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"results": result,
+		"next":    next,
+	})
+}
+
+// past months change only when a painting of theirs is hidden or unpublished, so clients and the
+// CDN can keep them for a while.
+const pastArchiveMaxAge = "public, max-age=3600"
+
+type archiveDay struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+//GET /archive/:yyyymm
+// the number of public paintings of each day of the month up to today. prev and next are the
+// neighbouring months; next is empty for the current month.
+func (h *Handler) ServeArchive(c *gin.Context) {
+	month, err := time.Parse(monthLayout, c.Param("yyyymm"))
+	now := time.Now().UTC()
+	if err != nil || len(c.Param("yyyymm")) != len(monthLayout) || month.After(now) {
+		c.JSON(400, gin.H{
+			"message": "bad request",
+		})
+		return
+	}
+	days := []archiveDay{}
+	for day := month; day.Month() == month.Month() && !day.After(now); day = day.AddDate(0, 0, 1) {
+		days = append(days, archiveDay{Date: day.Format(dayLayout)})
+	}
+	// one query per day, so they run side by side.
+	errs := make([]error, len(days))
+	var wg sync.WaitGroup
+	for i := range days {
+		wg.Add(1)
+		go func(day *archiveDay, err *error) {
+			defer wg.Done()
+			day.Count, *err = h.Paintings.CountPublicDatePaintings(day.Date)
+		}(&days[i], &errs[i])
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	next := ""
+	if nextMonth := month.AddDate(0, 1, 0); !nextMonth.After(now) {
+		next = nextMonth.Format(monthLayout)
+		c.Header("Cache-Control", pastArchiveMaxAge)
+	}
+	c.JSON(200, gin.H{
+		"month": month.Format(monthLayout),
+		"days":  days,
+		"prev":  month.AddDate(0, -1, 0).Format(monthLayout),
+		"next":  next,
+	})
+}
@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+type challengeRequest struct {
+	ChallengeId string `json:"challengeId" binding:"required"`
+	Title       string `json:"title" binding:"required"`
+	Theme       string `json:"theme"`
+	StartAt     uint64 `json:"startAt" binding:"required"`
+	EndAt       uint64 `json:"endAt" binding:"required"`
+	VoteEndAt   uint64 `json:"voteEndAt" binding:"required"`
+}
+
+type challengeResponse struct {
+	model.Challenge
+	State model.ChallengeState `json:"state"`
+}
+
+func asChallengeResponse(challenge model.Challenge, now uint64) challengeResponse {
+	return challengeResponse{Challenge: challenge, State: challenge.State(now)}
+}
+
+// getChallenge loads the challenge addressed by the URL.
+func (h *Handler) getChallenge(c *gin.Context) (*model.Challenge, bool) {
+	challengeId := c.Param("challengeId")
+	challenge, err := h.Challenges.GetChallenge(challengeId)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + challengeId,
+		})
+		return nil, false
+	}
+	return &challenge, true
+}
+
+// visiblePaintings loads the paintings of refs and returns the ids of those viewer may see, so
+// entries of deleted, hidden or private paintings do not give their ids away.
+func (h *Handler) visiblePaintings(viewer *model.User, refs []model.PaintingRef) (map[string]bool, error) {
+	found, err := h.Paintings.GetPaintings(refs)
+	if err != nil {
+		return nil, err
+	}
+	visible := map[string]bool{}
+	for id, painting := range found {
+		if h.canView(viewer, &painting) {
+			visible[id] = true
+		}
+	}
+	return visible, nil
+}
+
+// visibleResults keeps the results whose painting is in visible. Ranks stay as they were frozen.
+func visibleResults(results []model.ChallengeResult, visible map[string]bool) []model.ChallengeResult {
+	kept := []model.ChallengeResult{}
+	for _, result := range results {
+		if visible[result.PaintingRef().GetId()] {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+//POST /challenges
+func (h *Handler) CreateChallenge(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.roleOf(user).Includes(model.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admins only"})
+		return
+	}
+	var req challengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.StartAt >= req.EndAt || req.EndAt >= req.VoteEndAt {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "startAt < endAt < voteEndAt is required"})
+		return
+	}
+	challenge := model.Challenge{
+		ChallengeId: req.ChallengeId,
+		Title:       req.Title,
+		Theme:       req.Theme,
+		StartAt:     req.StartAt,
+		EndAt:       req.EndAt,
+		VoteEndAt:   req.VoteEndAt,
+		CreatedBy:   user.UserId,
+		Created:     util.GetUnixMilli(),
+	}
+	if err := h.Challenges.PutChallenge(&challenge); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "challenge already exists: " + req.ChallengeId})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, asChallengeResponse(challenge, util.GetUnixMilli()))
+}
+
+//GET /challenges
+func (h *Handler) ServeChallengeList(c *gin.Context) {
+	challenges, err := h.Challenges.ListChallenges()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	sort.Slice(challenges, func(i, j int) bool {
+		return challenges[i].StartAt > challenges[j].StartAt
+	})
+	now := util.GetUnixMilli()
+	results := []challengeResponse{}
+	for _, challenge := range challenges {
+		results = append(results, asChallengeResponse(challenge, now))
+	}
+	c.JSON(200, gin.H{
+		"results": results,
+	})
+}
+
+//GET /winners
+// archive of closed challenges with their top three entries, leaving out the ones the viewer may
+// not see.
+func (h *Handler) ServeChallengeWinners(c *gin.Context) {
+	challenges, err := h.Challenges.ListChallenges()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	sort.Slice(challenges, func(i, j int) bool {
+		return challenges[i].StartAt > challenges[j].StartAt
+	})
+	results := []model.Challenge{}
+	refs := []model.PaintingRef{}
+	for _, challenge := range challenges {
+		if !challenge.Closed {
+			continue
+		}
+		winners := []model.ChallengeResult{}
+		for _, result := range challenge.Results {
+			if result.Rank <= 3 {
+				winners = append(winners, result)
+				refs = append(refs, result.PaintingRef())
+			}
+		}
+		challenge.Results = winners
+		results = append(results, challenge)
+	}
+	visible, err := h.visiblePaintings(h.getViewer(c), refs)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range results {
+		results[i].Results = visibleResults(results[i].Results, visible)
+	}
+	c.JSON(200, gin.H{
+		"results": results,
+	})
+}
+
+//GET /challenges/:challengeId
+func (h *Handler) ServeChallenge(c *gin.Context) {
+	challenge, ok := h.getChallenge(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, asChallengeResponse(*challenge, util.GetUnixMilli()))
+}
+
+//GET /challenges/:challengeId/entries
+// entries whose painting was deleted or that the viewer may not see are left out.
+func (h *Handler) ServeChallengeEntries(c *gin.Context) {
+	entries, err := h.Challenges.ListChallengeEntries(c.Param("challengeId"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	visible, ok := h.visibleEntries(c, entries)
+	if !ok {
+		return
+	}
+	sort.Slice(visible, func(i, j int) bool {
+		return visible[i].Created < visible[j].Created
+	})
+	c.JSON(200, gin.H{
+		"results": visible,
+	})
+}
+
+// visibleEntries keeps the entries whose painting the viewer may see.
+func (h *Handler) visibleEntries(c *gin.Context, entries []model.ChallengeEntry) ([]model.ChallengeEntry, bool) {
+	refs := make([]model.PaintingRef, len(entries))
+	for i := range entries {
+		refs[i] = entries[i].PaintingRef()
+	}
+	visible, err := h.visiblePaintings(h.getViewer(c), refs)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	kept := []model.ChallengeEntry{}
+	for i := range entries {
+		if visible[refs[i].GetId()] {
+			kept = append(kept, entries[i])
+		}
+	}
+	return kept, true
+}
+
+//GET /challenges/:challengeId/leaderboard
+// running counts while the challenge is open, the frozen results once it is closed. Entries are
+// ranked among all of them, then the ones the viewer may not see are left out.
+func (h *Handler) ServeChallengeLeaderboard(c *gin.Context) {
+	challenge, ok := h.getChallenge(c)
+	if !ok {
+		return
+	}
+	results := challenge.Results
+	if !challenge.Closed {
+		entries, err := h.Challenges.ListChallengeEntries(challenge.ChallengeId)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		results = model.RankChallengeEntries(entries)
+	}
+	refs := make([]model.PaintingRef, len(results))
+	for i := range results {
+		refs[i] = results[i].PaintingRef()
+	}
+	visible, err := h.visiblePaintings(h.getViewer(c), refs)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"closed":  challenge.Closed,
+		"results": visibleResults(results, visible),
+	})
+}
+
+//POST /challenges/:challengeId/entries
+func (h *Handler) SubmitChallengeEntry(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	challenge, ok := h.getChallenge(c)
+	if !ok {
+		return
+	}
+	if challenge.State(util.GetUnixMilli()) != model.ChallengeOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "the challenge is not accepting entries"})
+		return
+	}
+	var ref model.PaintingRef
+	if err := c.ShouldBindJSON(&ref); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	painting, err := h.Paintings.GetPainting(ref.UserId, ref.Timestamp)
+	if err != nil || !isOwner(&user, &painting) || !painting.IsPublic() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only your own public paintings can be submitted"})
+		return
+	}
+	entry := model.ChallengeEntry{
+		ChallengeId:       challenge.ChallengeId,
+		EntryId:           painting.GetId(),
+		PaintingUserId:    painting.UserId,
+		PaintingTimestamp: painting.Timestamp,
+		Created:           util.GetUnixMilli(),
+	}
+	if err := h.Challenges.PutChallengeEntry(&entry); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "already submitted"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, entry)
+}
+
+//DELETE /challenges/:challengeId/entries/:entryId
+func (h *Handler) WithdrawChallengeEntry(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	challenge, ok := h.getChallenge(c)
+	if !ok {
+		return
+	}
+	if challenge.State(util.GetUnixMilli()) != model.ChallengeOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "entries can only be withdrawn while the challenge is open"})
+		return
+	}
+	entry, err := h.Challenges.GetChallengeEntry(challenge.ChallengeId, c.Param("entryId"))
+	if err != nil || entry.PaintingUserId != user.UserId {
+		c.JSON(404, gin.H{
+			"message": "error: not found id: " + c.Param("entryId"),
+		})
+		return
+	}
+	if err := h.Challenges.DeleteChallengeEntry(entry.ChallengeId, entry.EntryId); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
+
+//PUT /challenges/:challengeId/entries/:entryId/vote
+func (h *Handler) VoteChallengeEntry(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	challenge, ok := h.getChallenge(c)
+	if !ok {
+		return
+	}
+	if challenge.State(util.GetUnixMilli()) != model.ChallengeVoting {
+		c.JSON(http.StatusConflict, gin.H{"error": "voting is not open"})
+		return
+	}
+	entry, err := h.Challenges.GetChallengeEntry(challenge.ChallengeId, c.Param("entryId"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + c.Param("entryId"),
+		})
+		return
+	}
+	if entry.PaintingUserId == user.UserId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot vote for your own entry"})
+		return
+	}
+	vote := model.ChallengeVote{
+		EntryKey: model.ChallengeEntryKey(entry.ChallengeId, entry.EntryId),
+		UserId:   user.UserId,
+		Created:  util.GetUnixMilli(),
+	}
+	voted, err := h.Challenges.PutChallengeVote(entry.ChallengeId, entry.EntryId, vote)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !voted {
+		c.JSON(http.StatusConflict, gin.H{"error": "already voted"})
+		return
+	}
+	c.JSON(200, vote)
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+// DynamoDB items are limited to 400KB, which bounds how many references one collection can hold.
+const maxCollectionSize = 500
+
+type collectionRequest struct {
+	Title       *string            `json:"title"`
+	Description *string            `json:"description"`
+	Cover       *model.PaintingRef `json:"cover"`
+}
+
+// getOwnCollection loads the collection addressed by the URL and makes sure it belongs to the caller.
+func (h *Handler) getOwnCollection(c *gin.Context) (*model.Collection, error) {
+	userId := c.Param("userId")
+	collectionId := c.Param("collectionId")
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, err
+	}
+	if user.UserId != userId {
+		c.JSON(http.StatusForbidden, gin.H{"error": "stop it. we see you."})
+		return nil, errors.New("not the owner")
+	}
+	collection, err := h.Collections.GetCollection(userId, collectionId)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + collectionId,
+		})
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// canCollect decides whether user may put the painting into a collection: their own paintings in
+// any state, other painters' paintings only while they are public.
+func (h *Handler) canCollect(user *model.User, ref model.PaintingRef) error {
+	painting, err := h.Paintings.GetPainting(ref.UserId, ref.Timestamp)
+	if err != nil {
+		return errors.New("no such painting: " + ref.GetId())
+	}
+	if !isOwner(user, &painting) && !painting.IsPublic() {
+		return errors.New("no such painting: " + ref.GetId())
+	}
+	return nil
+}
+
+func (h *Handler) saveCollection(c *gin.Context, collection *model.Collection) {
+	collection.Updated = util.GetUnixMilli()
+	if err := h.Collections.PutCollection(collection); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, collection)
+}
+
+//POST /collections
+func (h *Handler) CreateCollection(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var req collectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Title == nil || *req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+	collection := model.Collection{
+		UserId:    user.UserId,
+		Title:     *req.Title,
+		Paintings: []model.PaintingRef{},
+	}
+	if req.Description != nil {
+		collection.Description = *req.Description
+	}
+	if req.Cover != nil {
+		if err := h.canCollect(&user, *req.Cover); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		collection.Cover = req.Cover
+	}
+	// the id is the creation time, so a second create within the same millisecond gets the next one
+	// instead of overwriting the first.
+	stampCollection(&collection)
+	for attempt := 1; ; attempt++ {
+		err = h.Collections.AddCollection(&collection)
+		if !errors.Is(err, domain.ErrConflict) || attempt == maxAddAttempts {
+			break
+		}
+		time.Sleep(time.Millisecond)
+		stampCollection(&collection)
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, collection)
+}
+
+// stampCollection gives a new collection its id from the current time.
+func stampCollection(collection *model.Collection) {
+	_, collection.CollectionId = util.GetDateAndTimestamp()
+	collection.Created = util.GetUnixMilli()
+	collection.Updated = collection.Created
+}
+
+//GET /users/:id/collections
+func (h *Handler) ServeUserCollections(c *gin.Context) {
+	collections, err := h.Collections.ListCollections(c.Param("id"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"results": collections,
+	})
+}
+
+//GET /collections/:userId/:collectionId
+// paintings that were deleted or that the viewer may not see are left out of the page.
+func (h *Handler) ServeCollection(c *gin.Context) {
+	collectionId := c.Param("collectionId")
+	collection, err := h.Collections.GetCollection(c.Param("userId"), collectionId)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + collectionId,
+		})
+		return
+	}
+	refs := collection.Paintings
+	if collection.Cover != nil {
+		refs = append([]model.PaintingRef{*collection.Cover}, refs...)
+	}
+	found, err := h.Paintings.GetPaintings(refs)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	viewer := h.getViewer(c)
+	paintings := []model.Painting{}
+	for _, ref := range collection.Paintings {
+		if painting, ok := found[ref.GetId()]; ok && h.canView(viewer, &painting) {
+			paintings = append(paintings, painting)
+		}
+	}
+	var cover *model.Painting
+	if collection.Cover != nil {
+		if painting, ok := found[collection.Cover.GetId()]; ok && h.canView(viewer, &painting) {
+			cover = &painting
+		}
+	}
+	c.JSON(200, gin.H{
+		"collection": collection,
+		"cover":      cover,
+		"paintings":  paintings,
+	})
+}
+
+//PATCH /collections/:userId/:collectionId
+func (h *Handler) UpdateCollection(c *gin.Context) {
+	collection, err := h.getOwnCollection(c)
+	if err != nil {
+		return
+	}
+	var req collectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Title != nil {
+		if *req.Title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+			return
+		}
+		collection.Title = *req.Title
+	}
+	if req.Description != nil {
+		collection.Description = *req.Description
+	}
+	if req.Cover != nil {
+		user := model.User{UserId: collection.UserId}
+		if err := h.canCollect(&user, *req.Cover); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		collection.Cover = req.Cover
+	}
+	h.saveCollection(c, collection)
+}
+
+//DELETE /collections/:userId/:collectionId
+func (h *Handler) DeleteCollection(c *gin.Context) {
+	collection, err := h.getOwnCollection(c)
+	if err != nil {
+		return
+	}
+	if err := h.Collections.DeleteCollection(collection.UserId, collection.CollectionId); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
+
+//POST /collections/:userId/:collectionId/paintings
+func (h *Handler) AddCollectionPainting(c *gin.Context) {
+	collection, err := h.getOwnCollection(c)
+	if err != nil {
+		return
+	}
+	var ref model.PaintingRef
+	if err := c.ShouldBindJSON(&ref); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if collection.IndexOf(ref) >= 0 {
+		c.JSON(200, collection)
+		return
+	}
+	if len(collection.Paintings) >= maxCollectionSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "collection is full"})
+		return
+	}
+	user := model.User{UserId: collection.UserId}
+	if err := h.canCollect(&user, ref); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	collection.Paintings = append(collection.Paintings, ref)
+	h.saveCollection(c, collection)
+}
+
+//DELETE /collections/:userId/:collectionId/paintings/:id/:timestamp
+// works for deleted paintings too, so owners can clean up dangling references.
+func (h *Handler) RemoveCollectionPainting(c *gin.Context) {
+	collection, err := h.getOwnCollection(c)
+	if err != nil {
+		return
+	}
+	ref := model.PaintingRef{UserId: c.Param("id"), Timestamp: c.Param("timestamp")}
+	if i := collection.IndexOf(ref); i >= 0 {
+		collection.Paintings = append(collection.Paintings[:i], collection.Paintings[i+1:]...)
+	}
+	if collection.Cover != nil && *collection.Cover == ref {
+		collection.Cover = nil
+	}
+	h.saveCollection(c, collection)
+}
+
+type collectionOrderRequest struct {
+	Paintings []model.PaintingRef `json:"paintings"`
+}
+
+//PUT /collections/:userId/:collectionId/order
+// the body has to list exactly the paintings already in the collection, in their new order.
+func (h *Handler) ReorderCollection(c *gin.Context) {
+	collection, err := h.getOwnCollection(c)
+	if err != nil {
+		return
+	}
+	var req collectionOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	seen := map[model.PaintingRef]bool{}
+	for _, ref := range req.Paintings {
+		if seen[ref] || collection.IndexOf(ref) < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "not in the collection or listed twice: " + ref.GetId()})
+			return
+		}
+		seen[ref] = true
+	}
+	if len(req.Paintings) != len(collection.Paintings) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "every painting in the collection has to be listed"})
+		return
+	}
+	collection.Paintings = req.Paintings
+	h.saveCollection(c, collection)
+}
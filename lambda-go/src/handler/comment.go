@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+const maxCommentLength = 1000
+
+type commentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+//GET /wcs/:id/:timestamp/comments
+func (h *Handler) ServeComments(c *gin.Context) {
+	painting, ok := h.getVisiblePainting(c, h.getViewer(c))
+	if !ok {
+		return
+	}
+	comments, err := h.Reactions.ListComments(painting.GetId())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	results := []model.Comment{}
+	for _, comment := range comments {
+		if !comment.Hidden {
+			results = append(results, comment)
+		}
+	}
+	c.JSON(200, gin.H{
+		"results": results,
+	})
+}
+
+//POST /wcs/:id/:timestamp/comments
+func (h *Handler) PostComment(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	painting, ok := h.getVisiblePainting(c, &user)
+	if !ok {
+		return
+	}
+	if !h.checkNotBlocked(c, user.UserId, painting.UserId) {
+		return
+	}
+	var req commentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if utf8.RuneCountInString(req.Body) > maxCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "comment is too long"})
+		return
+	}
+	_, timestamp := util.GetDateAndTimestamp()
+	comment := model.Comment{
+		PaintingId:        painting.GetId(),
+		CommentId:         timestamp + "-" + user.UserId,
+		UserId:            user.UserId,
+		PaintingUserId:    painting.UserId,
+		PaintingTimestamp: painting.Timestamp,
+		Body:              req.Body,
+		Created:           util.GetUnixMilli(),
+	}
+	if err := h.Reactions.PutComment(&comment); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, comment)
+}
+
+//DELETE /wcs/:id/:timestamp/comments/:commentId
+// the commenter and the painter can both delete a comment.
+func (h *Handler) DeleteComment(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	paintingId := (&model.Painting{UserId: c.Param("id"), Timestamp: c.Param("timestamp")}).GetId()
+	comment, err := h.Reactions.GetComment(paintingId, c.Param("commentId"))
+	if err != nil || (comment.UserId != user.UserId && comment.PaintingUserId != user.UserId) {
+		c.JSON(404, gin.H{
+			"message": "error: not found id: " + c.Param("commentId"),
+		})
+		return
+	}
+	if err := h.Reactions.DeleteComment(&comment); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
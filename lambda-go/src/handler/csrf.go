@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/session"
+)
+
+const csrfHeader = "X-CSRF-Token"
+
+// isFrontOrigin checks Origin, or Referer when a browser leaves Origin out, against the front end.
+func (h *Handler) isFrontOrigin(r *http.Request) bool {
+	front := h.Config.FrontUrl
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return origin == front
+	}
+	referer, err := url.Parse(r.Header.Get("Referer"))
+	if err != nil || referer.Host == "" {
+		return false
+	}
+	return referer.Scheme+"://"+referer.Host == front
+}
+
+// VerifyCsrf guards cookie authenticated mutating routes. The request has to come from the front end
+// and carry the session's token in the X-CSRF-Token header. Requests with a bearer token are let
+// through: GetUser then ignores the cookie, and browsers cannot attach the header cross-site since
+// CORS does not allow it.
+func (h *Handler) VerifyCsrf(c *gin.Context) {
+	if _, ok := bearerToken(c.Request); ok {
+		c.Next()
+		return
+	}
+	if !h.isFrontOrigin(c.Request) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "cross-site request"})
+		return
+	}
+	sess := h.Sessions.GetSession(c.Request)
+	if !session.VerifyCsrfToken(sess, c.GetHeader(csrfHeader)) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "invalid csrf token"})
+		return
+	}
+	c.Next()
+}
+
+//GET /csrf
+// issues the token the SPA sends back in X-CSRF-Token. Fetch it again after logging in, since
+// logging in starts a new session.
+func (h *Handler) ServeCsrfToken(c *gin.Context) {
+	sess := h.Sessions.GetSession(c.Request)
+	if sess.SessionId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no session"})
+		return
+	}
+	token, err := h.Sessions.CsrfToken(&sess)
+	// logged out since the session was read, so it is not brought back.
+	if errors.Is(err, domain.ErrConflict) {
+		h.Sessions.ClearSession(c.Writer, c.Request)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no session"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"token": token,
+	})
+}
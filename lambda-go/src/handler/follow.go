@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+//PUT /users/:id/follow
+func (h *Handler) Follow(c *gin.Context) {
+	followeeId := c.Param("id")
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.UserId == followeeId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot follow yourself"})
+		return
+	}
+	if _, err := h.Users.GetUser(followeeId); err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + followeeId,
+		})
+		return
+	}
+	if !h.checkNotBlocked(c, user.UserId, followeeId) {
+		return
+	}
+	follow := model.Follow{
+		UserId:     user.UserId,
+		FolloweeId: followeeId,
+		Created:    util.GetUnixMilli(),
+	}
+	if err := h.Follows.PutFollow(follow); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, follow)
+}
+
+//DELETE /users/:id/follow
+func (h *Handler) Unfollow(c *gin.Context) {
+	followeeId := c.Param("id")
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Follows.DeleteFollow(user.UserId, followeeId); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
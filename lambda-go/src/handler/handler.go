@@ -2,11 +2,53 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/auth"
+	"github.com/hirosato/wcs/domain"
 	"github.com/hirosato/wcs/env"
+	"github.com/hirosato/wcs/session"
 )
 
-func AddCorsHeader(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", env.GetFrontUrl())
+// Services are everything the handlers read and write through. main wires the real ones.
+type Services struct {
+	Config        env.Config
+	Auth          *auth.Registry
+	Sessions      *session.Manager
+	SessionStore  domain.SessionStore
+	Paintings     domain.PaintingRepository
+	Users         domain.UserRepository
+	Follows       domain.FollowRepository
+	Notifications domain.NotificationRepository
+	Reactions     domain.ReactionRepository
+	Collections   domain.CollectionRepository
+	Challenges    domain.ChallengeRepository
+	Identities    domain.IdentityRepository
+	Tokens        domain.TokenRepository
+	Audit         domain.AuditLog
+	Reports       domain.ReportRepository
+	Relations     domain.RelationRepository
+	Exports       domain.ExportRepository
+	Idempotency   domain.IdempotencyStore
+	Search        domain.SearchIndex
+	Pigments      domain.PigmentCatalog
+	LocalFiles    domain.LocalFileRepository
+	Blobs         domain.BlobStore
+	ExportFiles   domain.ExportStore
+	Cdn           domain.CdnRepository
+}
+
+// Handler serves the API routes with its services.
+type Handler struct {
+	Services
+	views *viewCounter
+}
+
+func New(services Services) *Handler {
+	return &Handler{Services: services, views: newViewCounter()}
+}
+
+func (h *Handler) AddCorsHeader(c *gin.Context) {
+	c.Header("Access-Control-Allow-Origin", h.Config.FrontUrl)
 	c.Header("Access-Control-Allow-Credentials", "true")
-	c.Header("Access-Control-Allow-Headers", "content-type")
+	c.Header("Access-Control-Allow-Headers", "content-type, x-csrf-token, idempotency-key, if-match")
+	c.Header("Access-Control-Expose-Headers", "etag")
 }
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// recordingWriter keeps a copy of the response body for the idempotency record.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// requestFingerprint tells a retry of the request apart from another request with the same key.
+func requestFingerprint(r *http.Request, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// Idempotent answers a retried request that carries the Idempotency-Key header of an earlier one
+// with the earlier response, instead of running it again. The same key with another method, path
+// or body is a conflict. Requests without the header or without a user run as usual, and 5xx
+// responses are not kept, so those requests can be retried with the same key.
+func (h *Handler) Idempotent(c *gin.Context) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+		return
+	}
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		return
+	}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	now := time.Now().Unix()
+	record := model.IdempotencyRecord{
+		IdempotencyKey: user.UserId + "#" + key,
+		Fingerprint:    requestFingerprint(c.Request, body),
+		Created:        now,
+		ExpiresAt:      now + model.IdempotencyLifetime,
+	}
+	reserved, err := h.Idempotency.ReserveIdempotencyKey(record, now)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !reserved {
+		h.replay(c, record)
+		return
+	}
+
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	if writer.Status() >= 500 {
+		if err := h.Idempotency.ReleaseIdempotencyKey(record.IdempotencyKey); err != nil {
+			log.Println(err.Error())
+		}
+		return
+	}
+	record.Status = writer.Status()
+	record.Body = writer.body.Bytes()
+	if err := h.Idempotency.CompleteIdempotencyRecord(record); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// replay answers with the response kept for the key. Every response of the API is JSON, so only
+// the status and body are kept.
+func (h *Handler) replay(c *gin.Context, record model.IdempotencyRecord) {
+	stored, err := h.Idempotency.GetIdempotencyRecord(record.IdempotencyKey)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stored.Fingerprint != record.Fingerprint {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "Idempotency-Key was used for another request"})
+		return
+	}
+	if stored.Status == 0 {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is in progress"})
+		return
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(stored.Status, "application/json; charset=utf-8", stored.Body)
+	c.Abort()
+}
@@ -6,94 +6,296 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/hirosato/wcs/db"
-	"github.com/hirosato/wcs/env"
+	"github.com/hirosato/wcs/domain"
 	"github.com/hirosato/wcs/model"
-	"github.com/hirosato/wcs/session"
-	"github.com/hirosato/wcs/twitter"
+	"github.com/hirosato/wcs/util"
 )
 
-func Login(c *gin.Context) {
-	alreadySignedInOnTwitter, redirectUrl, _ := twitter.ServeSignin(c.Writer, c.Request)
-	if alreadySignedInOnTwitter {
-		sess := session.GetSession(c.Request)
-		twitterUser, _ := twitter.GetTwitterUserInfo(c.Writer, c.Request)
-		if twitterUser == nil {
-			c.JSON(404, gin.H{
-				"message": "404",
-			})
+// callbackURL is where the provider sends the browser back to. Twitter keeps the URL it was
+// registered with.
+func (h *Handler) callbackURL(provider string) string {
+	if provider == "twitter" {
+		return h.Config.ApiUrl + "/twitter/callback"
+	}
+	return h.Config.ApiUrl + "/auth/" + provider + "/callback"
+}
+
+//GET /twitter/signin
+func (h *Handler) Login(c *gin.Context) {
+	h.signin(c, "twitter")
+}
+
+//GET /twitter/callback
+func (h *Handler) Callback(c *gin.Context) {
+	h.completeSignin(c, "twitter")
+}
+
+//GET /auth/:provider/signin
+// signs in, or links the account to the current user when already logged in.
+func (h *Handler) Signin(c *gin.Context) {
+	h.signin(c, c.Param("provider"))
+}
+
+//GET /auth/:provider/callback
+func (h *Handler) AuthCallback(c *gin.Context) {
+	h.completeSignin(c, c.Param("provider"))
+}
+
+//GET /auth
+func (h *Handler) ServeAuthProviders(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"results": h.Auth.Names(),
+	})
+}
+
+func (h *Handler) signin(c *gin.Context, name string) {
+	provider, ok := h.Auth.Get(name)
+	if !ok {
+		c.JSON(404, gin.H{"message": "error: unknown provider: " + name})
+		return
+	}
+	sess := h.Sessions.GetSession(c.Request)
+	redirectUrl, err := provider.Begin(&sess, h.callbackURL(name))
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Sessions.SetSession(c.Writer, c.Request, sess); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	http.Redirect(c.Writer, c.Request, redirectUrl, http.StatusFound)
+}
+
+func (h *Handler) completeSignin(c *gin.Context, name string) {
+	provider, ok := h.Auth.Get(name)
+	if !ok {
+		c.JSON(404, gin.H{"message": "error: unknown provider: " + name})
+		return
+	}
+	sess := h.Sessions.GetSession(c.Request)
+	identity, err := provider.Complete(&sess, c.Request, h.callbackURL(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := h.resolveUser(sess, identity)
+	if err != nil {
+		if err == errIdentityTaken {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		} else {
-			user := twitterUser.AsUser()
-			db.PutUser(user)
-			sess.UserId = user.UserId
-			session.SetSession(c.Writer, c.Request, sess)
-
-			c.JSON(200, gin.H{
-				"message": "pong " + user.UserId + " " + user.DisplayName + " " + user.AvatarURL + " ",
-			})
+			c.JSON(500, gin.H{"error": err.Error()})
 		}
-	} else {
-		http.Redirect(c.Writer, c.Request, redirectUrl, http.StatusFound)
+		return
 	}
+	if user.Suspended {
+		c.JSON(http.StatusForbidden, gin.H{"error": errSuspended.Error()})
+		return
+	}
+	sess.UserId = user.UserId
+	sess.OAuthProvider, sess.TempToken, sess.TempSecret, sess.OAuthState, sess.OAuthVerifier = "", "", "", "", ""
+	// the pre-login session id may have been planted by someone else, so never keep it.
+	if err := h.Sessions.RotateSession(c.Writer, c.Request, sess); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	http.Redirect(c.Writer, c.Request, h.Config.FrontUrl, http.StatusFound)
 }
 
-func Callback(c *gin.Context) {
-	twitter.ServeOAuthCallback(c.Writer, c.Request)
-	twitterUser, _ := twitter.GetTwitterUserInfo(c.Writer, c.Request)
-	if twitterUser == nil {
-		c.JSON(404, gin.H{
-			"message": "404",
-		})
+var errIdentityTaken = errors.New("this account is already linked to another user")
+
+// resolveUser finds the user an external account signs in as. An account seen for the first time
+// is linked to the logged in user if there is one, to the user Twitter sign-in created before
+// identities existed, whose id is the Twitter id, or else to a new user.
+func (h *Handler) resolveUser(sess model.Session, identity model.Identity) (model.User, error) {
+	linked, ok, err := h.Identities.GetIdentity(identity.Provider, identity.Subject)
+	if err != nil {
+		return model.User{}, err
+	}
+	if ok {
+		if sess.IsLoggedIn() && sess.UserId != linked.UserId {
+			return model.User{}, errIdentityTaken
+		}
+		user, err := h.Users.GetUser(linked.UserId)
+		if err != nil {
+			return model.User{}, err
+		}
+		linked.DisplayName = identity.DisplayName
+		linked.AvatarURL = identity.AvatarURL
+		if err := h.Identities.PutIdentity(linked); err != nil {
+			return model.User{}, err
+		}
+		if !sess.IsLoggedIn() {
+			user.RefreshFromIdentity(identity)
+			if err := h.Users.RefreshUserFromProvider(user); err != nil {
+				return model.User{}, err
+			}
+		}
+		return user, nil
+	}
+
+	var user model.User
+	isNew := false
+	if sess.IsLoggedIn() {
+		user, err = h.Users.GetUser(sess.UserId)
+	} else if identity.Provider == "twitter" {
+		user, err = h.Users.GetUser(identity.Subject)
+		if errors.Is(err, domain.ErrNotFound) {
+			isNew = true
+		}
 	} else {
-		sess := session.GetSession(c.Request)
-		user := twitterUser.AsUser()
-		db.PutUser(user)
-		sess.UserId = user.UserId
-		session.SetSession(c.Writer, c.Request, sess)
+		isNew = true
+	}
+	if err != nil && !isNew {
+		return model.User{}, err
+	}
+	if isNew {
+		user = model.User{
+			UserId:      util.NewId(),
+			DisplayName: identity.DisplayName,
+			AvatarURL:   identity.AvatarURL,
+		}
+	}
+	identity.UserId = user.UserId
+	identity.Created = util.GetUnixMilli()
+	if err := h.Identities.LinkIdentity(identity); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			return model.User{}, errIdentityTaken
+		}
+		return model.User{}, err
+	}
+	if isNew {
+		if _, err := h.Users.PutUser(user); err != nil {
+			return model.User{}, err
+		}
+	}
+	return user, nil
+}
 
-		http.Redirect(c.Writer, c.Request, env.GetFrontUrl(), http.StatusFound)
+//GET /identities
+func (h *Handler) ServeIdentities(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	identities, err := h.Identities.ListIdentities(user.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
+	c.JSON(200, gin.H{
+		"results": identities,
+	})
+}
 
+//DELETE /identities/:provider
+// the last identity cannot be unlinked, or the user could never sign in again.
+func (h *Handler) UnlinkIdentity(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	identities, err := h.Identities.ListIdentities(user.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, identity := range identities {
+		if identity.Provider != c.Param("provider") {
+			continue
+		}
+		if len(identities) == 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": "the last sign-in method cannot be removed"})
+			return
+		}
+		if err := h.Identities.DeleteIdentity(identity.Provider, identity.Subject); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(204)
+		return
+	}
+	c.JSON(404, gin.H{"message": "error: not linked: " + c.Param("provider")})
 }
 
-func ServeGetUser(c *gin.Context) {
-	sess := session.GetSession(c.Request)
-	if !sess.IsLoggedIn() {
-		c.JSON(400, gin.H{
-			"isLoggedIn": sess.IsLoggedIn(),
-		})
+func (h *Handler) ServeGetUser(c *gin.Context) {
+	if _, ok := bearerToken(c.Request); ok {
+		user, err := h.GetUser(c.Request)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, user)
 		return
 	}
-	if sess.UserId == "" {
+	sess := h.Sessions.GetSession(c.Request)
+	if !sess.IsLoggedIn() {
 		c.JSON(400, gin.H{
-			"isLoggedIn": false,
+			"isLoggedIn": sess.IsLoggedIn(),
 		})
-		log.Printf("session.UserId is not set. session: %s", sess.SessionId)
-		db.PutSession(model.Session{SessionId: sess.SessionId})
 		return
 	}
-	user, err := db.GetUser(sess.UserId)
+	user, err := h.Users.GetUser(sess.UserId)
 	if err != nil {
 		c.JSON(500, gin.H{
 			"isLoggedIn": sess.IsLoggedIn(),
 		})
-		db.PutSession(model.Session{SessionId: sess.SessionId})
+		h.SessionStore.PutSession(model.Session{SessionId: sess.SessionId})
 		return
 	}
 	c.JSON(200, user)
 }
 
-func GetUser(r *http.Request) (user model.User, err error) {
-	sess := session.GetSession(r)
+var errSuspended = errors.New("this account is suspended")
+
+// GetUser returns the user the request is authenticated as: by the personal access token in the
+// Authorization header if there is one, otherwise by the session cookie. Suspended users are
+// refused.
+func (h *Handler) GetUser(r *http.Request) (model.User, error) {
+	user, err := h.getUser(r)
+	if err == nil && user.Suspended {
+		return model.User{}, errSuspended
+	}
+	return user, err
+}
+
+func (h *Handler) getUser(r *http.Request) (user model.User, err error) {
+	if raw, ok := bearerToken(r); ok {
+		return h.getTokenUser(r, raw)
+	}
+	sess := h.Sessions.GetSession(r)
 	if !sess.IsLoggedIn() {
 		return model.User{}, errors.New("not logged in")
 	}
-	if sess.UserId == "" {
-		return model.User{}, errors.New("no user id")
-	}
-	user, err = db.GetUser(sess.UserId)
+	user, err = h.Users.GetUser(sess.UserId)
 	if err != nil {
 		return user, err
 	}
 	return user, nil
 }
+
+//POST /logout
+func (h *Handler) Logout(c *gin.Context) {
+	if err := h.Sessions.ClearSession(c.Writer, c.Request); err != nil {
+		log.Printf("failed to delete session: %s", err.Error())
+	}
+	c.Status(204)
+}
+
+//POST /logout/all
+// logs the user out on every device, including this one.
+func (h *Handler) LogoutAll(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Sessions.ClearUserSessions(user.UserId); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	h.Sessions.ClearSession(c.Writer, c.Request)
+	c.Status(204)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+)
+
+const notificationPageSize = 20
+
+//GET /notifications?before=
+// notifications caused by muted or blocked users are skipped.
+func (h *Handler) ServeNotifications(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	excluded, err := h.getExcludedAuthors(&user)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	// skipped items would leave the page short, so keep reading until it is full.
+	cursor := c.Query("before")
+	result := []model.Notification{}
+	more := true
+	for more && len(result) < notificationPageSize {
+		page, err := h.Notifications.ListNotifications(user.UserId, cursor, notificationPageSize)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		more = len(page) == notificationPageSize
+		for i := range page {
+			cursor = page[i].NotificationId
+			if excluded[page[i].ActorId] {
+				continue
+			}
+			result = append(result, page[i])
+			if len(result) == notificationPageSize {
+				more = more || i < len(page)-1
+				break
+			}
+		}
+	}
+	next := ""
+	if more {
+		next = cursor
+	}
+	c.JSON(200, gin.H{
+		"results": result,
+		"next":    next,
+	})
+}
@@ -1,47 +1,89 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/hirosato/wcs/db"
 	"github.com/hirosato/wcs/domain"
-	"github.com/hirosato/wcs/env"
-	"github.com/hirosato/wcs/file"
 	"github.com/hirosato/wcs/model"
 	"github.com/hirosato/wcs/util"
 )
 
-var filerepo domain.LocalFileRepository = file.NewLocalFileRepository()
-var s3repo domain.S3Repository = file.NewS3RepositoryImpl()
+const galleryPageSize = 10
 
-func parseBody(c *gin.Context) (*model.Painting, error) {
+// how often a painting or collection is given a new key when the one it got from the current time
+// is taken.
+const maxAddAttempts = 3
+
+// submitRequest is what the painter sets on a new painting. Counters, scores and moderation
+// fields are never taken from the client. The front end declares the images it is about to upload.
+type submitRequest struct {
+	Title         string           `json:"title"`
+	Description   string           `json:"description"`
+	Visibility    model.Visibility `json:"visibility"`
+	Draft         bool             `json:"draft"`
+	HasImageCover bool             `json:"has_image_cover"`
+	HasImage1     bool             `json:"has_image1"`
+	HasImage2     bool             `json:"has_image2"`
+	HasImage3     bool             `json:"has_image3"`
+	HasImage4     bool             `json:"has_image4"`
+}
+
+func (h *Handler) parseBody(c *gin.Context) (*model.Painting, error) {
 	var user model.User
 	var err error
-	if user, err = GetUser(c.Request); err != nil {
+	if user, err = h.GetUser(c.Request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return &model.Painting{}, err
 	}
-	var painting model.Painting
-	if err := c.ShouldBindJSON(&painting); err != nil {
+	var req submitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return &model.Painting{}, err
 	}
-	painting.UserId = user.UserId
-	painting.Date, painting.Timestamp = util.GetDateAndTimestamp()
-	if env.IsLocal {
-		painting.Date = "20210811"
-		painting.Timestamp = "20210811150726359"
+	painting := model.Painting{
+		Title:         req.Title,
+		Description:   req.Description,
+		Visibility:    req.Visibility,
+		Draft:         req.Draft,
+		HasImageCover: req.HasImageCover,
+		HasImage1:     req.HasImage1,
+		HasImage2:     req.HasImage2,
+		HasImage3:     req.HasImage3,
+		HasImage4:     req.HasImage4,
+	}
+	if painting.Visibility == "" {
+		painting.Visibility = model.VisibilityPublic
+	}
+	if !painting.Visibility.IsValid() {
+		err = errors.New("unknown visibility: " + string(painting.Visibility))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return &model.Painting{}, err
 	}
+	painting.UserId = user.UserId
+	stampPainting(&painting)
 	return &painting, nil
 }
 
-func parseImageBody(c *gin.Context) (*model.PaintingImage, error) {
+// stampPainting gives a new painting its key and id from the current time.
+func stampPainting(painting *model.Painting) {
+	painting.Date, painting.Timestamp = util.GetDateAndTimestamp()
+	painting.Created = util.GetUnixMilli()
+	painting.Updated = painting.Created
+	painting.Version = 1
+	painting.PaintingId = util.NewSortableId(painting.Created)
+}
+
+func (h *Handler) parseImageBody(c *gin.Context) (*model.PaintingImage, error) {
 	id := c.Param("id")
 	timestamp := c.Param("timestamp")
-	var err error
-	if _, err = GetUser(c.Request); err != nil {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return &model.PaintingImage{}, err
 	}
@@ -50,25 +92,42 @@ func parseImageBody(c *gin.Context) (*model.PaintingImage, error) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return &model.PaintingImage{}, err
 	}
-	if id != paintingImages.UserId || timestamp != paintingImages.Timestamp {
+	if id != user.UserId || id != paintingImages.UserId || timestamp != paintingImages.Timestamp {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "stop it. we see you."})
+		return &model.PaintingImage{}, errors.New("painting mismatch")
 	}
 	return &paintingImages, nil
 }
 
-func Submit(c *gin.Context) {
+func (h *Handler) Submit(c *gin.Context) {
 	log.Printf("EVENT: Submit start")
-	painting, err := parseBody(c)
+	painting, err := h.parseBody(c)
 
 	if err != nil {
 		return
 	}
 
 	log.Printf("EVENT: Submitting %s", painting.GetId())
-	err = db.PutPainting(painting)
+	// the key is the submit time, so a second submit within the same millisecond gets the next one
+	// instead of overwriting the first.
+	for attempt := 1; ; attempt++ {
+		err = h.Paintings.AddPainting(painting)
+		if !errors.Is(err, domain.ErrConflict) || attempt == maxAddAttempts {
+			break
+		}
+		time.Sleep(time.Millisecond)
+		stampPainting(painting)
+	}
 	log.Printf("EVENT: Submitting %s, dynamo done", painting.GetId())
-	db.PutEsPainting(painting)
-	log.Printf("EVENT: Submitting %s, es done", painting.GetId())
+	if err == nil && painting.IsPublic() {
+		h.Search.PutEsPainting(painting)
+		log.Printf("EVENT: Submitting %s, es done", painting.GetId())
+	}
+	if err == nil && painting.IsVisibleToFollowers() {
+		if err := h.Notifications.NotifyFollowers(painting); err != nil {
+			log.Println(err.Error())
+		}
+	}
 
 	if err != nil {
 		c.JSON(500, painting)
@@ -80,89 +139,314 @@ func Submit(c *gin.Context) {
 }
 
 func ServeSubmitPreflight(c *gin.Context) {
-	c.Header("Access-Control-Allow-Headers", "content-type")
-	c.Header("Access-Control-Allow-Methods", "GET, POST, PATCH")
+	c.Header("Access-Control-Allow-Headers", "content-type, x-csrf-token, idempotency-key, if-match")
+	c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
 	c.Status(200)
 }
 
-func uploadFile(userId string, timestamp string, base64image string, imageKind model.ImageKind) error {
-	filename, err := filerepo.Add(userId, timestamp, base64image, imageKind)
+func (h *Handler) uploadFile(userId string, timestamp string, base64image string, imageKind model.ImageKind) error {
+	filename, err := h.LocalFiles.Add(userId, timestamp, base64image, imageKind)
 	if err != nil {
 		return err
 	}
-	err = s3repo.Add(filename, userId, timestamp, imageKind)
+	err = h.Blobs.Add(filename, userId, timestamp, imageKind)
 	if err != nil {
-		filerepo.Remove(filename)
+		h.LocalFiles.Remove(filename)
 		return err
 	}
-	filerepo.Remove(filename)
+	h.LocalFiles.Remove(filename)
 	return nil
 }
 
-func ServePaintingList(c *gin.Context) {
-	c.JSON(200, db.ListWaterColorSite(0))
+//GET /wcs?trending=today|week|all&offset=
+// newest first unless a trending mode is given. Paintings by users the viewer muted or blocked are
+// left out by ES itself, so offsets stay consistent.
+func (h *Handler) ServePaintingList(c *gin.Context) {
+	trending := c.Query("trending")
+	if !domain.IsTrendingMode(trending) {
+		c.JSON(400, gin.H{
+			"message": "bad request",
+		})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(400, gin.H{
+			"message": "bad request",
+		})
+		return
+	}
+	excluded := []string{}
+	if viewer := h.getViewer(c); viewer != nil {
+		if excluded, err = h.Relations.ListExcludedAuthors(viewer.UserId); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(200, h.Search.ListWaterColorSite(offset, trending, excluded))
 }
 
 //wcs/:id
-func ServeUserPainting(c *gin.Context) {
+func (h *Handler) ServeUserPainting(c *gin.Context) {
+	id := c.Param("id")
+	viewer := h.getViewer(c)
+	following := false
+	if viewer != nil && viewer.UserId != id {
+		following, _ = h.Follows.IsFollowing(viewer.UserId, id)
+	}
+	result, next, err := readGalleryPage(c.Query("before"), func(before string) ([]model.Painting, error) {
+		return h.Paintings.ListUserPaintings(id, before, galleryPageSize)
+	}, func(painting *model.Painting) bool {
+		return isInGallery(viewer, following, painting)
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"message": "error: " + err.Error() + " id: " + id,
+		})
+		return
+	}
 	c.JSON(200, gin.H{
-		"message": "/wcs/:id",
+		"results": result,
+		"next":    next,
 	})
 }
 
-//PATCH /wcs
-func PatchPaintingImage(c *gin.Context) {
+// readGalleryPage reads pages older than cursor with list until galleryPageSize of them pass keep.
+// Items the viewer may not see are skipped, so a single read may not fill the page. next is the
+// cursor of the following page, or empty on the last one.
+func readGalleryPage(cursor string, list func(before string) ([]model.Painting, error), keep func(painting *model.Painting) bool) (result []model.Painting, next string, err error) {
+	result = []model.Painting{}
+	more := true
+	for more && len(result) < galleryPageSize {
+		page, err := list(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		more = len(page) == galleryPageSize
+		for i := range page {
+			cursor = page[i].Timestamp
+			if !keep(&page[i]) {
+				continue
+			}
+			result = append(result, page[i])
+			if len(result) == galleryPageSize {
+				more = more || i < len(page)-1
+				break
+			}
+		}
+	}
+	if more {
+		next = cursor
+	}
+	return result, next, nil
+}
+
+// getOwnPainting loads the painting addressed by the URL for an update. It makes sure the painting
+// belongs to the caller and that If-Match names its current version.
+func (h *Handler) getOwnPainting(c *gin.Context) (*model.Painting, error) {
+	id := c.Param("id")
+	timestamp := c.Param("timestamp")
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, err
+	}
+	if user.UserId != id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "stop it. we see you."})
+		return nil, errors.New("not the owner")
+	}
+	painting, err := h.Paintings.GetPainting(id, timestamp)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + id,
+		})
+		return nil, err
+	}
+	if !checkIfMatch(c, &painting) {
+		return nil, errors.New("stale version")
+	}
+	return &painting, nil
+}
+
+type paintingUpdate struct {
+	Title       *string           `json:"title"`
+	Description *string           `json:"description"`
+	Visibility  *model.Visibility `json:"visibility"`
+}
+
+//PATCH /wcs/:id/:timestamp
+func (h *Handler) UpdatePainting(c *gin.Context) {
+	painting, err := h.getOwnPainting(c)
+	if err != nil {
+		return
+	}
+	var update paintingUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if update.Visibility != nil && !update.Visibility.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown visibility: " + string(*update.Visibility)})
+		return
+	}
+	if update.Title != nil {
+		painting.Title = *update.Title
+	}
+	if update.Description != nil {
+		painting.Description = *update.Description
+	}
+	if update.Visibility != nil {
+		painting.Visibility = *update.Visibility
+	}
+	h.savePainting(c, painting)
+}
+
+type publishRequest struct {
+	PublishAt uint64 `json:"publish_at"`
+}
+
+//POST /wcs/:id/:timestamp/publish
+// publishes the draft now, or at publish_at when the body carries a future time.
+func (h *Handler) PublishPainting(c *gin.Context) {
+	painting, err := h.getOwnPainting(c)
+	if err != nil {
+		return
+	}
+	var req publishRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !painting.Draft || painting.Schedule == model.SchedulePublishing {
+		c.JSON(http.StatusConflict, gin.H{"error": "already published"})
+		return
+	}
+	now := util.GetUnixMilli()
+	if req.PublishAt > now {
+		painting.PublishAt = req.PublishAt
+		painting.Schedule = model.ScheduleWaiting
+		h.savePainting(c, painting)
+		return
+	}
+	painting.Draft = false
+	painting.PublishAt = now
+	painting.Schedule = ""
+	if h.savePainting(c, painting) && painting.IsVisibleToFollowers() {
+		if err := h.Notifications.NotifyFollowers(painting); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+//DELETE /wcs/:id/:timestamp/publish
+// cancels a scheduled publish and keeps the painting as a draft.
+func (h *Handler) CancelPublishPainting(c *gin.Context) {
+	painting, err := h.getOwnPainting(c)
+	if err != nil {
+		return
+	}
+	if painting.Schedule != model.ScheduleWaiting {
+		c.JSON(http.StatusConflict, gin.H{"error": "not scheduled"})
+		return
+	}
+	if err := h.Paintings.CancelSchedule(painting); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "the painting was changed"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.Header("ETag", paintingETag(painting))
+	c.JSON(200, painting)
+}
+
+// savePainting writes the owner's changes to a painting read by getOwnPainting. A painting changed
+// since then is answered with 412 rather than overwritten.
+func (h *Handler) savePainting(c *gin.Context, painting *model.Painting) bool {
+	painting.Updated = util.GetUnixMilli()
+	if err := h.Paintings.UpdatePainting(painting); err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "the painting was changed"})
+		} else {
+			c.JSON(500, painting)
+		}
+		return false
+	}
+	if err := h.indexPainting(painting); err != nil {
+		log.Println(err.Error())
+	}
+	c.Header("ETag", paintingETag(painting))
+	c.JSON(200, painting)
+	return true
+}
+
+// imageUploadResult acknowledges an image upload. The image itself is left out: it is as large as
+// the request, and Idempotent keeps the response in a DynamoDB item.
+type imageUploadResult struct {
+	UserId    string          `json:"user_id"`
+	Timestamp string          `json:"timestamp"`
+	Image     model.ImageKind `json:"image"`
+}
+
+//PATCH /wcs/:id/:timestamp/images
+// uploads one of the painting's images, the first one the body carries.
+func (h *Handler) PatchPaintingImage(c *gin.Context) {
 	log.Printf("EVENT: patch start")
-	paintingImages, err := parseImageBody(c)
+	paintingImages, err := h.parseImageBody(c)
 	if err != nil {
 		return
 	}
+	result := imageUploadResult{UserId: paintingImages.UserId, Timestamp: paintingImages.Timestamp}
 	if paintingImages.ImageCover != "" {
-		err = uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.ImageCover, model.ImageCover)
+		result.Image = model.ImageCover
+		err = h.uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.ImageCover, model.ImageCover)
 	} else if paintingImages.Image1 != "" {
-		err = uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.Image1, model.Image1)
+		result.Image = model.Image1
+		err = h.uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.Image1, model.Image1)
 	} else if paintingImages.Image2 != "" {
-		err = uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.Image2, model.Image2)
+		result.Image = model.Image2
+		err = h.uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.Image2, model.Image2)
 	} else if paintingImages.Image3 != "" {
-		err = uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.Image3, model.Image3)
+		result.Image = model.Image3
+		err = h.uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.Image3, model.Image3)
 	} else if paintingImages.Image4 != "" {
-		err = uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.Image4, model.Image4)
+		result.Image = model.Image4
+		err = h.uploadFile(paintingImages.UserId, paintingImages.Timestamp, paintingImages.Image4, model.Image4)
 	}
 	if err != nil {
 		log.Println(err.Error())
-		c.JSON(500, paintingImages)
+		c.JSON(500, result)
 	} else {
-		c.JSON(200, paintingImages)
+		c.JSON(200, result)
 	}
 	log.Printf("EVENT: patch end")
 }
 
-//POST /invalidate
-func InvalidatePainting(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"message": "/wcs/:id",
-	})
-}
-
 //:id/:timestamp
-func ServePainting(c *gin.Context) {
+func (h *Handler) ServePainting(c *gin.Context) {
 	id := c.Param("id")
 	timestamp := c.Param("timestamp")
-	var painting model.Painting
-	var err error
-	if env.IsLocal {
-		painting, err = db.GetPainting("875895056078483456", "20210810150726359")
-	} else {
-		painting, err = db.GetPainting(id, timestamp)
-	}
+	painting, err := h.Paintings.GetPainting(id, timestamp)
 	if err != nil {
 		c.JSON(404, gin.H{
 			"message": "error: " + err.Error() + " id: " + id,
 		})
 		return
 	}
+	viewer := h.getViewer(c)
+	if !h.canView(viewer, &painting) {
+		c.JSON(404, gin.H{
+			"message": "error: not found id: " + id,
+		})
+		return
+	}
+	if !isOwner(viewer, &painting) {
+		h.countView(c, viewer, &painting)
+	}
+	c.Header("ETag", paintingETag(&painting))
 	c.JSON(200, painting)
-	// eq, err := db.GetPigment()
+	// eq, err := h.Pigments.GetPigment()
 	// if err != nil {
 	// 	c.JSON(404, gin.H{
 	// 		"message": "error: " + err.Error() + " id: " + id,
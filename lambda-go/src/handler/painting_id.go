@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/domain"
+)
+
+// ResolvePainting lets the /paintings/:paintingId routes share the handlers of /wcs/:id/:timestamp.
+// It looks the painting up by its id and sets the :id and :timestamp parameters they read. Composite
+// ids, userId-timestamp as model.Painting.GetId makes them, are split instead; ULIDs have no dash.
+func (h *Handler) ResolvePainting(c *gin.Context) {
+	paintingId := c.Param("paintingId")
+	var userId, timestamp string
+	if i := strings.LastIndex(paintingId, "-"); i >= 0 {
+		userId, timestamp = paintingId[:i], paintingId[i+1:]
+	} else {
+		painting, err := h.Paintings.GetPaintingById(paintingId)
+		if errors.Is(err, domain.ErrNotFound) {
+			c.AbortWithStatusJSON(404, gin.H{
+				"message": "error: not found id: " + paintingId,
+			})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		userId, timestamp = painting.UserId, painting.Timestamp
+	}
+	c.Params = append(c.Params, gin.Param{Key: "id", Value: userId}, gin.Param{Key: "timestamp", Value: timestamp})
+}
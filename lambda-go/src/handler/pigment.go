@@ -4,11 +4,10 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/hirosato/wcs/db"
 	"github.com/hirosato/wcs/model"
 )
 
-func ServePigmentSearch(c *gin.Context) {
+func (h *Handler) ServePigmentSearch(c *gin.Context) {
 	cat := c.Query("cat")
 	q := c.Query("q")
 	if cat == "" || q == "" {
@@ -25,7 +24,7 @@ func ServePigmentSearch(c *gin.Context) {
 		return
 	}
 
-	equipments, err := db.GetPigment(model.JA, int32(icat), q)
+	equipments, err := h.Pigments.GetPigment(model.JA, int32(icat), q)
 	if err != nil {
 		c.JSON(404, gin.H{
 			"message": "error: " + err.Error(),
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 500
+	maxWebsiteLength     = 200
+	maxLocationLength    = 100
+	maxFavorites         = 20
+)
+
+// profileRequest has a pointer per field, so fields left out of the body are not touched.
+type profileRequest struct {
+	DisplayName        *string  `json:"displayName"`
+	Bio                *string  `json:"bio"`
+	Website            *string  `json:"website"`
+	Location           *string  `json:"location"`
+	Language           *string  `json:"language"`
+	FavoriteEquipments *[]int32 `json:"favoriteEquipments"`
+}
+
+func checkLength(name string, value string, max int) string {
+	if utf8.RuneCountInString(value) > max {
+		return name + " is too long"
+	}
+	return ""
+}
+
+// applyProfile validates req and copies it onto user. It returns what is wrong with the request.
+func applyProfile(user *model.User, req profileRequest) string {
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if name == "" {
+			return "displayName cannot be empty"
+		}
+		if msg := checkLength("displayName", name, maxDisplayNameLength); msg != "" {
+			return msg
+		}
+		user.DisplayName = name
+		user.DisplayNameEdited = true
+	}
+	if req.Bio != nil {
+		if msg := checkLength("bio", *req.Bio, maxBioLength); msg != "" {
+			return msg
+		}
+		user.Bio = *req.Bio
+	}
+	if req.Website != nil {
+		if *req.Website != "" {
+			website, err := url.Parse(*req.Website)
+			if err != nil || (website.Scheme != "http" && website.Scheme != "https") || website.Host == "" {
+				return "website has to be an http or https URL"
+			}
+		}
+		if msg := checkLength("website", *req.Website, maxWebsiteLength); msg != "" {
+			return msg
+		}
+		user.Website = *req.Website
+	}
+	if req.Location != nil {
+		if msg := checkLength("location", *req.Location, maxLocationLength); msg != "" {
+			return msg
+		}
+		user.Location = *req.Location
+	}
+	if req.Language != nil {
+		if _, ok := model.ParseLang(*req.Language); *req.Language != "" && !ok {
+			return "unsupported language: " + *req.Language
+		}
+		user.Language = *req.Language
+	}
+	if req.FavoriteEquipments != nil {
+		if len(*req.FavoriteEquipments) > maxFavorites {
+			return "too many favorites"
+		}
+		seen := map[int32]bool{}
+		for _, key := range *req.FavoriteEquipments {
+			if seen[key] {
+				return "favoriteEquipments lists a key twice"
+			}
+			seen[key] = true
+		}
+		user.FavoriteEquipments = *req.FavoriteEquipments
+	}
+	return ""
+}
+
+// getEquipments resolves the user's favorites in the catalog, in their language.
+func (h *Handler) getEquipments(user *model.User) ([]model.Pigment, error) {
+	lang, ok := model.ParseLang(user.Language)
+	if !ok {
+		lang = model.JA
+	}
+	return h.Pigments.GetPigmentsByKeys(lang, user.FavoriteEquipments)
+}
+
+//GET /users/:id
+func (h *Handler) ServeProfile(c *gin.Context) {
+	id := c.Param("id")
+	user, err := h.Users.GetUser(id)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + id,
+		})
+		return
+	}
+	equipments, err := h.getEquipments(&user)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"user":       user,
+		"equipments": equipments,
+	})
+}
+
+//PATCH /profile
+// a display name set here is kept over the sign-in provider's from then on.
+func (h *Handler) UpdateProfile(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var req profileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := applyProfile(&user, req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	equipments, err := h.getEquipments(&user)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if len(equipments) != len(user.FavoriteEquipments) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "favoriteEquipments has keys that are not in the catalog"})
+		return
+	}
+	if err := h.Users.UpdateUserProfile(user); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"user":       user,
+		"equipments": equipments,
+	})
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/file"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+type cropRequest struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type profileImageRequest struct {
+	Image string `json:"image" binding:"required"`
+	// in pixels of the uploaded image. The largest centered area of the right shape when left out.
+	Crop *cropRequest `json:"crop"`
+}
+
+// cropRect checks the requested crop against the image and the variant's aspect ratio, allowing
+// a couple of percent for rounding in the front end's cropper.
+func cropRect(bounds image.Rectangle, req *cropRequest, variant model.ImageVariant) (image.Rectangle, string) {
+	if req == nil {
+		width, height := bounds.Dx(), bounds.Dx()*variant.Height/variant.Width
+		if height > bounds.Dy() {
+			width, height = bounds.Dy()*variant.Width/variant.Height, bounds.Dy()
+		}
+		min := bounds.Min.Add(image.Pt((bounds.Dx()-width)/2, (bounds.Dy()-height)/2))
+		return image.Rectangle{Min: min, Max: min.Add(image.Pt(width, height))}, ""
+	}
+	crop := image.Rect(req.X, req.Y, req.X+req.Width, req.Y+req.Height).Add(bounds.Min)
+	if req.Width <= 0 || req.Height <= 0 || !crop.In(bounds) {
+		return crop, "crop has to be inside the image"
+	}
+	want := float64(variant.Width) / float64(variant.Height)
+	got := float64(req.Width) / float64(req.Height)
+	if got < want*0.98 || got > want*1.02 {
+		return crop, fmt.Sprintf("crop has to be %d:%d", variant.Width, variant.Height)
+	}
+	return crop, ""
+}
+
+// uploadVariants scales the cropped image to every variant of kind and uploads them under a new
+// version, so the CDN never serves a stale image for the new URLs.
+func (h *Handler) uploadVariants(userId string, kind model.ProfileImageKind, img image.Image, crop image.Rectangle, version string) (map[string]string, error) {
+	urls := map[string]string{}
+	for _, variant := range kind.Variants() {
+		resized := file.CropAndResize(img, crop, variant.Width, variant.Height)
+		filename, err := h.LocalFiles.AddImage(fmt.Sprintf("%s-%s-%s-%s", userId, kind, version, variant.Name), resized)
+		if err != nil {
+			h.LocalFiles.Remove(filename)
+			return nil, err
+		}
+		key := fmt.Sprintf("/users/%s/%s/%s/%s.png", userId, kind, version, variant.Name)
+		err = h.Blobs.Put(filename, key)
+		h.LocalFiles.Remove(filename)
+		if err != nil {
+			return nil, err
+		}
+		urls[variant.Name] = h.Config.ImageUrl + key
+	}
+	return urls, nil
+}
+
+// uploadedVersion returns the key prefix of the user's uploaded images of kind, or "" when they
+// have none.
+func (h *Handler) uploadedVersion(user *model.User, kind model.ProfileImageKind) string {
+	urls := user.Headers
+	if kind == model.ProfileAvatar {
+		urls = user.Avatars
+	}
+	base := fmt.Sprintf("/users/%s/%s/", user.UserId, kind)
+	for _, url := range urls {
+		prefix := path.Dir(strings.TrimPrefix(url, h.Config.ImageUrl)) + "/"
+		if strings.HasPrefix(prefix, base) && prefix != base {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// deleteVersion removes images the user no longer points to, so the CDN stops serving them. The
+// user is already updated, so a failure is only logged.
+func (h *Handler) deleteVersion(prefix string) {
+	if prefix == "" {
+		return
+	}
+	if err := h.Blobs.DeletePrefix(prefix); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (h *Handler) putProfileImage(c *gin.Context, kind model.ProfileImageKind) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var req profileImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	img, err := file.DecodeImage(req.Image)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	crop, msg := cropRect(img.Bounds(), req.Crop, kind.Variants()[0])
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	_, version := util.GetDateAndTimestamp()
+	urls, err := h.uploadVariants(user.UserId, kind, img, crop, version)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	old := h.uploadedVersion(&user, kind)
+	if kind == model.ProfileAvatar {
+		if !user.AvatarURLEdited && user.ProviderAvatarURL == "" {
+			// users from before uploads still have the provider's avatar in AvatarURL only.
+			user.ProviderAvatarURL = user.AvatarURL
+		}
+		user.Avatars = urls
+		user.AvatarURL = urls["medium"]
+		user.AvatarURLEdited = true
+	} else {
+		user.Headers = urls
+	}
+	if err := h.Users.SetUserImages(user, kind); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	// two uploads in the same millisecond share a version.
+	if old != h.uploadedVersion(&user, kind) {
+		h.deleteVersion(old)
+	}
+	c.JSON(200, user)
+}
+
+func (h *Handler) deleteProfileImage(c *gin.Context, kind model.ProfileImageKind) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	old := h.uploadedVersion(&user, kind)
+	if kind == model.ProfileAvatar {
+		if user.AvatarURLEdited {
+			user.AvatarURL = user.ProviderAvatarURL
+		}
+		user.Avatars = nil
+		user.AvatarURLEdited = false
+	} else {
+		user.Headers = nil
+	}
+	if err := h.Users.SetUserImages(user, kind); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	h.deleteVersion(old)
+	c.JSON(200, user)
+}
+
+//PUT /profile/avatar
+func (h *Handler) PutAvatar(c *gin.Context) {
+	h.putProfileImage(c, model.ProfileAvatar)
+}
+
+//DELETE /profile/avatar
+// goes back to the sign-in provider's avatar.
+func (h *Handler) DeleteAvatar(c *gin.Context) {
+	h.deleteProfileImage(c, model.ProfileAvatar)
+}
+
+//PUT /profile/header
+func (h *Handler) PutHeader(c *gin.Context) {
+	h.putProfileImage(c, model.ProfileHeader)
+}
+
+//DELETE /profile/header
+func (h *Handler) DeleteHeader(c *gin.Context) {
+	h.deleteProfileImage(c, model.ProfileHeader)
+}
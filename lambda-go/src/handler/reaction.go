@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+// getVisiblePainting loads the painting addressed by the URL as long as viewer may see it.
+func (h *Handler) getVisiblePainting(c *gin.Context, viewer *model.User) (*model.Painting, bool) {
+	id := c.Param("id")
+	painting, err := h.Paintings.GetPainting(id, c.Param("timestamp"))
+	if err != nil || !h.canView(viewer, &painting) {
+		c.JSON(404, gin.H{
+			"message": "error: not found id: " + id,
+		})
+		return nil, false
+	}
+	return &painting, true
+}
+
+func (h *Handler) react(c *gin.Context, kind model.ReactionKind) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	painting, ok := h.getVisiblePainting(c, &user)
+	if !ok {
+		return
+	}
+	if !h.checkNotBlocked(c, user.UserId, painting.UserId) {
+		return
+	}
+	reaction := model.Reaction{
+		PaintingId:        painting.GetId(),
+		Kind:              kind,
+		UserId:            user.UserId,
+		PaintingUserId:    painting.UserId,
+		PaintingTimestamp: painting.Timestamp,
+		Created:           util.GetUnixMilli(),
+	}
+	if _, err := h.Reactions.PutReaction(reaction); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, reaction)
+}
+
+func (h *Handler) unreact(c *gin.Context, kind model.ReactionKind) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	painting, err := h.Paintings.GetPainting(c.Param("id"), c.Param("timestamp"))
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + c.Param("id"),
+		})
+		return
+	}
+	if _, err := h.Reactions.DeleteReaction(&painting, kind, user.UserId); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
+
+//PUT /wcs/:id/:timestamp/like
+func (h *Handler) LikePainting(c *gin.Context) {
+	h.react(c, model.ReactionLike)
+}
+
+//DELETE /wcs/:id/:timestamp/like
+func (h *Handler) UnlikePainting(c *gin.Context) {
+	h.unreact(c, model.ReactionLike)
+}
+
+//PUT /wcs/:id/:timestamp/favorite
+func (h *Handler) FavoritePainting(c *gin.Context) {
+	h.react(c, model.ReactionFavorite)
+}
+
+//DELETE /wcs/:id/:timestamp/favorite
+func (h *Handler) UnfavoritePainting(c *gin.Context) {
+	h.unreact(c, model.ReactionFavorite)
+}
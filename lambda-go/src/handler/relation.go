@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+// the excluded users go into every ES query of the viewer's, which has to stay reasonably small.
+const maxRelations = 1000
+
+// checkNotBlocked answers 403 when either user has blocked the other.
+func (h *Handler) checkNotBlocked(c *gin.Context, userId string, otherId string) bool {
+	blocked, err := h.Relations.IsBlockedBetween(userId, otherId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return false
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "blocked"})
+		return false
+	}
+	return true
+}
+
+// getExcludedAuthors returns the users whose paintings are left out of the viewer's listings.
+func (h *Handler) getExcludedAuthors(viewer *model.User) (map[string]bool, error) {
+	excluded := map[string]bool{}
+	if viewer == nil {
+		return excluded, nil
+	}
+	userIds, err := h.Relations.ListExcludedAuthors(viewer.UserId)
+	if err != nil {
+		return nil, err
+	}
+	for _, userId := range userIds {
+		excluded[userId] = true
+	}
+	return excluded, nil
+}
+
+func (h *Handler) relate(c *gin.Context, kind model.RelationKind) {
+	targetId := c.Param("id")
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user.UserId == targetId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot " + string(kind) + " yourself"})
+		return
+	}
+	if _, err := h.Users.GetUser(targetId); err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + targetId,
+		})
+		return
+	}
+	relations, err := h.Relations.ListRelations(user.UserId, kind)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if len(relations) >= maxRelations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many users. remove some first"})
+		return
+	}
+	relation := model.Relation{
+		UserId:   user.UserId,
+		Kind:     kind,
+		TargetId: targetId,
+		Created:  util.GetUnixMilli(),
+	}
+	if err := h.Relations.PutRelation(relation); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if kind == model.RelationBlock {
+		// a block ends following in both directions.
+		if err := h.Follows.DeleteFollow(user.UserId, targetId); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if err := h.Follows.DeleteFollow(targetId, user.UserId); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(200, relation)
+}
+
+func (h *Handler) unrelate(c *gin.Context, kind model.RelationKind) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.Relations.DeleteRelation(user.UserId, kind, c.Param("id")); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(204)
+}
+
+func (h *Handler) serveRelations(c *gin.Context, kind model.RelationKind) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	relations, err := h.Relations.ListRelations(user.UserId, kind)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"results": relations,
+	})
+}
+
+//PUT /users/:id/block
+func (h *Handler) Block(c *gin.Context) {
+	h.relate(c, model.RelationBlock)
+}
+
+//DELETE /users/:id/block
+func (h *Handler) Unblock(c *gin.Context) {
+	h.unrelate(c, model.RelationBlock)
+}
+
+//PUT /users/:id/mute
+func (h *Handler) Mute(c *gin.Context) {
+	h.relate(c, model.RelationMute)
+}
+
+//DELETE /users/:id/mute
+func (h *Handler) Unmute(c *gin.Context) {
+	h.unrelate(c, model.RelationMute)
+}
+
+//GET /blocks
+func (h *Handler) ServeBlocks(c *gin.Context) {
+	h.serveRelations(c, model.RelationBlock)
+}
+
+//GET /mutes
+func (h *Handler) ServeMutes(c *gin.Context) {
+	h.serveRelations(c, model.RelationMute)
+}
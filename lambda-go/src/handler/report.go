@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+const maxReportLength = 1000
+
+type reportRequest struct {
+	TargetKind model.ReportTargetKind `json:"targetKind" binding:"required"`
+	// the painting's owner, the comment's painting owner, or the reported user.
+	UserId    string             `json:"userId" binding:"required"`
+	Timestamp string             `json:"timestamp"`
+	CommentId string             `json:"commentId"`
+	Reason    model.ReportReason `json:"reason" binding:"required"`
+	Text      string             `json:"text"`
+}
+
+// reportTarget checks that the reporter can see what they report and describes it as a queue item.
+func (h *Handler) reportTarget(viewer *model.User, req reportRequest) (model.ModerationItem, bool) {
+	item := model.ModerationItem{TargetKind: req.TargetKind}
+	switch req.TargetKind {
+	case model.ReportPainting, model.ReportComment:
+		painting, err := h.Paintings.GetPainting(req.UserId, req.Timestamp)
+		if err != nil || !h.canView(viewer, &painting) {
+			return item, false
+		}
+		item.TargetUserId = painting.UserId
+		item.PaintingId = painting.GetId()
+		item.PaintingTimestamp = painting.Timestamp
+		if req.TargetKind == model.ReportComment {
+			comment, err := h.Reactions.GetComment(painting.GetId(), req.CommentId)
+			if err != nil || comment.Hidden {
+				return item, false
+			}
+			item.TargetUserId = comment.UserId
+			item.CommentId = comment.CommentId
+		}
+	case model.ReportUser:
+		user, err := h.Users.GetUser(req.UserId)
+		if err != nil {
+			return item, false
+		}
+		item.TargetUserId = user.UserId
+	default:
+		return item, false
+	}
+	item.ItemId = model.ModerationItemId(item.TargetKind, item.TargetId())
+	return item, true
+}
+
+//POST /reports
+// reports on the same target end up in the same moderation queue item.
+func (h *Handler) PostReport(c *gin.Context) {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var req reportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !req.Reason.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown reason: " + string(req.Reason)})
+		return
+	}
+	if utf8.RuneCountInString(req.Text) > maxReportLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text is too long"})
+		return
+	}
+	item, ok := h.reportTarget(&user, req)
+	if !ok {
+		c.JSON(404, gin.H{"message": "error: no such " + string(req.TargetKind)})
+		return
+	}
+	if item.TargetUserId == user.UserId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot report yourself"})
+		return
+	}
+	now := util.GetUnixMilli()
+	report := model.Report{
+		ItemId:  item.ItemId,
+		UserId:  user.UserId,
+		Reason:  req.Reason,
+		Text:    req.Text,
+		Created: now,
+	}
+	reported, err := h.Reports.PutReport(report)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !reported {
+		c.JSON(http.StatusConflict, gin.H{"error": "already reported"})
+		return
+	}
+	if err := h.Reports.RecordReport(item, now); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, report)
+}
+
+//GET /moderation?state=open|actioned|dismissed
+// open items by default, the ones waiting longest first.
+func (h *Handler) ServeModerationQueue(c *gin.Context) {
+	state := model.ModerationState(c.DefaultQuery("state", string(model.ModerationOpen)))
+	switch state {
+	case model.ModerationOpen, model.ModerationActioned, model.ModerationDismissed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown state: " + string(state)})
+		return
+	}
+	items, err := h.Reports.ListModerationItems(state)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"results": items,
+	})
+}
+
+func (h *Handler) getModerationItem(c *gin.Context) (*model.ModerationItem, bool) {
+	itemId := c.Param("itemId")
+	item, err := h.Reports.GetModerationItem(itemId)
+	if err != nil {
+		c.JSON(404, gin.H{
+			"message": "error: " + err.Error() + " id: " + itemId,
+		})
+		return nil, false
+	}
+	return &item, true
+}
+
+//GET /moderation/:itemId
+func (h *Handler) ServeModerationItem(c *gin.Context) {
+	item, ok := h.getModerationItem(c)
+	if !ok {
+		return
+	}
+	reports, err := h.Reports.ListReports(item.ItemId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"item":    item,
+		"reports": reports,
+	})
+}
+
+//POST /moderation/:itemId/claim
+func (h *Handler) ClaimModerationItem(c *gin.Context) {
+	item, ok := h.getModerationItem(c)
+	if !ok {
+		return
+	}
+	actor := getActor(c)
+	if !h.audit(c, "claim-report", string(item.TargetKind), item.TargetId(), item.ItemId) {
+		return
+	}
+	claimed, err := h.Reports.ClaimModerationItem(item.ItemId, actor.UserId, util.GetUnixMilli())
+	if err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the item is closed or claimed by someone else"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, claimed)
+}
+
+type resolveRequest struct {
+	// hide the painting or comment, suspend the user behind the target, or dismiss the reports.
+	Action string `json:"action" binding:"required"`
+	Note   string `json:"note"`
+}
+
+// moderate hides or shows the target again, or suspends or reinstates its user.
+func (h *Handler) moderate(item *model.ModerationItem, action string, undo bool) error {
+	switch action {
+	case "hide":
+		if item.TargetKind == model.ReportComment {
+			return h.Reactions.SetCommentHidden(item.PaintingId, item.CommentId, !undo)
+		}
+		painting, err := h.Paintings.GetPainting(item.TargetUserId, item.PaintingTimestamp)
+		if err != nil {
+			return err
+		}
+		reason := ""
+		if !undo {
+			reason = "reported: " + item.ItemId
+		}
+		return h.applyPaintingHidden(&painting, !undo, reason)
+	case "suspend":
+		reason := ""
+		if !undo {
+			reason = "reported: " + item.ItemId
+		}
+		return h.applyUserSuspended(item.TargetUserId, !undo, reason)
+	}
+	return nil
+}
+
+//POST /moderation/:itemId/resolve
+// only the moderator who claimed the item can resolve it. Suspending takes an admin.
+func (h *Handler) ResolveModerationItem(c *gin.Context) {
+	item, ok := h.getModerationItem(c)
+	if !ok {
+		return
+	}
+	var req resolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	actor := getActor(c)
+	state := model.ModerationActioned
+	switch req.Action {
+	case "hide":
+		if item.TargetKind == model.ReportUser {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "users cannot be hidden. suspend them instead"})
+			return
+		}
+	case "suspend":
+		if !h.roleOf(actor).Includes(model.RoleAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "admins only"})
+			return
+		}
+		if !h.canSuspend(c, actor, item.TargetUserId) {
+			return
+		}
+	case "dismiss":
+		state = model.ModerationDismissed
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown action: " + req.Action})
+		return
+	}
+	if item.State != model.ModerationOpen || item.ClaimedBy != actor.UserId {
+		c.JSON(http.StatusConflict, gin.H{"error": "claim the open item first"})
+		return
+	}
+	if !h.audit(c, "resolve-report:"+req.Action, string(item.TargetKind), item.TargetId(), req.Note) {
+		return
+	}
+	// the item is resolved before the action is taken, so a moderator who lost the claim in the
+	// meantime, or a second click on resolve, changes nothing.
+	resolved, err := h.Reports.ResolveModerationItem(item.ItemId, actor.UserId, state, req.Action, req.Note, util.GetUnixMilli())
+	if err != nil {
+		if errors.Is(err, domain.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "claim the open item first"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if err := h.moderate(item, req.Action, false); err != nil {
+		if reopenErr := h.Reports.ReopenModerationItem(item.ItemId, actor.UserId); reopenErr != nil {
+			log.Println(reopenErr.Error())
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, resolved)
+}
+
+type noteRequest struct {
+	Note string `json:"note"`
+}
+
+//POST /moderation/:itemId/restore
+// undoes the action taken on an item, for example when a hidden painting turns out to be fine.
+func (h *Handler) RestoreModerationItem(c *gin.Context) {
+	item, ok := h.getModerationItem(c)
+	if !ok {
+		return
+	}
+	// the note is optional, so a request without a body is fine.
+	var req noteRequest
+	c.ShouldBindJSON(&req)
+	actor := getActor(c)
+	if item.State != model.ModerationActioned {
+		c.JSON(http.StatusConflict, gin.H{"error": "nothing to restore"})
+		return
+	}
+	if item.Resolution == "suspend" && !h.roleOf(actor).Includes(model.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admins only"})
+		return
+	}
+	if !h.audit(c, "restore-report:"+item.Resolution, string(item.TargetKind), item.TargetId(), req.Note) {
+		return
+	}
+	if err := h.moderate(item, item.Resolution, true); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	restored, err := h.Reports.RestoreModerationItem(item.ItemId, actor.UserId, req.Note, util.GetUnixMilli())
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, restored)
+}
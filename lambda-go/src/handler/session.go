@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sessionInfo struct {
+	Handle    string `json:"handle"`
+	UserAgent string `json:"userAgent"`
+	IpAddress string `json:"ipAddress"`
+	Created   int64  `json:"created"`
+	LastSeen  int64  `json:"lastSeen"`
+	Current   bool   `json:"current"`
+}
+
+//GET /sessions
+// the devices the user is logged in on. Sessions that expired but are not swept yet are left out.
+func (h *Handler) ServeSessions(c *gin.Context) {
+	current := h.Sessions.GetSession(c.Request)
+	if !current.IsLoggedIn() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "not logged in"})
+		return
+	}
+	sessions, err := h.SessionStore.ListUserSessions(current.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now().Unix()
+	results := []sessionInfo{}
+	for _, s := range sessions {
+		if s.IsExpired(now) {
+			continue
+		}
+		results = append(results, sessionInfo{
+			Handle:    s.Handle(),
+			UserAgent: s.UserAgent,
+			IpAddress: s.IpAddress,
+			Created:   s.Created,
+			LastSeen:  s.LastSeen,
+			Current:   s.SessionId == current.SessionId,
+		})
+	}
+	c.JSON(200, gin.H{
+		"results": results,
+	})
+}
+
+//DELETE /sessions/:handle
+func (h *Handler) RevokeSession(c *gin.Context) {
+	current := h.Sessions.GetSession(c.Request)
+	if !current.IsLoggedIn() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "not logged in"})
+		return
+	}
+	sessions, err := h.SessionStore.ListUserSessions(current.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now().Unix()
+	for _, s := range sessions {
+		if s.Handle() != c.Param("handle") || s.IsExpired(now) {
+			continue
+		}
+		if err := h.SessionStore.DeleteSession(s.SessionId); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if s.SessionId == current.SessionId {
+			h.Sessions.ClearSession(c.Writer, c.Request)
+		}
+		c.Status(204)
+		return
+	}
+	c.JSON(404, gin.H{
+		"message": "error: not found id: " + c.Param("handle"),
+	})
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+const (
+	tokenPrefix        = "wcs_"
+	maxTokensPerUser   = 20
+	defaultTokenDays   = 90
+	maxTokenDays       = 365
+	day                = 24 * 60 * 60 * 1000
+	tokenTouchInterval = 60 * 1000
+)
+
+type tokenScopeKey struct{}
+
+type tokenUserKey struct{}
+
+var errMissingScope = errors.New("token lacks the scope")
+
+// AllowToken lets the route accept a personal access token with the scope in place of the session
+// cookie. Routes without it only accept the cookie. The token is checked before the route runs: an
+// unknown, revoked or expired one gets 401 and one without the scope 403.
+func (h *Handler) AllowToken(scope model.TokenScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), tokenScopeKey{}, scope)
+		if raw, ok := bearerToken(c.Request); ok {
+			user, err := h.getTokenUser(c.Request.WithContext(ctx), raw)
+			if errors.Is(err, errMissingScope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			ctx = context.WithValue(ctx, tokenUserKey{}, user)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")), true
+}
+
+// getTokenUser authenticates a request that carries a personal access token. AllowToken has done
+// so already for its routes.
+func (h *Handler) getTokenUser(r *http.Request, raw string) (model.User, error) {
+	if user, ok := r.Context().Value(tokenUserKey{}).(model.User); ok {
+		return user, nil
+	}
+	scope, _ := r.Context().Value(tokenScopeKey{}).(model.TokenScope)
+	if scope == "" {
+		return model.User{}, errors.New("api tokens are not accepted here")
+	}
+	token, err := h.Tokens.GetApiToken(model.HashApiToken(raw))
+	if err != nil {
+		return model.User{}, errors.New("invalid token")
+	}
+	now := util.GetUnixMilli()
+	if token.IsExpired(now) {
+		return model.User{}, errors.New("token expired")
+	}
+	if !token.HasScope(scope) {
+		return model.User{}, fmt.Errorf("%w %s", errMissingScope, scope)
+	}
+	if now-token.LastUsed > tokenTouchInterval {
+		if err := h.Tokens.TouchApiToken(token.TokenHash, now); err != nil {
+			log.Printf("failed to touch token %s: %s", token.TokenId, err.Error())
+		}
+	}
+	return h.Users.GetUser(token.UserId)
+}
+
+type tokenRequest struct {
+	Name          string             `json:"name" binding:"required"`
+	Scopes        []model.TokenScope `json:"scopes" binding:"required"`
+	ExpiresInDays int                `json:"expiresInDays"`
+}
+
+// getCookieUser is GetUser for the token management routes, which a token must never reach.
+func (h *Handler) getCookieUser(c *gin.Context) (model.User, bool) {
+	if _, ok := bearerToken(c.Request); ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "tokens cannot manage tokens"})
+		return model.User{}, false
+	}
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return model.User{}, false
+	}
+	return user, true
+}
+
+//POST /tokens
+// the token is only in this response. Later listings show everything but the token.
+func (h *Handler) CreateToken(c *gin.Context) {
+	user, ok := h.getCookieUser(c)
+	if !ok {
+		return
+	}
+	var req tokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one scope is required"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !scope.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown scope: " + string(scope)})
+			return
+		}
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultTokenDays
+	}
+	if req.ExpiresInDays < 1 || req.ExpiresInDays > maxTokenDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresInDays has to be between 1 and 365"})
+		return
+	}
+	tokens, err := h.Tokens.ListApiTokens(user.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if len(tokens) >= maxTokensPerUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many tokens. revoke one first"})
+		return
+	}
+	raw := tokenPrefix + util.NewId() + util.NewId()
+	now := util.GetUnixMilli()
+	token := model.ApiToken{
+		TokenHash: model.HashApiToken(raw),
+		TokenId:   util.NewId(),
+		UserId:    user.UserId,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Created:   now,
+		ExpiresAt: now + uint64(req.ExpiresInDays)*day,
+	}
+	if err := h.Tokens.PutApiToken(token); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"token":    raw,
+		"apiToken": token,
+	})
+}
+
+//GET /tokens
+func (h *Handler) ServeTokens(c *gin.Context) {
+	user, ok := h.getCookieUser(c)
+	if !ok {
+		return
+	}
+	tokens, err := h.Tokens.ListApiTokens(user.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{
+		"results": tokens,
+	})
+}
+
+//DELETE /tokens/:tokenId
+func (h *Handler) RevokeToken(c *gin.Context) {
+	user, ok := h.getCookieUser(c)
+	if !ok {
+		return
+	}
+	tokens, err := h.Tokens.ListApiTokens(user.UserId)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, token := range tokens {
+		if token.TokenId == c.Param("tokenId") {
+			if err := h.Tokens.DeleteApiToken(token.TokenHash); err != nil {
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+			c.Status(204)
+			return
+		}
+	}
+	c.JSON(404, gin.H{"message": "error: not found id: " + c.Param("tokenId")})
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+)
+
+// paintingETag is the ETag of the painting's Version. Views and likes do not change it, since an
+// edit does not overwrite them.
+func paintingETag(painting *model.Painting) string {
+	return `"` + strconv.FormatUint(painting.Version, 10) + `"`
+}
+
+// checkIfMatch makes an update to painting carry the painting's ETag in If-Match, so an edit from a
+// stale copy, such as another tab, fails instead of overwriting a newer one. It answers 428 without
+// the header and 412 when the ETag is not the current one.
+func checkIfMatch(c *gin.Context, painting *model.Painting) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match is required"})
+		return false
+	}
+	current := paintingETag(painting)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		// weak tags never match, as If-Match compares strongly.
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+			return true
+		}
+	}
+	c.Header("ETag", current)
+	c.JSON(http.StatusPreconditionFailed, gin.H{"error": "the painting was changed"})
+	return false
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/session"
+)
+
+// Views are counted in batches rather than with a write per GET, since most come from anonymous
+// readers and crawlers. Within a batch a viewer, the user or else the client address, counts once
+// per painting. Views still pending when the lambda is recycled are lost, which the ranking can
+// live with.
+const (
+	viewBatchSize = 100
+	viewBatchAge  = time.Minute
+)
+
+type viewCounter struct {
+	mu      sync.Mutex
+	viewers map[model.PaintingRef]map[string]bool
+	size    int
+	started time.Time
+}
+
+func newViewCounter() *viewCounter {
+	return &viewCounter{viewers: map[model.PaintingRef]map[string]bool{}}
+}
+
+// add counts the view and returns the batch to write once it is full or old enough.
+func (v *viewCounter) add(ref model.PaintingRef, viewer string, now time.Time) map[model.PaintingRef]int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.size == 0 {
+		v.started = now
+	}
+	if v.viewers[ref] == nil {
+		v.viewers[ref] = map[string]bool{}
+	}
+	if !v.viewers[ref][viewer] {
+		v.viewers[ref][viewer] = true
+		v.size++
+	}
+	if v.size < viewBatchSize && now.Sub(v.started) < viewBatchAge {
+		return nil
+	}
+	return v.take()
+}
+
+// take empties the batch and returns its view count per painting. The caller holds mu.
+func (v *viewCounter) take() map[model.PaintingRef]int {
+	batch := map[model.PaintingRef]int{}
+	for ref, viewers := range v.viewers {
+		batch[ref] = len(viewers)
+	}
+	v.viewers = map[model.PaintingRef]map[string]bool{}
+	v.size = 0
+	return batch
+}
+
+// countView counts a view of painting by someone other than its owner.
+func (h *Handler) countView(c *gin.Context, viewer *model.User, painting *model.Painting) {
+	key := "ip:" + session.ClientIP(c.Request)
+	if viewer != nil {
+		key = "user:" + viewer.UserId
+	}
+	h.writeViews(h.views.add(painting.AsRef(), key, time.Now()))
+}
+
+// FlushViews writes the views counted so far without waiting for the batch to fill.
+func (h *Handler) FlushViews() {
+	h.views.mu.Lock()
+	batch := h.views.take()
+	h.views.mu.Unlock()
+	h.writeViews(batch)
+}
+
+func (h *Handler) writeViews(batch map[model.PaintingRef]int) {
+	for ref, count := range batch {
+		if err := h.Paintings.IncrementPaintingCounter(ref.UserId, ref.Timestamp, "Views", count); err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
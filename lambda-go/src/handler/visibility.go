@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/model"
+)
+
+// getViewer returns the logged in user, or nil for anonymous requests.
+func (h *Handler) getViewer(c *gin.Context) *model.User {
+	user, err := h.GetUser(c.Request)
+	if err != nil {
+		return nil
+	}
+	return &user
+}
+
+func isOwner(viewer *model.User, painting *model.Painting) bool {
+	return viewer != nil && viewer.UserId == painting.UserId
+}
+
+// canView decides whether viewer may open the painting by its URL.
+func (h *Handler) canView(viewer *model.User, painting *model.Painting) bool {
+	if isOwner(viewer, painting) {
+		return true
+	}
+	if painting.Draft || painting.Hidden {
+		return false
+	}
+	switch painting.GetVisibility() {
+	case model.VisibilityPublic, model.VisibilityUnlisted:
+		return true
+	case model.VisibilityFollowers:
+		if viewer == nil {
+			return false
+		}
+		following, err := h.Follows.IsFollowing(viewer.UserId, painting.UserId)
+		return err == nil && following
+	default:
+		return false
+	}
+}
+
+// isInGallery decides whether the painting is listed in its owner's gallery for viewer.
+// Unlisted paintings can be opened by URL but are never listed.
+func isInGallery(viewer *model.User, following bool, painting *model.Painting) bool {
+	if isOwner(viewer, painting) {
+		return true
+	}
+	if painting.Draft || painting.Hidden {
+		return false
+	}
+	switch painting.GetVisibility() {
+	case model.VisibilityPublic:
+		return true
+	case model.VisibilityFollowers:
+		return following
+	default:
+		return false
+	}
+}
+
+// indexPainting keeps the public ES index in line with the painting's visibility.
+func (h *Handler) indexPainting(painting *model.Painting) error {
+	if painting.IsPublic() {
+		return h.Search.PutEsPainting(painting)
+	}
+	return h.Search.DeleteEsPainting(painting)
+}
@@ -0,0 +1,43 @@
+package job
+
+import (
+	"log"
+
+	"github.com/hirosato/wcs/model"
+)
+
+// CloseChallenges freezes the results of every challenge whose voting window is over. Votes are
+// recounted from the vote items, and closing is conditional, so running it twice changes nothing.
+func (r *Runner) CloseChallenges(now uint64) error {
+	challenges, err := r.Challenges.ListChallenges()
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, challenge := range challenges {
+		if challenge.State(now) != model.ChallengeClosing {
+			continue
+		}
+		if err := r.closeChallenge(&challenge); err != nil {
+			log.Printf("failed to close %s: %s", challenge.ChallengeId, err.Error())
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (r *Runner) closeChallenge(challenge *model.Challenge) error {
+	entries, err := r.Challenges.ListChallengeEntries(challenge.ChallengeId)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		votes, err := r.Challenges.CountChallengeVotes(challenge.ChallengeId, entries[i].EntryId)
+		if err != nil {
+			return err
+		}
+		entries[i].Votes = votes
+	}
+	log.Printf("EVENT: closing challenge %s with %d entries", challenge.ChallengeId, len(entries))
+	return r.Challenges.CloseChallenge(challenge.ChallengeId, model.RankChallengeEntries(entries))
+}
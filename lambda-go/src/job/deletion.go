@@ -0,0 +1,153 @@
+package job
+
+import (
+	"log"
+
+	"github.com/hirosato/wcs/model"
+)
+
+// DeleteAccounts deletes every account whose grace period ended at or before now.
+// Each step only removes what is still there, so a run that fails halfway is finished by the next
+// one. The user record goes last, which keeps the account in the index until everything is gone.
+// Reports and the audit log are kept for moderation.
+func (r *Runner) DeleteAccounts(now uint64) error {
+	users, err := r.Users.GetUsersDueForDeletion(now)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, user := range users {
+		if err := r.deleteAccount(user.UserId); err != nil {
+			log.Printf("failed to delete account %s: %s", user.UserId, err.Error())
+			lastErr = err
+			continue
+		}
+		log.Printf("EVENT: deleted account %s", user.UserId)
+	}
+	return lastErr
+}
+
+func (r *Runner) deleteAccount(userId string) error {
+	if err := r.deletePaintings(userId); err != nil {
+		return err
+	}
+	if err := r.Blobs.DeletePrefix("/users/" + userId + "/"); err != nil {
+		return err
+	}
+
+	// what the user left on other people's paintings. Likes go away with their counts, comments
+	// stay without their author so the threads still make sense.
+	reactions, err := r.Reactions.ListUserReactions(userId)
+	if err != nil {
+		return err
+	}
+	for _, reaction := range reactions {
+		painting := model.Painting{UserId: reaction.PaintingUserId, Timestamp: reaction.PaintingTimestamp}
+		if _, err := r.Reactions.DeleteReaction(&painting, reaction.Kind, userId); err != nil {
+			return err
+		}
+	}
+	comments, err := r.Reactions.ListUserComments(userId)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		if err := r.Reactions.AnonymizeComment(&comments[i]); err != nil {
+			return err
+		}
+	}
+
+	following, err := r.Follows.ListFollowing(userId)
+	if err != nil {
+		return err
+	}
+	for _, follow := range following {
+		if err := r.Follows.DeleteFollow(userId, follow.FolloweeId); err != nil {
+			return err
+		}
+	}
+	followers, err := r.Follows.GetFollowers(userId)
+	if err != nil {
+		return err
+	}
+	for _, follow := range followers {
+		if err := r.Follows.DeleteFollow(follow.UserId, userId); err != nil {
+			return err
+		}
+	}
+	if err := r.Notifications.DeleteNotifications(userId); err != nil {
+		return err
+	}
+
+	collections, err := r.Collections.ListCollections(userId)
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		if err := r.Collections.DeleteCollection(userId, collection.CollectionId); err != nil {
+			return err
+		}
+	}
+	relations, err := r.Relations.ListRelations(userId, "")
+	if err != nil {
+		return err
+	}
+	for _, relation := range relations {
+		if err := r.Relations.DeleteRelation(userId, relation.Kind, relation.TargetId); err != nil {
+			return err
+		}
+	}
+	exports, err := r.Exports.ListExports(userId)
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if err := r.Exports.DeleteExport(userId, export.ExportId); err != nil {
+			return err
+		}
+	}
+
+	tokens, err := r.Tokens.ListApiTokens(userId)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if err := r.Tokens.DeleteApiToken(token.TokenHash); err != nil {
+			return err
+		}
+	}
+	identities, err := r.Identities.ListIdentities(userId)
+	if err != nil {
+		return err
+	}
+	for _, identity := range identities {
+		if err := r.Identities.DeleteIdentity(identity.Provider, identity.Subject); err != nil {
+			return err
+		}
+	}
+	if err := r.Sessions.ClearUserSessions(userId); err != nil {
+		return err
+	}
+	return r.Users.DeleteUser(userId)
+}
+
+// deletePaintings removes the user's paintings with their images, likes and comments.
+func (r *Runner) deletePaintings(userId string) error {
+	paintings, err := r.listAllPaintings(userId)
+	if err != nil {
+		return err
+	}
+	for i := range paintings {
+		painting := &paintings[i]
+		if err := r.Search.DeleteEsPainting(painting); err != nil {
+			return err
+		}
+		if err := r.Reactions.DeletePaintingActivity(painting.GetId()); err != nil {
+			return err
+		}
+		if err := r.Paintings.DeletePainting(painting); err != nil {
+			return err
+		}
+	}
+	return r.Blobs.DeletePrefix("/wcs/" + userId + "/")
+}
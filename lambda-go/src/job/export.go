@@ -0,0 +1,115 @@
+package job
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"log"
+
+	"github.com/hirosato/wcs/model"
+)
+
+// BuildExports builds the ZIP of every pending export. An export that fails is marked failed so the
+// user can ask for a new one, and the others go on.
+func (r *Runner) BuildExports(now uint64) error {
+	exports, err := r.Exports.GetPendingExports()
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, export := range exports {
+		key := "exports/" + export.UserId + "/" + export.ExportId + ".zip"
+		if err := r.buildExport(export.UserId, key); err != nil {
+			log.Printf("failed to export %s: %s", export.UserId, err.Error())
+			export.State = model.ExportFailed
+			export.Error = err.Error()
+			lastErr = err
+		} else {
+			export.State = model.ExportReady
+			export.Key = key
+		}
+		export.Finished = now
+		if err := r.Exports.PutExport(export); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (r *Runner) buildExport(userId string, key string) error {
+	user, err := r.Users.GetUser(userId)
+	if err != nil {
+		return err
+	}
+	paintings, err := r.listAllPaintings(userId)
+	if err != nil {
+		return err
+	}
+	comments, err := r.Reactions.ListUserComments(userId)
+	if err != nil {
+		return err
+	}
+	reactions, err := r.Reactions.ListUserReactions(userId)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for name, v := range map[string]interface{}{
+		"user.json":      user,
+		"paintings.json": paintings,
+		"comments.json":  comments,
+		"reactions.json": reactions,
+	} {
+		if err := writeJSON(w, name, v); err != nil {
+			return err
+		}
+	}
+	for i := range paintings {
+		for _, kind := range paintings[i].ImageKinds() {
+			image, err := r.Blobs.Get("/wcs/" + userId + "/" + paintings[i].Timestamp + "/" + kind.ToPathString() + ".png")
+			if err != nil {
+				return err
+			}
+			f, err := w.Create("images/" + paintings[i].Timestamp + "/" + kind.ToPathString() + ".png")
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(image); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return r.ExportFiles.Put(key, buf.Bytes())
+}
+
+func writeJSON(w *zip.Writer, name string, v interface{}) error {
+	f, err := w.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// listAllPaintings returns every painting of the user, drafts and hidden ones included.
+func (r *Runner) listAllPaintings(userId string) ([]model.Painting, error) {
+	result := []model.Painting{}
+	before := ""
+	for {
+		page, err := r.Paintings.ListUserPaintings(userId, before, 100)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page...)
+		if len(page) < 100 {
+			return result, nil
+		}
+		before = page[len(page)-1].Timestamp
+	}
+}
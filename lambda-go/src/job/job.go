@@ -0,0 +1,79 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/session"
+	"github.com/hirosato/wcs/util"
+)
+
+// Event is the constant input each CloudWatch schedule rule passes to the scheduler lambda.
+type Event struct {
+	Job string `json:"job"`
+}
+
+const (
+	JobPublish         = "publish"
+	JobCloseChallenges = "close-challenges"
+	JobRankPaintings   = "rank-paintings"
+	JobBuildExports    = "build-exports"
+	JobDeleteAccounts  = "delete-accounts"
+	// not scheduled; run once by hand after deploying painting ids.
+	JobAssignPaintingIds = "assign-painting-ids"
+	// not scheduled; run once by hand after deploying the ranking index.
+	JobMarkRankedPaintings = "mark-ranked-paintings"
+)
+
+// Services are what the jobs read and write through. main wires the real ones.
+type Services struct {
+	Sessions      *session.Manager
+	Paintings     domain.PaintingRepository
+	Users         domain.UserRepository
+	Follows       domain.FollowRepository
+	Notifications domain.NotificationRepository
+	Reactions     domain.ReactionRepository
+	Collections   domain.CollectionRepository
+	Challenges    domain.ChallengeRepository
+	Identities    domain.IdentityRepository
+	Tokens        domain.TokenRepository
+	Relations     domain.RelationRepository
+	Exports       domain.ExportRepository
+	Search        domain.SearchIndex
+	Blobs         domain.BlobStore
+	ExportFiles   domain.ExportStore
+}
+
+// Runner runs the background jobs with its services.
+type Runner struct {
+	Services
+}
+
+func New(services Services) *Runner {
+	return &Runner{Services: services}
+}
+
+func (r *Runner) Run(ctx context.Context, event Event) error {
+	log.Printf("EVENT: job %s start", event.Job)
+	defer log.Printf("EVENT: job %s end", event.Job)
+	switch event.Job {
+	case JobPublish:
+		return r.PublishScheduled(util.GetUnixMilli())
+	case JobCloseChallenges:
+		return r.CloseChallenges(util.GetUnixMilli())
+	case JobRankPaintings:
+		return r.RankPaintings(util.GetUnixMilli())
+	case JobBuildExports:
+		return r.BuildExports(util.GetUnixMilli())
+	case JobDeleteAccounts:
+		return r.DeleteAccounts(util.GetUnixMilli())
+	case JobAssignPaintingIds:
+		return r.AssignPaintingIds()
+	case JobMarkRankedPaintings:
+		return r.MarkRankedPaintings(util.GetUnixMilli())
+	default:
+		return errors.New("unknown job: " + event.Job)
+	}
+}
@@ -0,0 +1,47 @@
+package job
+
+import (
+	"errors"
+	"log"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/util"
+)
+
+// AssignPaintingIds gives the paintings submitted before PaintingId existed an id made from their
+// creation time, so the ids sort like the paintings, and reindexes the public ones so listings
+// carry it. Paintings that have an id are skipped, so it is safe to run again.
+func (r *Runner) AssignPaintingIds() error {
+	paintings, err := r.Paintings.ScanPaintings()
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	assigned := 0
+	for i := range paintings {
+		painting := &paintings[i]
+		if painting.PaintingId != "" {
+			continue
+		}
+		painting.PaintingId = util.NewSortableId(createdAt(painting))
+		if err := r.Paintings.SetPaintingId(painting.UserId, painting.Timestamp, painting.PaintingId); err != nil {
+			// deleted, or given an id since the scan.
+			if errors.Is(err, domain.ErrConflict) {
+				continue
+			}
+			log.Printf("failed to assign an id to %s: %s", painting.GetId(), err.Error())
+			lastErr = err
+			continue
+		}
+		if painting.IsPublic() {
+			if err := r.Search.PutEsPainting(painting); err != nil {
+				log.Printf("failed to index the id of %s: %s", painting.GetId(), err.Error())
+				lastErr = err
+				continue
+			}
+		}
+		assigned++
+	}
+	log.Printf("EVENT: assigned ids to %d paintings", assigned)
+	return lastErr
+}
@@ -0,0 +1,40 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/hirosato/wcs/memory"
+	"github.com/hirosato/wcs/model"
+)
+
+func TestAssignPaintingIds(t *testing.T) {
+	store := memory.NewStore()
+	search := memory.NewSearchIndex()
+	r := New(Services{Paintings: store, Search: search})
+	store.AddPainting(&model.Painting{UserId: "u", Timestamp: "20210810150726359"})
+	store.AddPainting(&model.Painting{UserId: "u", Timestamp: "20210811150726359", Created: 1628694446359, Draft: true})
+	store.AddPainting(&model.Painting{UserId: "v", Timestamp: "1", PaintingId: "01ARYZ6S41TSV4RRFFQ69G5FAV"})
+
+	if err := r.AssignPaintingIds(); err != nil {
+		t.Fatal(err)
+	}
+	older, _ := store.GetPainting("u", "20210810150726359")
+	newer, _ := store.GetPainting("u", "20210811150726359")
+	kept, _ := store.GetPainting("v", "1")
+	if older.PaintingId == "" || newer.PaintingId <= older.PaintingId {
+		t.Fatalf("ids do not sort like the paintings: %s %s", older.PaintingId, newer.PaintingId)
+	}
+	if kept.PaintingId != "01ARYZ6S41TSV4RRFFQ69G5FAV" {
+		t.Fatalf("existing id replaced by %s", kept.PaintingId)
+	}
+	if listed := search.ListWaterColorSite(0, "", nil); len(listed) != 1 || listed[0].PaintingId != older.PaintingId {
+		t.Fatalf("public painting not reindexed with its id: %+v", listed)
+	}
+
+	if err := r.AssignPaintingIds(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := store.GetPainting("u", "20210810150726359"); again.PaintingId != older.PaintingId {
+		t.Fatalf("id changed on the second run: %s %s", older.PaintingId, again.PaintingId)
+	}
+}
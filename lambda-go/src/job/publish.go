@@ -0,0 +1,46 @@
+package job
+
+import (
+	"log"
+
+	"github.com/hirosato/wcs/model"
+)
+
+// PublishScheduled publishes every draft whose publish time is at or before now.
+// A painting is marked as publishing before it is indexed and its followers are notified, and both
+// of those steps are idempotent, so a run that overlaps or repeats another one is harmless.
+func (r *Runner) PublishScheduled(now uint64) error {
+	paintings, err := r.Paintings.GetDuePaintings(now)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for i := range paintings {
+		if err := r.publish(&paintings[i], now); err != nil {
+			log.Printf("failed to publish %s: %s", paintings[i].GetId(), err.Error())
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (r *Runner) publish(painting *model.Painting, now uint64) error {
+	if painting.Schedule == model.ScheduleWaiting {
+		started, err := r.Paintings.StartPublishing(painting, now)
+		if err != nil || !started {
+			return err
+		}
+	}
+	if painting.IsPublic() {
+		if err := r.Search.PutEsPainting(painting); err != nil {
+			return err
+		}
+	}
+	if painting.IsVisibleToFollowers() {
+		if err := r.Notifications.NotifyFollowers(painting); err != nil {
+			return err
+		}
+	}
+	log.Printf("EVENT: published %s", painting.GetId())
+	return r.Paintings.FinishPublishing(painting)
+}
@@ -0,0 +1,133 @@
+package job
+
+import (
+	"log"
+	"math"
+
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+// weights of each signal. A comment takes more effort than a like, and a view takes almost none.
+const (
+	likeWeight     = 1.0
+	favoriteWeight = 2.0
+	commentWeight  = 3.0
+	viewWeight     = 0.1
+)
+
+// half-lives of the decayed scores, in milliseconds. The all time score does not decay.
+const (
+	todayHalfLife = 6 * 60 * 60 * 1000
+	weekHalfLife  = 2 * 24 * 60 * 60 * 1000
+)
+
+// points combines the painting's signals into a single undecayed value.
+func points(painting *model.Painting) float64 {
+	return float64(painting.Likes)*likeWeight +
+		float64(painting.Favorits)*favoriteWeight +
+		float64(painting.Comments)*commentWeight +
+		float64(painting.Views)*viewWeight
+}
+
+// publishedAt is when the painting went public. Paintings from before PublishAt existed fall back to
+// their creation time.
+func publishedAt(painting *model.Painting) uint64 {
+	if painting.PublishAt != 0 {
+		return painting.PublishAt
+	}
+	return createdAt(painting)
+}
+
+// createdAt is when the painting was submitted. Paintings from before Created existed fall back to
+// the time encoded in their timestamp.
+func createdAt(painting *model.Painting) uint64 {
+	if painting.Created != 0 {
+		return painting.Created
+	}
+	created, err := util.TimestampToUnixMilli(painting.Timestamp)
+	if err != nil {
+		return 0
+	}
+	return created
+}
+
+func decay(points float64, age uint64, halfLife float64) float64 {
+	return points * math.Pow(0.5, float64(age)/halfLife)
+}
+
+// ComputeScores sets the trending scores of the painting as of now. It only depends on its arguments.
+func ComputeScores(painting *model.Painting, now uint64) {
+	p := points(painting)
+	var age uint64
+	if published := publishedAt(painting); published < now {
+		age = now - published
+	}
+	painting.TrendingToday = decay(p, age, todayHalfLife)
+	painting.TrendingWeek = decay(p, age, weekHalfLife)
+	painting.TrendingAll = p
+}
+
+// rankingWindow is how long after its last reaction or view a painting is still rescored. By then
+// its week score has halved seven times, so one last run settles it and takes it off the ranking
+// index.
+const rankingWindow = 14 * 24 * 60 * 60 * 1000
+
+// RankPaintings recomputes the scores of the paintings on the ranking index and stores them on both
+// the item and, for public ones, the ES document. Paintings without a reaction or view in the last
+// rankingWindow are not on the index and keep their settled scores.
+func (r *Runner) RankPaintings(now uint64) error {
+	paintings, err := r.Paintings.ListRankedPaintings()
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	updated := 0
+	for i := range paintings {
+		painting := &paintings[i]
+		if painting.LastActive+rankingWindow < now {
+			painting.Ranking = ""
+		}
+		ComputeScores(painting, now)
+		if err := r.Paintings.PutPaintingScores(painting); err != nil {
+			log.Printf("failed to store scores of %s: %s", painting.GetId(), err.Error())
+			lastErr = err
+			continue
+		}
+		if painting.IsPublic() {
+			if err := r.Search.UpdateEsScores(painting); err != nil {
+				log.Printf("failed to index scores of %s: %s", painting.GetId(), err.Error())
+				lastErr = err
+				continue
+			}
+		}
+		updated++
+	}
+	log.Printf("EVENT: ranked %d paintings", updated)
+	return lastErr
+}
+
+// MarkRankedPaintings puts the paintings that have any signal on the ranking index, so scores from
+// before the index existed are rescored and settled like the others. It is safe to run again.
+func (r *Runner) MarkRankedPaintings(now uint64) error {
+	paintings, err := r.Paintings.ScanPaintings()
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	marked := 0
+	for i := range paintings {
+		painting := &paintings[i]
+		if painting.Ranking != "" || (points(painting) == 0 && painting.TrendingAll == 0) {
+			continue
+		}
+		if err := r.Paintings.MarkPaintingRanked(painting.UserId, painting.Timestamp, now); err != nil {
+			log.Printf("failed to mark %s for ranking: %s", painting.GetId(), err.Error())
+			lastErr = err
+			continue
+		}
+		marked++
+	}
+	log.Printf("EVENT: marked %d paintings for ranking", marked)
+	return lastErr
+}
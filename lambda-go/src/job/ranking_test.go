@@ -0,0 +1,87 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/hirosato/wcs/memory"
+	"github.com/hirosato/wcs/model"
+)
+
+func TestComputeScores(t *testing.T) {
+	const hour = 60 * 60 * 1000
+	now := uint64(1630000000000)
+
+	t.Run("same input gives the same scores", func(t *testing.T) {
+		a := model.Painting{Likes: 3, Comments: 1, Views: 40, PublishAt: now - 5*hour}
+		b := a
+		ComputeScores(&a, now)
+		ComputeScores(&b, now)
+		if a.TrendingToday != b.TrendingToday || a.TrendingWeek != b.TrendingWeek || a.TrendingAll != b.TrendingAll {
+			t.Fatalf("scores differ: %+v %+v", a, b)
+		}
+	})
+
+	t.Run("newer paintings trend higher with the same signals", func(t *testing.T) {
+		fresh := model.Painting{Likes: 10, PublishAt: now - hour}
+		old := model.Painting{Likes: 10, PublishAt: now - 72*hour}
+		ComputeScores(&fresh, now)
+		ComputeScores(&old, now)
+		if fresh.TrendingToday <= old.TrendingToday || fresh.TrendingWeek <= old.TrendingWeek {
+			t.Fatalf("expected fresh > old, got %+v %+v", fresh, old)
+		}
+		if fresh.TrendingAll != old.TrendingAll {
+			t.Fatalf("all time score should not decay, got %v %v", fresh.TrendingAll, old.TrendingAll)
+		}
+	})
+
+	t.Run("today decays faster than this week", func(t *testing.T) {
+		painting := model.Painting{Favorits: 4, PublishAt: now - 12*hour}
+		ComputeScores(&painting, now)
+		if painting.TrendingToday != 2 || painting.TrendingWeek <= painting.TrendingToday {
+			t.Fatalf("unexpected scores %+v", painting)
+		}
+	})
+
+	t.Run("old paintings fall back to their timestamp", func(t *testing.T) {
+		painting := model.Painting{Likes: 1, Timestamp: "20210826114640000"}
+		ComputeScores(&painting, now)
+		if painting.TrendingToday != 0.5 {
+			t.Fatalf("expected one half-life of age, got %v", painting.TrendingToday)
+		}
+	})
+}
+
+func TestRankPaintings(t *testing.T) {
+	const hour = 60 * 60 * 1000
+	now := uint64(1630000000000)
+	store := memory.NewStore()
+	r := New(Services{Paintings: store, Search: memory.NewSearchIndex()})
+	store.AddPainting(&model.Painting{UserId: "u", Timestamp: "1", Likes: 2, PublishAt: now - hour})
+	store.AddPainting(&model.Painting{UserId: "u", Timestamp: "2", Likes: 2, PublishAt: now - 30*24*hour})
+	store.AddPainting(&model.Painting{UserId: "u", Timestamp: "3", Likes: 2, PublishAt: now - hour})
+	store.MarkPaintingRanked("u", "1", now-hour)
+	store.MarkPaintingRanked("u", "2", now-rankingWindow-hour)
+
+	if err := r.RankPaintings(now); err != nil {
+		t.Fatal(err)
+	}
+	active, _ := store.GetPainting("u", "1")
+	settled, _ := store.GetPainting("u", "2")
+	unranked, _ := store.GetPainting("u", "3")
+	if active.TrendingAll != 2 || active.Ranking != model.RankingActive {
+		t.Fatalf("unexpected active painting %+v", active)
+	}
+	if settled.TrendingAll != 2 || settled.Ranking != "" {
+		t.Fatalf("expected the quiet painting scored and off the index, got %+v", settled)
+	}
+	if unranked.TrendingAll != 0 {
+		t.Fatalf("a painting off the index was rescored: %+v", unranked)
+	}
+
+	if err := r.MarkRankedPaintings(now); err != nil {
+		t.Fatal(err)
+	}
+	if ranked, _ := store.ListRankedPaintings(); len(ranked) != 3 {
+		t.Fatalf("expected every painting with a signal on the index, got %+v", ranked)
+	}
+}
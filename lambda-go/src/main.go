@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,8 +12,17 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	ginadapter "github.com/awslabs/aws-lambda-go-api-proxy/gin"
 	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/auth"
+	"github.com/hirosato/wcs/db"
+	"github.com/hirosato/wcs/domain"
 	"github.com/hirosato/wcs/env"
+	"github.com/hirosato/wcs/file"
 	"github.com/hirosato/wcs/handler"
+	"github.com/hirosato/wcs/job"
+	"github.com/hirosato/wcs/memory"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/session"
+	"github.com/hirosato/wcs/twitter"
 )
 
 var ginLambda *ginadapter.GinLambda
@@ -21,28 +31,318 @@ func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.API
 	return ginLambda.ProxyWithContext(ctx, req)
 }
 
-func main() {
-	fmt.Println("IS_LOCAL", os.Getenv("IS_LOCAL"))
-	log.Printf("Gin cold start")
+// store is every repository at once, which db.Store and memory.Store both are.
+type store interface {
+	domain.PaintingRepository
+	domain.UserRepository
+	domain.SessionStore
+	domain.FollowRepository
+	domain.NotificationRepository
+	domain.ReactionRepository
+	domain.CollectionRepository
+	domain.ChallengeRepository
+	domain.IdentityRepository
+	domain.TokenRepository
+	domain.AuditLog
+	domain.ReportRepository
+	domain.RelationRepository
+	domain.ExportRepository
+	domain.IdempotencyStore
+}
+
+type backends struct {
+	store       store
+	search      domain.SearchIndex
+	pigments    domain.PigmentCatalog
+	blobs       domain.BlobStore
+	exportFiles domain.ExportStore
+	cdn         domain.CdnRepository
+	providers   []auth.Provider
+}
+
+// awsBackends builds the real backends for config. Nothing connects until the first request.
+func awsBackends(config env.Config) (backends, error) {
+	search, err := db.NewSearchIndex(config)
+	if err != nil {
+		return backends{}, err
+	}
+	pigments, err := db.OpenPigmentCatalog(config.SqlitePath)
+	if err != nil {
+		return backends{}, err
+	}
+	providers := []auth.Provider{}
+	if config.TwitterConsumerKey != "" {
+		providers = append(providers, twitter.NewProvider(config.TwitterConsumerKey, config.TwitterConsumerSecret))
+	}
+	if config.GoogleClientId != "" {
+		providers = append(providers, auth.NewGoogleProvider(config.GoogleClientId, config.GoogleClientSecret))
+	}
+	if config.GitHubClientId != "" {
+		providers = append(providers, auth.NewGitHubProvider(config.GitHubClientId, config.GitHubClientSecret))
+	}
+	return backends{
+		store:       db.NewStore(config),
+		search:      search,
+		pigments:    pigments,
+		blobs:       file.NewS3RepositoryImpl(config),
+		exportFiles: file.NewExportStore(config),
+		cdn:         file.NewCloudFrontRepository(config),
+		providers:   providers,
+	}, nil
+}
+
+// memoryBackends keeps everything in memory and signs in with memory.Provider, which needs no
+// network. Only the pigment catalog is still read from its SQLite file.
+func memoryBackends(config env.Config) (backends, error) {
+	pigments, err := db.OpenPigmentCatalog(config.SqlitePath)
+	if err != nil {
+		return backends{}, err
+	}
+	return backends{
+		store:       memory.NewStore(),
+		search:      memory.NewSearchIndex(),
+		pigments:    pigments,
+		blobs:       memory.NewBlobStore(),
+		exportFiles: memory.NewExportStore(),
+		cdn:         &memory.Cdn{},
+		providers:   []auth.Provider{memory.Provider{}},
+	}, nil
+}
+
+func services(config env.Config, b backends) (handler.Services, job.Services) {
+	sessions := session.NewManager(b.store, config)
+	handlerServices := handler.Services{
+		Config:        config,
+		Auth:          auth.NewRegistry(b.providers...),
+		Sessions:      sessions,
+		SessionStore:  b.store,
+		Paintings:     b.store,
+		Users:         b.store,
+		Follows:       b.store,
+		Notifications: b.store,
+		Reactions:     b.store,
+		Collections:   b.store,
+		Challenges:    b.store,
+		Identities:    b.store,
+		Tokens:        b.store,
+		Audit:         b.store,
+		Reports:       b.store,
+		Relations:     b.store,
+		Exports:       b.store,
+		Idempotency:   b.store,
+		Search:        b.search,
+		Pigments:      b.pigments,
+		LocalFiles:    file.NewLocalFileRepository(),
+		Blobs:         b.blobs,
+		ExportFiles:   b.exportFiles,
+		Cdn:           b.cdn,
+	}
+	jobServices := job.Services{
+		Sessions:      sessions,
+		Paintings:     b.store,
+		Users:         b.store,
+		Follows:       b.store,
+		Notifications: b.store,
+		Reactions:     b.store,
+		Collections:   b.store,
+		Challenges:    b.store,
+		Identities:    b.store,
+		Tokens:        b.store,
+		Relations:     b.store,
+		Exports:       b.store,
+		Search:        b.search,
+		Blobs:         b.blobs,
+		ExportFiles:   b.exportFiles,
+	}
+	return handlerServices, jobServices
+}
+
+// newRouter registers every API route on a new engine.
+func newRouter(h *handler.Handler) *gin.Engine {
 	r := gin.Default()
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "pong",
 		})
 	})
-	r.GET("/wcs", handler.AddCorsHeader, handler.ServePaintingList)
-	r.GET("/wcs/:id", handler.AddCorsHeader, handler.ServeUserPainting)
-	r.GET("/wcs/:id/:timestamp", handler.AddCorsHeader, handler.ServePainting)
-	r.PATCH("/wcs/:id/:timestamp/images", handler.AddCorsHeader, handler.PatchPaintingImage)
-	r.OPTIONS("/wcs/:id/:timestamp/images", handler.AddCorsHeader, handler.ServeSubmitPreflight)
-	r.GET("/equipments", handler.AddCorsHeader, handler.ServePigmentSearch)
-	r.POST("/wcs", handler.AddCorsHeader, handler.Submit)
-	r.POST("/invalidate", handler.AddCorsHeader, handler.InvalidatePainting)
-	r.OPTIONS("/wcs", handler.AddCorsHeader, handler.ServeSubmitPreflight)
-	r.GET("/getUser", handler.AddCorsHeader, handler.ServeGetUser)
-	r.GET("twitter/signin", handler.AddCorsHeader, handler.Login)
-	r.GET("twitter/callback", handler.AddCorsHeader, handler.Callback)
-	if env.IsLocal {
+	r.GET("/wcs", h.AddCorsHeader, h.ServePaintingList)
+	r.GET("/wcs/:id", h.AddCorsHeader, h.AllowToken(model.ScopeRead), h.ServeUserPainting)
+	r.GET("/wcs/:id/:timestamp", h.AddCorsHeader, h.AllowToken(model.ScopeRead), handler.ByDate(h.ServeDatePaintings, h.ServePainting))
+	r.GET("/archive/:yyyymm", h.AddCorsHeader, h.ServeArchive)
+	r.PATCH("/wcs/:id/:timestamp", h.AddCorsHeader, h.AllowToken(model.ScopeWritePaintings), h.VerifyCsrf, h.UpdatePainting)
+	r.OPTIONS("/wcs/:id/:timestamp", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PATCH("/wcs/:id/:timestamp/images", h.AddCorsHeader, h.AllowToken(model.ScopeUploadImages), h.VerifyCsrf, h.Idempotent, h.PatchPaintingImage)
+	r.OPTIONS("/wcs/:id/:timestamp/images", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/wcs/:id/:timestamp/publish", h.AddCorsHeader, h.AllowToken(model.ScopeWritePaintings), h.VerifyCsrf, h.PublishPainting)
+	r.DELETE("/wcs/:id/:timestamp/publish", h.AddCorsHeader, h.AllowToken(model.ScopeWritePaintings), h.VerifyCsrf, h.CancelPublishPainting)
+	r.OPTIONS("/wcs/:id/:timestamp/publish", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/wcs/:id/:timestamp/like", h.AddCorsHeader, h.VerifyCsrf, h.LikePainting)
+	r.DELETE("/wcs/:id/:timestamp/like", h.AddCorsHeader, h.VerifyCsrf, h.UnlikePainting)
+	r.OPTIONS("/wcs/:id/:timestamp/like", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/wcs/:id/:timestamp/favorite", h.AddCorsHeader, h.VerifyCsrf, h.FavoritePainting)
+	r.DELETE("/wcs/:id/:timestamp/favorite", h.AddCorsHeader, h.VerifyCsrf, h.UnfavoritePainting)
+	r.OPTIONS("/wcs/:id/:timestamp/favorite", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/wcs/:id/:timestamp/comments", h.AddCorsHeader, h.ServeComments)
+	r.POST("/wcs/:id/:timestamp/comments", h.AddCorsHeader, h.VerifyCsrf, h.PostComment)
+	r.OPTIONS("/wcs/:id/:timestamp/comments", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.DELETE("/wcs/:id/:timestamp/comments/:commentId", h.AddCorsHeader, h.VerifyCsrf, h.DeleteComment)
+	r.OPTIONS("/wcs/:id/:timestamp/comments/:commentId", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	// the painting routes again by the painting's id alone. The ones above stay for old links.
+	r.GET("/paintings/:paintingId", h.AddCorsHeader, h.AllowToken(model.ScopeRead), h.ResolvePainting, h.ServePainting)
+	r.PATCH("/paintings/:paintingId", h.AddCorsHeader, h.AllowToken(model.ScopeWritePaintings), h.VerifyCsrf, h.ResolvePainting, h.UpdatePainting)
+	r.OPTIONS("/paintings/:paintingId", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PATCH("/paintings/:paintingId/images", h.AddCorsHeader, h.AllowToken(model.ScopeUploadImages), h.VerifyCsrf, h.Idempotent, h.ResolvePainting, h.PatchPaintingImage)
+	r.OPTIONS("/paintings/:paintingId/images", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/paintings/:paintingId/publish", h.AddCorsHeader, h.AllowToken(model.ScopeWritePaintings), h.VerifyCsrf, h.ResolvePainting, h.PublishPainting)
+	r.DELETE("/paintings/:paintingId/publish", h.AddCorsHeader, h.AllowToken(model.ScopeWritePaintings), h.VerifyCsrf, h.ResolvePainting, h.CancelPublishPainting)
+	r.OPTIONS("/paintings/:paintingId/publish", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/paintings/:paintingId/like", h.AddCorsHeader, h.VerifyCsrf, h.ResolvePainting, h.LikePainting)
+	r.DELETE("/paintings/:paintingId/like", h.AddCorsHeader, h.VerifyCsrf, h.ResolvePainting, h.UnlikePainting)
+	r.OPTIONS("/paintings/:paintingId/like", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/paintings/:paintingId/favorite", h.AddCorsHeader, h.VerifyCsrf, h.ResolvePainting, h.FavoritePainting)
+	r.DELETE("/paintings/:paintingId/favorite", h.AddCorsHeader, h.VerifyCsrf, h.ResolvePainting, h.UnfavoritePainting)
+	r.OPTIONS("/paintings/:paintingId/favorite", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/paintings/:paintingId/comments", h.AddCorsHeader, h.ResolvePainting, h.ServeComments)
+	r.POST("/paintings/:paintingId/comments", h.AddCorsHeader, h.VerifyCsrf, h.ResolvePainting, h.PostComment)
+	r.OPTIONS("/paintings/:paintingId/comments", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.DELETE("/paintings/:paintingId/comments/:commentId", h.AddCorsHeader, h.VerifyCsrf, h.ResolvePainting, h.DeleteComment)
+	r.OPTIONS("/paintings/:paintingId/comments/:commentId", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/equipments", h.AddCorsHeader, h.ServePigmentSearch)
+	r.POST("/wcs", h.AddCorsHeader, h.AllowToken(model.ScopeWritePaintings), h.VerifyCsrf, h.Idempotent, h.Submit)
+	r.OPTIONS("/wcs", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/getUser", h.AddCorsHeader, h.AllowToken(model.ScopeRead), h.ServeGetUser)
+	r.GET("/csrf", h.AddCorsHeader, h.ServeCsrfToken)
+	r.GET("/notifications", h.AddCorsHeader, h.AllowToken(model.ScopeRead), h.ServeNotifications)
+	r.PUT("/users/:id/follow", h.AddCorsHeader, h.VerifyCsrf, h.Follow)
+	r.DELETE("/users/:id/follow", h.AddCorsHeader, h.VerifyCsrf, h.Unfollow)
+	r.OPTIONS("/users/:id/follow", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/challenges", h.AddCorsHeader, h.ServeChallengeList)
+	r.POST("/challenges", h.AddCorsHeader, h.VerifyCsrf, h.CreateChallenge)
+	r.OPTIONS("/challenges", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/challenges/:challengeId", h.AddCorsHeader, h.ServeChallenge)
+	r.GET("/challenges/:challengeId/entries", h.AddCorsHeader, h.ServeChallengeEntries)
+	r.POST("/challenges/:challengeId/entries", h.AddCorsHeader, h.VerifyCsrf, h.SubmitChallengeEntry)
+	r.OPTIONS("/challenges/:challengeId/entries", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.DELETE("/challenges/:challengeId/entries/:entryId", h.AddCorsHeader, h.VerifyCsrf, h.WithdrawChallengeEntry)
+	r.OPTIONS("/challenges/:challengeId/entries/:entryId", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/challenges/:challengeId/entries/:entryId/vote", h.AddCorsHeader, h.VerifyCsrf, h.VoteChallengeEntry)
+	r.OPTIONS("/challenges/:challengeId/entries/:entryId/vote", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/challenges/:challengeId/leaderboard", h.AddCorsHeader, h.ServeChallengeLeaderboard)
+	r.GET("/winners", h.AddCorsHeader, h.ServeChallengeWinners)
+	r.GET("/users/:id/collections", h.AddCorsHeader, h.ServeUserCollections)
+	r.POST("/collections", h.AddCorsHeader, h.VerifyCsrf, h.CreateCollection)
+	r.OPTIONS("/collections", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/collections/:userId/:collectionId", h.AddCorsHeader, h.ServeCollection)
+	r.PATCH("/collections/:userId/:collectionId", h.AddCorsHeader, h.VerifyCsrf, h.UpdateCollection)
+	r.DELETE("/collections/:userId/:collectionId", h.AddCorsHeader, h.VerifyCsrf, h.DeleteCollection)
+	r.OPTIONS("/collections/:userId/:collectionId", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/collections/:userId/:collectionId/paintings", h.AddCorsHeader, h.VerifyCsrf, h.AddCollectionPainting)
+	r.OPTIONS("/collections/:userId/:collectionId/paintings", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.DELETE("/collections/:userId/:collectionId/paintings/:id/:timestamp", h.AddCorsHeader, h.VerifyCsrf, h.RemoveCollectionPainting)
+	r.OPTIONS("/collections/:userId/:collectionId/paintings/:id/:timestamp", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/collections/:userId/:collectionId/order", h.AddCorsHeader, h.VerifyCsrf, h.ReorderCollection)
+	r.OPTIONS("/collections/:userId/:collectionId/order", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/logout", h.AddCorsHeader, h.VerifyCsrf, h.Logout)
+	r.OPTIONS("/logout", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/logout/all", h.AddCorsHeader, h.VerifyCsrf, h.LogoutAll)
+	r.OPTIONS("/logout/all", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/sessions", h.AddCorsHeader, h.ServeSessions)
+	r.DELETE("/sessions/:handle", h.AddCorsHeader, h.VerifyCsrf, h.RevokeSession)
+	r.OPTIONS("/sessions/:handle", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("twitter/signin", h.AddCorsHeader, h.Login)
+	r.GET("twitter/callback", h.AddCorsHeader, h.Callback)
+	r.GET("/auth", h.AddCorsHeader, h.ServeAuthProviders)
+	r.GET("/auth/:provider/signin", h.AddCorsHeader, h.Signin)
+	r.GET("/auth/:provider/callback", h.AddCorsHeader, h.AuthCallback)
+	moderator := h.RequireRole(model.RoleModerator)
+	admin := h.RequireRole(model.RoleAdmin)
+	r.PUT("/admin/paintings/:id/:timestamp/hidden", h.AddCorsHeader, h.VerifyCsrf, moderator, h.HidePainting)
+	r.DELETE("/admin/paintings/:id/:timestamp/hidden", h.AddCorsHeader, h.VerifyCsrf, moderator, h.UnhidePainting)
+	r.OPTIONS("/admin/paintings/:id/:timestamp/hidden", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/admin/paintings/:id/:timestamp/reindex", h.AddCorsHeader, h.VerifyCsrf, admin, h.ReindexPainting)
+	r.OPTIONS("/admin/paintings/:id/:timestamp/reindex", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/admin/invalidate", h.AddCorsHeader, h.VerifyCsrf, admin, h.InvalidatePainting)
+	r.OPTIONS("/admin/invalidate", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/admin/users/:id/suspended", h.AddCorsHeader, h.VerifyCsrf, admin, h.SuspendUser)
+	r.DELETE("/admin/users/:id/suspended", h.AddCorsHeader, h.VerifyCsrf, admin, h.UnsuspendUser)
+	r.OPTIONS("/admin/users/:id/suspended", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/admin/users/:id/role", h.AddCorsHeader, h.VerifyCsrf, admin, h.SetUserRole)
+	r.OPTIONS("/admin/users/:id/role", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/admin/audit", h.AddCorsHeader, admin, h.ServeAuditLog)
+	r.POST("/reports", h.AddCorsHeader, h.VerifyCsrf, h.PostReport)
+	r.OPTIONS("/reports", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/moderation", h.AddCorsHeader, moderator, h.ServeModerationQueue)
+	r.GET("/moderation/:itemId", h.AddCorsHeader, moderator, h.ServeModerationItem)
+	r.POST("/moderation/:itemId/claim", h.AddCorsHeader, h.VerifyCsrf, moderator, h.ClaimModerationItem)
+	r.OPTIONS("/moderation/:itemId/claim", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/moderation/:itemId/resolve", h.AddCorsHeader, h.VerifyCsrf, moderator, h.ResolveModerationItem)
+	r.OPTIONS("/moderation/:itemId/resolve", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/moderation/:itemId/restore", h.AddCorsHeader, h.VerifyCsrf, moderator, h.RestoreModerationItem)
+	r.OPTIONS("/moderation/:itemId/restore", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/users/:id", h.AddCorsHeader, h.ServeProfile)
+	r.PATCH("/profile", h.AddCorsHeader, h.VerifyCsrf, h.UpdateProfile)
+	r.OPTIONS("/profile", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/profile/avatar", h.AddCorsHeader, h.AllowToken(model.ScopeUploadImages), h.VerifyCsrf, h.PutAvatar)
+	r.DELETE("/profile/avatar", h.AddCorsHeader, h.VerifyCsrf, h.DeleteAvatar)
+	r.OPTIONS("/profile/avatar", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/profile/header", h.AddCorsHeader, h.AllowToken(model.ScopeUploadImages), h.VerifyCsrf, h.PutHeader)
+	r.DELETE("/profile/header", h.AddCorsHeader, h.VerifyCsrf, h.DeleteHeader)
+	r.OPTIONS("/profile/header", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/users/:id/block", h.AddCorsHeader, h.VerifyCsrf, h.Block)
+	r.DELETE("/users/:id/block", h.AddCorsHeader, h.VerifyCsrf, h.Unblock)
+	r.OPTIONS("/users/:id/block", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.PUT("/users/:id/mute", h.AddCorsHeader, h.VerifyCsrf, h.Mute)
+	r.DELETE("/users/:id/mute", h.AddCorsHeader, h.VerifyCsrf, h.Unmute)
+	r.OPTIONS("/users/:id/mute", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/blocks", h.AddCorsHeader, h.ServeBlocks)
+	r.GET("/mutes", h.AddCorsHeader, h.ServeMutes)
+	r.GET("/tokens", h.AddCorsHeader, h.ServeTokens)
+	r.POST("/tokens", h.AddCorsHeader, h.VerifyCsrf, h.CreateToken)
+	r.OPTIONS("/tokens", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.DELETE("/tokens/:tokenId", h.AddCorsHeader, h.VerifyCsrf, h.RevokeToken)
+	r.OPTIONS("/tokens/:tokenId", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/identities", h.AddCorsHeader, h.ServeIdentities)
+	r.DELETE("/identities/:provider", h.AddCorsHeader, h.VerifyCsrf, h.UnlinkIdentity)
+	r.OPTIONS("/identities/:provider", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.GET("/export", h.AddCorsHeader, h.ServeExports)
+	r.POST("/export", h.AddCorsHeader, h.VerifyCsrf, h.CreateExport)
+	r.OPTIONS("/export", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	r.POST("/account/deletion", h.AddCorsHeader, h.VerifyCsrf, h.ScheduleDeletion)
+	r.DELETE("/account/deletion", h.AddCorsHeader, h.VerifyCsrf, h.CancelDeletion)
+	r.OPTIONS("/account/deletion", h.AddCorsHeader, handler.ServeSubmitPreflight)
+	return r
+}
+
+func main() {
+	mode := flag.String("mode", "", "backends to run with: aws, or memory for no AWS and no network. Overrides WCS_MODE.")
+	flag.Parse()
+	if *mode != "" {
+		os.Setenv("WCS_MODE", *mode)
+	}
+	config, err := env.Load()
+	if err == nil {
+		err = config.Validate()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	build := awsBackends
+	if config.IsMemory() {
+		build = memoryBackends
+	}
+	b, err := build(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	handlerServices, jobServices := services(config, b)
+	if config.LambdaHandler == "scheduler" {
+		lambda.Start(job.New(jobServices).Run)
+		return
+	}
+	fmt.Println("profile", config.Profile, "mode", config.Mode, "systemEnv", config.SystemEnv)
+	log.Printf("Gin cold start")
+	r := newRouter(handler.New(handlerServices))
+	if config.IsLocal() || config.IsMemory() {
 		log.Fatal(http.ListenAndServe(":8080", r))
 	} else {
 		ginLambda = ginadapter.New(r)
@@ -1,18 +1,850 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hirosato/wcs/auth"
+	"github.com/hirosato/wcs/env"
+	"github.com/hirosato/wcs/handler"
+	"github.com/hirosato/wcs/memory"
+	"github.com/hirosato/wcs/model"
 )
 
+// go test -run TestHandler -update rewrites testdata/*.golden.json from the current responses.
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+const frontUrl = "http://front.test"
+
+// fakePigments stands in for the SQLite catalog, which is not checked in.
+type fakePigments struct{}
+
+func (fakePigments) GetPigment(lang model.SupportedLang, filtergroup int32, name string) (*[]model.Pigment, error) {
+	result := []model.Pigment{}
+	for _, pigment := range []model.Pigment{{Key: 1, Name: "Ultramarine"}, {Key: 2, Name: "Ultramarine Violet"}, {Key: 3, Name: "Burnt Sienna"}} {
+		if filtergroup == 1 && strings.HasPrefix(pigment.Name, name) {
+			result = append(result, pigment)
+		}
+	}
+	return &result, nil
+}
+
+func (fakePigments) GetPigmentsByKeys(lang model.SupportedLang, keys []int32) ([]model.Pigment, error) {
+	return []model.Pigment{}, nil
+}
+
+// newOAuthServer fakes an OpenID Connect provider. It hands out a token for the code "good" only.
+func newOAuthServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good" || r.FormValue("code_verifier") == "" || r.FormValue("client_secret") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"sub": "painter-1", "name": "Painter", "picture": "https://avatar.test/painter.png"})
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// client sends requests to the router like a browser on the front end, keeping the session cookie.
+// Cookies are kept by hand because the session cookie is Secure and the router is served over http.
+type client struct {
+	t       *testing.T
+	router  http.Handler
+	cookies map[string]string
+	csrf    string
+	// sent with every request on top of the ones do always sets.
+	header http.Header
+}
+
+func (c *client) do(method string, path string, body interface{}) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", frontUrl)
+	req.Header.Set("User-Agent", "e2e")
+	if c.csrf != "" {
+		req.Header.Set("X-CSRF-Token", c.csrf)
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	for name, value := range c.cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, req)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+		} else {
+			c.cookies[cookie.Name] = cookie.Value
+		}
+	}
+	return w
+}
+
+// signin starts signing in through the fake provider, which gives the client a session that is
+// not logged in yet, and returns the state the provider would send back.
+func (c *client) signin() string {
+	w := c.do("GET", "/auth/fake/signin", nil)
+	if w.Code != http.StatusFound {
+		c.t.Fatalf("signin: %d %s", w.Code, w.Body.String())
+	}
+	redirect, _ := url.Parse(w.Header().Get("Location"))
+	return redirect.Query().Get("state")
+}
+
+// login signs in through the fake provider and fetches a CSRF token for the new session.
+func (c *client) login() {
+	w := c.do("GET", "/auth/fake/callback?code=good&state="+url.QueryEscape(c.signin()), nil)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != frontUrl {
+		c.t.Fatalf("callback: %d %s", w.Code, w.Body.String())
+	}
+	c.fetchCsrf()
+}
+
+func (c *client) fetchCsrf() {
+	w := c.do("GET", "/csrf", nil)
+	var token struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &token); err != nil || token.Token == "" {
+		c.t.Fatalf("csrf: %d %s", w.Code, w.Body.String())
+	}
+	c.csrf = token.Token
+}
+
+type fixture struct {
+	router  http.Handler
+	handler *handler.Handler
+	oauth   *httptest.Server
+	blobs   *memory.BlobStore
+	store   *memory.Store
+	search  *memory.SearchIndex
+}
+
+func newFixture(t *testing.T) *fixture {
+	gin.SetMode(gin.TestMode)
+	oauth := newOAuthServer(t)
+	config := env.Config{
+		Profile:   env.ProfileDev,
+		Mode:      env.ModeMemory,
+		SystemEnv: "test",
+		FrontUrl:  frontUrl,
+		ApiUrl:    "http://api.test",
+		ImageUrl:  "http://img.test",
+	}
+	blobs := memory.NewBlobStore()
+	store := memory.NewStore()
+	search := memory.NewSearchIndex()
+	handlerServices, _ := services(config, backends{
+		store:       store,
+		search:      search,
+		pigments:    fakePigments{},
+		blobs:       blobs,
+		exportFiles: memory.NewExportStore(),
+		cdn:         &memory.Cdn{},
+		providers:   []auth.Provider{auth.NewOIDCProvider("fake", oauth.URL, "client", "secret")},
+	})
+	h := handler.New(handlerServices)
+	return &fixture{router: newRouter(h), handler: h, oauth: oauth, blobs: blobs, store: store, search: search}
+}
+
+func (f *fixture) client(t *testing.T) *client {
+	return &client{t: t, router: f.router, cookies: map[string]string{}, header: http.Header{}}
+}
+
+// millisKeys hold times that change on every run.
+var millisKeys = map[string]bool{"created": true, "updated": true}
+
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if n, ok := item.(float64); ok && millisKeys[key] && n != 0 {
+				v[key] = "<millis>"
+			} else {
+				v[key] = normalize(item)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+	}
+	return value
+}
+
+// assertGolden compares the response with testdata/<name>.golden.json. Each of replace's keys is
+// swapped for its value first, so ids and timestamps minted during the test read the same every run.
+func assertGolden(t *testing.T, name string, w *httptest.ResponseRecorder, replace map[string]string) {
+	t.Helper()
+	body := w.Body.String()
+	// longest first, since a date is also the start of a timestamp.
+	froms := []string{}
+	for from := range replace {
+		if from != "" {
+			froms = append(froms, from)
+		}
+	}
+	sort.Slice(froms, func(i, j int) bool { return len(froms[i]) > len(froms[j]) })
+	for _, from := range froms {
+		body = strings.ReplaceAll(body, from, replace[from])
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		t.Fatalf("%s: not JSON: %s", name, body)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]interface{}{"status": w.Code, "body": normalize(decoded)}); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.Bytes()
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s: %s (run with -update to create it)", name, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s does not match %s:\n%s", name, path, got)
+	}
+}
+
+// pngDataURL encodes a size by size image of noise, which PNG cannot compress much.
+func pngDataURL(t *testing.T, size int) string {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	noise := rand.New(rand.NewSource(1))
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(noise.Intn(256)), G: uint8(noise.Intn(256)), B: uint8(noise.Intn(256)), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
 func TestHandler(t *testing.T) {
-	t.Run("Unable to get IP", func(t *testing.T) {
-		// DefaultHTTPGetAddress = "http://127.0.0.1:12345"
+	t.Run("login callback and getUser", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		assertGolden(t, "getuser_anonymous", c.do("GET", "/getUser", nil), nil)
+		c.login()
+		w := c.do("GET", "/getUser", nil)
+		var user model.User
+		json.Unmarshal(w.Body.Bytes(), &user)
+		assertGolden(t, "getuser", w, map[string]string{user.UserId: "<userId>"})
+
+		// signing in again with the same account finds the same user.
+		again := f.client(t)
+		again.login()
+		var same model.User
+		json.Unmarshal(again.do("GET", "/getUser", nil).Body.Bytes(), &same)
+		if same.UserId != user.UserId {
+			t.Fatalf("second login made a new user: %s and %s", user.UserId, same.UserId)
+		}
+	})
+
+	t.Run("login callback failures", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		assertGolden(t, "callback_without_signin", c.do("GET", "/auth/fake/callback?code=good&state=forged", nil), nil)
+
+		state := url.QueryEscape(c.signin())
+		assertGolden(t, "callback_wrong_state", c.do("GET", "/auth/fake/callback?code=good&state=wrong", nil), nil)
+		assertGolden(t, "callback_bad_code", c.do("GET", "/auth/fake/callback?code=bad&state="+state, nil), map[string]string{f.oauth.URL: "<oauth>"})
+		assertGolden(t, "callback_denied", c.do("GET", "/auth/fake/callback?error=access_denied&state="+state, nil), nil)
+		assertGolden(t, "callback_unknown_provider", c.do("GET", "/auth/nope/callback", nil), nil)
+		assertGolden(t, "getuser_after_failures", c.do("GET", "/getUser", nil), nil)
+	})
+
+	t.Run("sessions list live devices by the address the proxy saw", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.9")
+		c.login()
+		var user model.User
+		json.Unmarshal(c.do("GET", "/getUser", nil).Body.Bytes(), &user)
+		// expired, but not swept by the TTL yet.
+		expired := model.Session{SessionId: "expired", UserId: user.UserId, UserAgent: "e2e", LastSeen: 1, ExpiresAt: 2}
+		f.store.PutSession(expired)
+		var sessions struct {
+			Results []struct {
+				IpAddress string `json:"ipAddress"`
+				Current   bool   `json:"current"`
+			} `json:"results"`
+		}
+		json.Unmarshal(c.do("GET", "/sessions", nil).Body.Bytes(), &sessions)
+		if len(sessions.Results) != 1 || !sessions.Results[0].Current || sessions.Results[0].IpAddress != "203.0.113.9" {
+			t.Fatalf("unexpected sessions %+v", sessions)
+		}
+		if w := c.do("DELETE", "/sessions/"+expired.Handle(), nil); w.Code != http.StatusNotFound {
+			t.Fatalf("revoked an expired session: %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("api tokens are held to their scopes and skip csrf", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		var user model.User
+		json.Unmarshal(c.do("GET", "/getUser", nil).Body.Bytes(), &user)
+		bearer := func(scopes ...model.TokenScope) (*client, string) {
+			var created struct {
+				Token    string         `json:"token"`
+				ApiToken model.ApiToken `json:"apiToken"`
+			}
+			json.Unmarshal(c.do("POST", "/tokens", map[string]interface{}{"name": "script", "scopes": scopes}).Body.Bytes(), &created)
+			script := f.client(t)
+			script.header.Set("Authorization", "Bearer "+created.Token)
+			return script, created.ApiToken.TokenId
+		}
+
+		// no cookie and no X-CSRF-Token.
+		writer, _ := bearer(model.ScopeWritePaintings)
+		if w := writer.do("POST", "/wcs", map[string]string{"title": "Scripted"}); w.Code != http.StatusOK {
+			t.Fatalf("submit with a token: %d %s", w.Code, w.Body.String())
+		}
+		if w := writer.do("GET", "/getUser", nil); w.Code != http.StatusForbidden {
+			t.Fatalf("read without the read scope: %d %s", w.Code, w.Body.String())
+		}
+		if w := writer.do("GET", "/tokens", nil); w.Code != http.StatusForbidden {
+			t.Fatalf("a token listed tokens: %d %s", w.Code, w.Body.String())
+		}
 
-		// _, err := Handler(events.APIGatewayProxyRequest{})
-		// if err == nil {
-		// 	t.Fatal("Error failed to trigger with an invalid request")
-		// }
-		// t.Logf("fefefe")
+		reader, readerId := bearer(model.ScopeRead)
+		if w := reader.do("GET", "/getUser", nil); w.Code != http.StatusOK {
+			t.Fatalf("read with a token: %d %s", w.Code, w.Body.String())
+		}
+		if w := reader.do("POST", "/wcs", map[string]string{"title": "Scripted"}); w.Code != http.StatusForbidden {
+			t.Fatalf("submit without the write scope: %d %s", w.Code, w.Body.String())
+		}
+		if w := c.do("DELETE", "/tokens/"+readerId, nil); w.Code != http.StatusNoContent {
+			t.Fatalf("revoke: %d %s", w.Code, w.Body.String())
+		}
+		if w := reader.do("GET", "/getUser", nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("read with a revoked token: %d %s", w.Code, w.Body.String())
+		}
+
+		f.store.PutApiToken(model.ApiToken{TokenHash: model.HashApiToken("wcs_expired"), TokenId: "expired", UserId: user.UserId, Scopes: []model.TokenScope{model.ScopeRead}, ExpiresAt: 1})
+		expired := f.client(t)
+		expired.header.Set("Authorization", "Bearer wcs_expired")
+		if w := expired.do("GET", "/getUser", nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("read with an expired token: %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("admin routes need the role and leave an audit trail", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		var user model.User
+		json.Unmarshal(c.do("GET", "/getUser", nil).Body.Bytes(), &user)
+		f.store.PutUser(model.User{UserId: "target"})
+		f.store.AddPainting(&model.Painting{UserId: "target", Timestamp: "1", Title: "Harbor"})
+		hide := func() *httptest.ResponseRecorder {
+			return c.do("PUT", "/admin/paintings/target/1/hidden", map[string]string{"reason": "spam"})
+		}
+		suspend := func() *httptest.ResponseRecorder {
+			return c.do("PUT", "/admin/users/target/suspended", map[string]string{"reason": "spam"})
+		}
+
+		for name, w := range map[string]*httptest.ResponseRecorder{
+			"hide":       hide(),
+			"suspend":    suspend(),
+			"audit":      c.do("GET", "/admin/audit", nil),
+			"moderation": c.do("GET", "/moderation", nil),
+		} {
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("%s as a plain user: %d %s", name, w.Code, w.Body.String())
+			}
+		}
+		f.store.SetUserRole(user.UserId, model.RoleModerator)
+		if w := hide(); w.Code != http.StatusOK {
+			t.Fatalf("hide as a moderator: %d %s", w.Code, w.Body.String())
+		}
+		if w := suspend(); w.Code != http.StatusForbidden {
+			t.Fatalf("suspend as a moderator: %d %s", w.Code, w.Body.String())
+		}
+		f.store.SetUserRole(user.UserId, model.RoleAdmin)
+		// audit ids start with a millisecond timestamp; keep the two entries apart so the order holds
+		time.Sleep(2 * time.Millisecond)
+		if w := suspend(); w.Code != http.StatusOK {
+			t.Fatalf("suspend as an admin: %d %s", w.Code, w.Body.String())
+		}
+
+		var audit struct {
+			Results []model.AuditEntry `json:"results"`
+		}
+		json.Unmarshal(c.do("GET", "/admin/audit", nil).Body.Bytes(), &audit)
+		actions := []string{}
+		for _, entry := range audit.Results {
+			if entry.ActorId != user.UserId || entry.Detail != "spam" {
+				t.Fatalf("unexpected audit entry %+v", entry)
+			}
+			actions = append(actions, entry.Action+" "+entry.TargetId)
+		}
+		if strings.Join(actions, ", ") != "suspend-user target, hide-painting target-1" {
+			t.Fatalf("unexpected audit trail %v", actions)
+		}
+		if painting, _ := f.store.GetPainting("target", "1"); !painting.Hidden {
+			t.Fatal("the painting was not hidden")
+		}
+		if target, _ := f.store.GetUser("target"); !target.Suspended {
+			t.Fatal("the user was not suspended")
+		}
+	})
+
+	t.Run("resolving a report takes the action after the item is resolved", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		var user model.User
+		json.Unmarshal(c.do("GET", "/getUser", nil).Body.Bytes(), &user)
+		f.store.SetUserRole(user.UserId, model.RoleAdmin)
+		f.store.RecordReport(model.ModerationItem{ItemId: "painting", TargetKind: model.ReportPainting,
+			TargetUserId: "author", PaintingTimestamp: "1", PaintingId: "author-1"}, 1)
+		f.store.RecordReport(model.ModerationItem{ItemId: "self", TargetKind: model.ReportUser,
+			TargetUserId: user.UserId}, 1)
+		resolve := func(itemId string, action string) *httptest.ResponseRecorder {
+			return c.do("POST", "/moderation/"+itemId+"/resolve", map[string]string{"action": action})
+		}
+		for _, itemId := range []string{"painting", "self"} {
+			if w := c.do("POST", "/moderation/"+itemId+"/claim", nil); w.Code != http.StatusOK {
+				t.Fatalf("claim %s: %d %s", itemId, w.Code, w.Body.String())
+			}
+		}
+
+		// the painting is not there yet, so hiding it fails and the item is open again.
+		if w := resolve("painting", "hide"); w.Code != http.StatusInternalServerError {
+			t.Fatalf("hide a missing painting: %d %s", w.Code, w.Body.String())
+		}
+		if item, _ := f.store.GetModerationItem("painting"); item.State != model.ModerationOpen || item.ClaimedBy != user.UserId {
+			t.Fatalf("the item was not reopened: %+v", item)
+		}
+		f.store.AddPainting(&model.Painting{UserId: "author", Timestamp: "1", Title: "Harbor"})
+		if w := resolve("painting", "hide"); w.Code != http.StatusOK {
+			t.Fatalf("hide: %d %s", w.Code, w.Body.String())
+		}
+		if painting, _ := f.store.GetPainting("author", "1"); !painting.Hidden {
+			t.Fatal("the painting was not hidden")
+		}
+		if w := resolve("painting", "hide"); w.Code != http.StatusConflict {
+			t.Fatalf("resolve twice: %d %s", w.Code, w.Body.String())
+		}
+
+		if w := resolve("self", "suspend"); w.Code != http.StatusBadRequest {
+			t.Fatalf("suspend yourself: %d %s", w.Code, w.Body.String())
+		}
+		if self, _ := f.store.GetUser(user.UserId); self.Suspended {
+			t.Fatal("the actor suspended themselves")
+		}
+	})
+
+	t.Run("replaced and deleted avatars leave the image bucket", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		upload := func() string {
+			w := c.do("PUT", "/profile/avatar", map[string]string{"image": pngDataURL(t, 64)})
+			var user model.User
+			if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("upload: %d %s", w.Code, w.Body.String())
+			}
+			return strings.TrimPrefix(user.Avatars["medium"], "http://img.test")
+		}
+		first := upload()
+		// versions are millisecond timestamps.
+		time.Sleep(2 * time.Millisecond)
+		second := upload()
+		if _, err := f.blobs.Get(first); err == nil {
+			t.Fatalf("%s is still stored", first)
+		}
+		if _, err := f.blobs.Get(second); err != nil {
+			t.Fatalf("%s: %v", second, err)
+		}
+		if w := c.do("DELETE", "/profile/avatar", nil); w.Code != http.StatusOK {
+			t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+		}
+		if _, err := f.blobs.Get(second); err == nil {
+			t.Fatalf("%s is still stored", second)
+		}
+	})
+
+	t.Run("blocked and muted authors are left out of listings and notifications", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		var user model.User
+		json.Unmarshal(c.do("GET", "/getUser", nil).Body.Bytes(), &user)
+		for _, author := range []string{"blocked", "muted", "other"} {
+			painting := model.Painting{UserId: author, Timestamp: "1", Title: author, Visibility: model.VisibilityPublic}
+			f.store.PutUser(model.User{UserId: author})
+			f.store.AddPainting(&painting)
+			f.search.PutEsPainting(&painting)
+			f.store.PutFollow(model.Follow{UserId: user.UserId, FolloweeId: author})
+			f.store.NotifyFollowers(&painting)
+		}
+		if w := c.do("PUT", "/users/blocked/block", nil); w.Code != http.StatusOK {
+			t.Fatalf("block: %d %s", w.Code, w.Body.String())
+		}
+		if w := c.do("PUT", "/users/muted/mute", nil); w.Code != http.StatusOK {
+			t.Fatalf("mute: %d %s", w.Code, w.Body.String())
+		}
+
+		var listed []model.Painting
+		json.Unmarshal(c.do("GET", "/wcs", nil).Body.Bytes(), &listed)
+		if len(listed) != 1 || listed[0].UserId != "other" {
+			t.Fatalf("unexpected listing %+v", listed)
+		}
+		json.Unmarshal(f.client(t).do("GET", "/wcs", nil).Body.Bytes(), &listed)
+		if len(listed) != 3 {
+			t.Fatalf("anonymous listing lost paintings: %+v", listed)
+		}
+		var notifications struct {
+			Results []model.Notification `json:"results"`
+		}
+		json.Unmarshal(c.do("GET", "/notifications", nil).Body.Bytes(), &notifications)
+		if len(notifications.Results) != 1 || notifications.Results[0].ActorId != "other" {
+			t.Fatalf("unexpected notifications %+v", notifications.Results)
+		}
+	})
+
+	t.Run("submit, get and list", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		assertGolden(t, "submit_without_session", c.do("POST", "/wcs", map[string]string{"title": "Morning"}), nil)
+		c.signin()
+		c.fetchCsrf()
+		assertGolden(t, "submit_anonymous", c.do("POST", "/wcs", map[string]string{"title": "Morning"}), nil)
+		c.login()
+
+		csrf := c.csrf
+		c.csrf = ""
+		assertGolden(t, "submit_without_csrf", c.do("POST", "/wcs", map[string]string{"title": "Morning"}), nil)
+		c.csrf = csrf
+		assertGolden(t, "submit_bad_visibility", c.do("POST", "/wcs", map[string]string{"title": "Morning", "visibility": "everyone"}), nil)
+
+		w := c.do("POST", "/wcs", map[string]string{"title": "Morning", "description": "harbor at dawn"})
+		var painting model.Painting
+		json.Unmarshal(w.Body.Bytes(), &painting)
+		replace := map[string]string{painting.UserId: "<userId>", painting.Timestamp: "<timestamp>", painting.Date: "<date>", painting.PaintingId: "<paintingId>"}
+		assertGolden(t, "submit", w, replace)
+
+		path := "/wcs/" + painting.UserId + "/" + painting.Timestamp
+		assertGolden(t, "get_own", c.do("GET", path, nil), replace)
+		// views are written in batches, where the same visitor counts once.
+		visitor := f.client(t)
+		visitor.do("GET", path, nil)
+		visitor.do("GET", path, nil)
+		f.handler.FlushViews()
+		assertGolden(t, "get_counts_views", visitor.do("GET", path, nil), replace)
+		assertGolden(t, "get_missing", visitor.do("GET", "/wcs/"+painting.UserId+"/20000101000000000", nil), replace)
+
+		assertGolden(t, "list", visitor.do("GET", "/wcs", nil), replace)
+		assertGolden(t, "list_bad_mode", visitor.do("GET", "/wcs?trending=month", nil), replace)
+		assertGolden(t, "gallery", visitor.do("GET", "/wcs/"+painting.UserId, nil), replace)
+	})
+
+	t.Run("submit notifies followers of what they may see", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		var draft model.Painting
+		json.Unmarshal(c.do("POST", "/wcs", map[string]interface{}{"title": "Sketch", "draft": true}).Body.Bytes(), &draft)
+		f.store.PutFollow(model.Follow{UserId: "fan", FolloweeId: draft.UserId})
+		c.do("POST", "/wcs", map[string]interface{}{"title": "Sketch", "draft": true})
+		c.do("POST", "/wcs", map[string]string{"title": "Diary", "visibility": "private"})
+		var public, followers model.Painting
+		json.Unmarshal(c.do("POST", "/wcs", map[string]string{"title": "Morning"}).Body.Bytes(), &public)
+		json.Unmarshal(c.do("POST", "/wcs", map[string]string{"title": "Evening", "visibility": "followers"}).Body.Bytes(), &followers)
+		notifications, err := f.store.ListNotifications("fan", "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		notified := map[string]bool{}
+		for _, notification := range notifications {
+			notified[notification.PaintingTimestamp] = true
+		}
+		if len(notifications) != 2 || !notified[public.Timestamp] || !notified[followers.Timestamp] {
+			t.Fatalf("unexpected notifications %+v", notifications)
+		}
+	})
+
+	t.Run("paintings by id", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		var first, second model.Painting
+		json.Unmarshal(c.do("POST", "/wcs", map[string]string{"title": "Morning"}).Body.Bytes(), &first)
+		json.Unmarshal(c.do("POST", "/wcs", map[string]string{"title": "Evening"}).Body.Bytes(), &second)
+		if first.PaintingId == "" || first.PaintingId == second.PaintingId || first.Timestamp == second.Timestamp {
+			t.Fatalf("submits collided: %+v %+v", first, second)
+		}
+		replace := map[string]string{first.UserId: "<userId>", first.Timestamp: "<timestamp>", first.Date: "<date>", first.PaintingId: "<paintingId>"}
+		assertGolden(t, "painting_by_id", c.do("GET", "/paintings/"+first.PaintingId, nil), replace)
+		assertGolden(t, "painting_by_composite_id", c.do("GET", "/paintings/"+first.GetId(), nil), replace)
+		assertGolden(t, "painting_by_missing_id", c.do("GET", "/paintings/01ARYZ6S41TSV4RRFFQ69G5FAV", nil), replace)
+		c.header.Set("If-Match", `"1"`)
+		assertGolden(t, "painting_update_by_id", c.do("PATCH", "/paintings/"+first.PaintingId, map[string]string{"title": "Dawn"}), replace)
+	})
+
+	t.Run("submit ignores counters, scores and moderation", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		var painting model.Painting
+		w := c.do("POST", "/wcs", map[string]interface{}{
+			"title": "Morning", "has_image_cover": true,
+			"likes": 1000, "favorits": 1000, "comments": 1000, "views": 1000,
+			"trending_today": 99.5, "trending_week": 99.5, "trending_all": 99.5,
+			"hidden": true, "hidden_reason": "none", "version": 7,
+		})
+		json.Unmarshal(w.Body.Bytes(), &painting)
+		stored, err := f.store.GetPainting(painting.UserId, painting.Timestamp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := model.Painting{
+			PaintingId: stored.PaintingId, UserId: stored.UserId, Timestamp: stored.Timestamp, Date: stored.Date,
+			Created: stored.Created, Updated: stored.Updated, Title: "Morning", HasImageCover: true,
+			Visibility: model.VisibilityPublic, Version: 1,
+		}
+		if stored != want {
+			t.Fatalf("submit kept client fields: %+v", stored)
+		}
+	})
+
+	t.Run("updates need the current version", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		var painting model.Painting
+		json.Unmarshal(c.do("POST", "/wcs", map[string]string{"title": "Morning"}).Body.Bytes(), &painting)
+		replace := map[string]string{painting.UserId: "<userId>", painting.Timestamp: "<timestamp>", painting.Date: "<date>", painting.PaintingId: "<paintingId>"}
+		path := "/wcs/" + painting.UserId + "/" + painting.Timestamp
+
+		etag := c.do("GET", path, nil).Header().Get("ETag")
+		if etag != `"1"` {
+			t.Fatalf("unexpected ETag %q", etag)
+		}
+		assertGolden(t, "update_without_if_match", c.do("PATCH", path, map[string]string{"title": "Dawn"}), replace)
+
+		// two tabs edit the same version, and the second one loses.
+		c.header.Set("If-Match", etag)
+		w := c.do("PATCH", path, map[string]string{"title": "Dawn"})
+		if w.Code != 200 || w.Header().Get("ETag") != `"2"` {
+			t.Fatalf("update: %d %q %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+		}
+		stale := c.do("PATCH", path, map[string]string{"description": "harbor"})
+		if stale.Header().Get("ETag") != `"2"` {
+			t.Fatalf("412 without the current ETag: %q", stale.Header().Get("ETag"))
+		}
+		assertGolden(t, "update_stale", stale, replace)
+		assertGolden(t, "publish_stale", c.do("POST", path+"/publish", nil), replace)
+
+		// views and likes from others do not change the version.
+		visitor := f.client(t)
+		visitor.do("GET", path, nil)
+		c.header.Set("If-Match", "W/\"2\", \"2\"")
+		w = c.do("PATCH", path, map[string]string{"description": "harbor"})
+		if w.Code != 200 || w.Header().Get("ETag") != `"3"` {
+			t.Fatalf("update after a view: %d %q %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+		}
+
+		c.header.Set("If-Match", "*")
+		if w := c.do("DELETE", path+"/publish", nil); w.Code != http.StatusConflict {
+			t.Fatalf("cancelled an unscheduled painting: %d %s", w.Code, w.Body.String())
+		}
 	})
 
+	t.Run("daily gallery and archive", func(t *testing.T) {
+		f := newFixture(t)
+		for i := 0; i < 11; i++ {
+			f.store.AddPainting(&model.Painting{UserId: "painter", Timestamp: fmt.Sprintf("20210810%09d", i), Date: "20210810", Title: fmt.Sprint("Harbor ", i)})
+		}
+		f.store.AddPainting(&model.Painting{UserId: "painter", Timestamp: "20210810999999999", Date: "20210810", Draft: true})
+		f.store.AddPainting(&model.Painting{UserId: "painter", Timestamp: "20210831000000000", Date: "20210831", Visibility: model.VisibilityPublic})
+		c := f.client(t)
+		assertGolden(t, "daily", c.do("GET", "/wcs/date/20210810", nil), nil)
+		assertGolden(t, "daily_next", c.do("GET", "/wcs/date/20210810?before=20210810000000001", nil), nil)
+		assertGolden(t, "daily_bad_date", c.do("GET", "/wcs/date/2021081", nil), nil)
+		archive := c.do("GET", "/archive/202108", nil)
+		assertGolden(t, "archive", archive, nil)
+		if archive.Header().Get("Cache-Control") == "" {
+			t.Fatal("a past month is not cached")
+		}
+		if w := c.do("GET", "/archive/"+time.Now().UTC().Format("200601"), nil); w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "" {
+			t.Fatalf("the current month: %d %q", w.Code, w.Header().Get("Cache-Control"))
+		}
+		assertGolden(t, "archive_bad_month", c.do("GET", "/archive/2021-8", nil), nil)
+		assertGolden(t, "archive_future", c.do("GET", "/archive/299912", nil), nil)
+	})
+
+	t.Run("challenge entries the viewer may see", func(t *testing.T) {
+		f := newFixture(t)
+		f.store.AddPainting(&model.Painting{UserId: "painter", Timestamp: "1", Title: "Shown", Visibility: model.VisibilityPublic})
+		f.store.AddPainting(&model.Painting{UserId: "painter", Timestamp: "2", Title: "Hidden", Visibility: model.VisibilityPublic, Hidden: true})
+		f.store.AddPainting(&model.Painting{UserId: "painter", Timestamp: "3", Title: "Private", Visibility: model.VisibilityPrivate})
+		f.store.PutChallenge(&model.Challenge{ChallengeId: "c", Title: "Open", StartAt: 1, EndAt: 2, VoteEndAt: 3})
+		// the one entry left has the second most votes and keeps its rank.
+		votes := []uint32{3, 4, 2, 1}
+		for i, timestamp := range []string{"1", "2", "3", "4"} {
+			f.store.PutChallengeEntry(&model.ChallengeEntry{ChallengeId: "c", EntryId: timestamp, PaintingUserId: "painter", PaintingTimestamp: timestamp, Votes: votes[i], Created: uint64(i)})
+		}
+		entries, _ := f.store.ListChallengeEntries("c")
+		results := model.RankChallengeEntries(entries)
+		f.store.PutChallenge(&model.Challenge{ChallengeId: "done", Title: "Closed", StartAt: 1, EndAt: 2, VoteEndAt: 3, Closed: true, Results: results})
+		c := f.client(t)
+		assertGolden(t, "challenge_entries", c.do("GET", "/challenges/c/entries", nil), nil)
+		assertGolden(t, "challenge_leaderboard", c.do("GET", "/challenges/c/leaderboard", nil), nil)
+		assertGolden(t, "challenge_leaderboard_closed", c.do("GET", "/challenges/done/leaderboard", nil), nil)
+		assertGolden(t, "challenge_winners", c.do("GET", "/winners", nil), nil)
+	})
+
+	t.Run("image patch", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		var painting model.Painting
+		json.Unmarshal(c.do("POST", "/wcs", map[string]string{"title": "Morning"}).Body.Bytes(), &painting)
+		replace := map[string]string{painting.UserId: "<userId>", painting.Timestamp: "<timestamp>"}
+		path := "/wcs/" + painting.UserId + "/" + painting.Timestamp + "/images"
+		image := pngDataURL(t, 2)
+
+		w := c.do("PATCH", path, map[string]string{"user_id": painting.UserId, "timestamp": painting.Timestamp, "image_cover": image})
+		assertGolden(t, "image_patch", w, replace)
+		if _, err := f.blobs.Get("/wcs/" + painting.UserId + "/" + painting.Timestamp + "/cover.png"); err != nil {
+			t.Fatal(err)
+		}
+
+		// parseImageBody checks the URL, the body and the session all name the same user and painting.
+		assertGolden(t, "image_patch_other_user", c.do("PATCH", "/wcs/someone/"+painting.Timestamp+"/images",
+			map[string]string{"user_id": "someone", "timestamp": painting.Timestamp, "image1": image}), replace)
+		assertGolden(t, "image_patch_body_mismatch", c.do("PATCH", path,
+			map[string]string{"user_id": "someone", "timestamp": painting.Timestamp, "image1": image}), replace)
+		assertGolden(t, "image_patch_timestamp_mismatch", c.do("PATCH", path,
+			map[string]string{"user_id": painting.UserId, "timestamp": "20000101000000000", "image1": image}), replace)
+		if _, err := f.blobs.Get("/wcs/" + painting.UserId + "/" + painting.Timestamp + "/1.png"); err == nil {
+			t.Fatal("a rejected patch was stored")
+		}
+
+		anonymous := f.client(t)
+		anonymous.signin()
+		anonymous.fetchCsrf()
+		assertGolden(t, "image_patch_anonymous", anonymous.do("PATCH", path,
+			map[string]string{"user_id": painting.UserId, "timestamp": painting.Timestamp, "image1": image}), replace)
+	})
+
+	t.Run("idempotent submit and image patch", func(t *testing.T) {
+		f := newFixture(t)
+		c := f.client(t)
+		c.login()
+		c.header.Set("Idempotency-Key", "submit-1")
+		first := c.do("POST", "/wcs", map[string]string{"title": "Morning"})
+		var painting model.Painting
+		json.Unmarshal(first.Body.Bytes(), &painting)
+		replace := map[string]string{painting.UserId: "<userId>", painting.Timestamp: "<timestamp>", painting.Date: "<date>", painting.PaintingId: "<paintingId>"}
+		retry := c.do("POST", "/wcs", map[string]string{"title": "Morning"})
+		if retry.Body.String() != first.Body.String() || retry.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatalf("retry was not replayed: %s", retry.Body.String())
+		}
+		assertGolden(t, "submit_idempotent_other_body", c.do("POST", "/wcs", map[string]string{"title": "Evening"}), replace)
+		if paintings, _ := f.store.ListUserPaintings(painting.UserId, "", 10); len(paintings) != 1 {
+			t.Fatalf("expected 1 painting, got %d", len(paintings))
+		}
+
+		// keys belong to the user rather than the session, and another key is another request.
+		device := f.client(t)
+		device.login()
+		device.header.Set("Idempotency-Key", "submit-1")
+		if w := device.do("POST", "/wcs", map[string]string{"title": "Morning"}); w.Body.String() != first.Body.String() {
+			t.Fatalf("retry from another session was not replayed: %s", w.Body.String())
+		}
+		c.header.Set("Idempotency-Key", "submit-2")
+		c.do("POST", "/wcs", map[string]string{"title": "Morning"})
+		if paintings, _ := f.store.ListUserPaintings(painting.UserId, "", 10); len(paintings) != 2 {
+			t.Fatalf("expected 2 paintings, got %d", len(paintings))
+		}
+
+		// a photo of a painting is far bigger than a DynamoDB item, so the response must not carry it.
+		c.header.Set("Idempotency-Key", "patch-1")
+		path := "/wcs/" + painting.UserId + "/" + painting.Timestamp + "/images"
+		patch := map[string]string{"user_id": painting.UserId, "timestamp": painting.Timestamp, "image_cover": pngDataURL(t, 400)}
+		first = c.do("PATCH", path, patch)
+		if first.Code != 200 || first.Body.Len() > 1024 {
+			t.Fatalf("unexpected response to the patch: %d, %d bytes", first.Code, first.Body.Len())
+		}
+		f.blobs.DeletePrefix("/wcs/")
+		retry = c.do("PATCH", path, patch)
+		if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+			t.Fatalf("retry was not replayed: %d %s", retry.Code, retry.Body.String())
+		}
+		if _, err := f.blobs.Get("/wcs/" + painting.UserId + "/" + painting.Timestamp + "/cover.png"); err == nil {
+			t.Fatal("the replayed patch uploaded again")
+		}
+		assertGolden(t, "image_patch_idempotent_other_path", c.do("PATCH", "/paintings/"+painting.PaintingId+"/images", patch), replace)
+	})
+
+	t.Run("pigment search", func(t *testing.T) {
+		c := newFixture(t).client(t)
+		assertGolden(t, "pigments", c.do("GET", "/equipments?cat=1&q=Ultra", nil), nil)
+		assertGolden(t, "pigments_without_query", c.do("GET", "/equipments?cat=1", nil), nil)
+		assertGolden(t, "pigments_bad_category", c.do("GET", "/equipments?cat=paper&q=Ultra", nil), nil)
+	})
 }
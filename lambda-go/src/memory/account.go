@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+// GetIdentity returns the identity linked to the external account, or ok=false when none is.
+func (s *Store) GetIdentity(provider string, subject string) (identity model.Identity, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identity, ok = s.identities[model.IdentityKey(provider, subject)]
+	return identity, ok, nil
+}
+
+// LinkIdentity stores a new link. It fails with domain.ErrConflict when the external
+// account is already linked.
+func (s *Store) LinkIdentity(identity model.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identity.IdentityKey = model.IdentityKey(identity.Provider, identity.Subject)
+	if _, ok := s.identities[identity.IdentityKey]; ok {
+		return domain.ErrConflict
+	}
+	s.identities[identity.IdentityKey] = identity
+	return nil
+}
+
+func (s *Store) PutIdentity(identity model.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identity.IdentityKey = model.IdentityKey(identity.Provider, identity.Subject)
+	s.identities[identity.IdentityKey] = identity
+	return nil
+}
+
+func (s *Store) ListIdentities(userId string) ([]model.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.Identity
+	for _, key := range rangeKeys(s.identities, false) {
+		if identity := s.identities[key]; identity.UserId == userId {
+			result = append(result, identity)
+		}
+	}
+	return result, nil
+}
+
+func (s *Store) DeleteIdentity(provider string, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.identities, model.IdentityKey(provider, subject))
+	return nil
+}
+
+func (s *Store) PutApiToken(token model.ApiToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var stored model.ApiToken
+	copyItem(token, &stored)
+	s.apiTokens[token.TokenHash] = stored
+	return nil
+}
+
+func (s *Store) GetApiToken(tokenHash string) (model.ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result model.ApiToken
+	stored, ok := s.apiTokens[tokenHash]
+	if !ok {
+		return result, domain.ErrNotFound
+	}
+	copyItem(stored, &result)
+	return result, nil
+}
+
+func (s *Store) ListApiTokens(userId string) ([]model.ApiToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.ApiToken
+	for _, tokenHash := range rangeKeys(s.apiTokens, false) {
+		if stored := s.apiTokens[tokenHash]; stored.UserId == userId {
+			var token model.ApiToken
+			copyItem(stored, &token)
+			result = append(result, token)
+		}
+	}
+	return result, nil
+}
+
+func (s *Store) TouchApiToken(tokenHash string, now uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.apiTokens[tokenHash]
+	if !ok {
+		return domain.ErrConflict
+	}
+	stored.LastUsed = now
+	s.apiTokens[tokenHash] = stored
+	return nil
+}
+
+func (s *Store) DeleteApiToken(tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.apiTokens, tokenHash)
+	return nil
+}
+
+func (s *Store) PutExport(export model.Export) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exports[export.UserId] == nil {
+		s.exports[export.UserId] = map[string]model.Export{}
+	}
+	s.exports[export.UserId][export.ExportId] = export
+	return nil
+}
+
+func (s *Store) ListExports(userId string) ([]model.Export, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.Export{}
+	for _, exportId := range rangeKeys(s.exports[userId], true) {
+		result = append(result, s.exports[userId][exportId])
+	}
+	return result, nil
+}
+
+// GetPendingExports returns the exports still to be built, the oldest first.
+func (s *Store) GetPendingExports() ([]model.Export, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.Export
+	for _, userId := range rangeKeys(s.exports, false) {
+		for _, exportId := range rangeKeys(s.exports[userId], false) {
+			if export := s.exports[userId][exportId]; export.State == model.ExportPending {
+				result = append(result, export)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Created < result[j].Created
+	})
+	return result, nil
+}
+
+func (s *Store) DeleteExport(userId string, exportId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.exports[userId], exportId)
+	return nil
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/hirosato/wcs/auth"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+// Provider signs in without an external account, so the API can be used offline. The callback
+// signs in as the account named by its user query parameter, "dev" when there is none.
+type Provider struct{}
+
+var _ auth.Provider = Provider{}
+
+func (Provider) Name() string {
+	return "memory"
+}
+
+func (p Provider) Begin(s *model.Session, callbackURL string) (string, error) {
+	s.OAuthProvider = p.Name()
+	s.OAuthState = util.NewId()
+	return callbackURL + "?state=" + url.QueryEscape(s.OAuthState), nil
+}
+
+func (p Provider) Complete(s *model.Session, r *http.Request, callbackURL string) (model.Identity, error) {
+	query := r.URL.Query()
+	if s.OAuthProvider != p.Name() || s.OAuthState == "" || query.Get("state") != s.OAuthState {
+		return model.Identity{}, errors.New("sign-in state mismatch")
+	}
+	subject := query.Get("user")
+	if subject == "" {
+		subject = "dev"
+	}
+	return model.Identity{
+		Provider:    p.Name(),
+		Subject:     subject,
+		DisplayName: subject,
+	}, nil
+}
@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) PutChallenge(challenge *model.Challenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.challenges[challenge.ChallengeId]; ok {
+		return domain.ErrConflict
+	}
+	var stored model.Challenge
+	copyItem(challenge, &stored)
+	s.challenges[challenge.ChallengeId] = stored
+	return nil
+}
+
+func (s *Store) GetChallenge(challengeId string) (model.Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result model.Challenge
+	stored, ok := s.challenges[challengeId]
+	if !ok {
+		return result, domain.ErrNotFound
+	}
+	copyItem(stored, &result)
+	return result, nil
+}
+
+func (s *Store) ListChallenges() ([]model.Challenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.Challenge{}
+	for _, challengeId := range rangeKeys(s.challenges, false) {
+		var challenge model.Challenge
+		copyItem(s.challenges[challengeId], &challenge)
+		result = append(result, challenge)
+	}
+	return result, nil
+}
+
+// CloseChallenge freezes the results. Only the first call for a challenge has any effect.
+func (s *Store) CloseChallenge(challengeId string, results []model.ChallengeResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.challenges[challengeId]
+	if !ok || stored.Closed {
+		return nil
+	}
+	var challenge model.Challenge
+	copyItem(stored, &challenge)
+	challenge.Closed = true
+	challenge.Results = results
+	copyItem(challenge, &stored)
+	s.challenges[challengeId] = stored
+	return nil
+}
+
+func (s *Store) PutChallengeEntry(entry *model.ChallengeEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.challengeEntries[entry.ChallengeId][entry.EntryId]; ok {
+		return domain.ErrConflict
+	}
+	if s.challengeEntries[entry.ChallengeId] == nil {
+		s.challengeEntries[entry.ChallengeId] = map[string]model.ChallengeEntry{}
+	}
+	s.challengeEntries[entry.ChallengeId][entry.EntryId] = *entry
+	return nil
+}
+
+func (s *Store) GetChallengeEntry(challengeId string, entryId string) (model.ChallengeEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.challengeEntries[challengeId][entryId]
+	if !ok {
+		return entry, domain.ErrNotFound
+	}
+	return entry, nil
+}
+
+func (s *Store) DeleteChallengeEntry(challengeId string, entryId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.challengeEntries[challengeId], entryId)
+	return nil
+}
+
+func (s *Store) ListChallengeEntries(challengeId string) ([]model.ChallengeEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.ChallengeEntry{}
+	for _, entryId := range rangeKeys(s.challengeEntries[challengeId], false) {
+		result = append(result, s.challengeEntries[challengeId][entryId])
+	}
+	return result, nil
+}
+
+// PutChallengeVote records a vote and bumps the entry's running count. It returns false when the
+// user has already voted for the entry.
+func (s *Store) PutChallengeVote(challengeId string, entryId string, vote model.ChallengeVote) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.challengeVotes[vote.EntryKey][vote.UserId]; ok {
+		return false, nil
+	}
+	if s.challengeVotes[vote.EntryKey] == nil {
+		s.challengeVotes[vote.EntryKey] = map[string]model.ChallengeVote{}
+	}
+	s.challengeVotes[vote.EntryKey][vote.UserId] = vote
+	if entry, ok := s.challengeEntries[challengeId][entryId]; ok {
+		entry.Votes++
+		s.challengeEntries[challengeId][entryId] = entry
+	}
+	return true, nil
+}
+
+// CountChallengeVotes counts the vote items themselves, which are authoritative over the running count.
+func (s *Store) CountChallengeVotes(challengeId string, entryId string) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return uint32(len(s.challengeVotes[model.ChallengeEntryKey(challengeId, entryId)])), nil
+}
+
+func (s *Store) AddCollection(collection *model.Collection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.collections[collection.UserId][collection.CollectionId]; ok {
+		return domain.ErrConflict
+	}
+	if s.collections[collection.UserId] == nil {
+		s.collections[collection.UserId] = map[string]model.Collection{}
+	}
+	var stored model.Collection
+	copyItem(collection, &stored)
+	s.collections[collection.UserId][collection.CollectionId] = stored
+	return nil
+}
+
+func (s *Store) PutCollection(collection *model.Collection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.collections[collection.UserId] == nil {
+		s.collections[collection.UserId] = map[string]model.Collection{}
+	}
+	var stored model.Collection
+	copyItem(collection, &stored)
+	s.collections[collection.UserId][collection.CollectionId] = stored
+	return nil
+}
+
+func (s *Store) GetCollection(userId string, collectionId string) (model.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result model.Collection
+	stored, ok := s.collections[userId][collectionId]
+	if !ok {
+		return result, domain.ErrNotFound
+	}
+	copyItem(stored, &result)
+	return result, nil
+}
+
+func (s *Store) ListCollections(userId string) ([]model.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.Collection{}
+	for _, collectionId := range rangeKeys(s.collections[userId], true) {
+		var collection model.Collection
+		copyItem(s.collections[userId][collectionId], &collection)
+		result = append(result, collection)
+	}
+	return result, nil
+}
+
+func (s *Store) DeleteCollection(userId string, collectionId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.collections[userId], collectionId)
+	return nil
+}
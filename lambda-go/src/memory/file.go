@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+// BlobStore keeps the images the S3 bucket would, by object key.
+type BlobStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+var _ domain.BlobStore = (*BlobStore)(nil)
+
+func NewBlobStore() *BlobStore {
+	return &BlobStore{objects: map[string][]byte{}}
+}
+
+func (store *BlobStore) Add(localFilePath string, userId string, timestamp string, filename model.ImageKind) error {
+	return store.Put(localFilePath, "/wcs/"+userId+"/"+timestamp+"/"+filename.ToPathString()+".png")
+}
+
+// Put copies a local file to key.
+func (store *BlobStore) Put(localFilePath string, key string) error {
+	body, err := ioutil.ReadFile(localFilePath)
+	if err != nil {
+		return err
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.objects[key] = body
+	return nil
+}
+
+func (store *BlobStore) Get(key string) ([]byte, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	body, ok := store.objects[key]
+	if !ok {
+		return nil, errors.New("NoSuchKey: " + key)
+	}
+	return body, nil
+}
+
+// DeletePrefix removes every object whose key starts with prefix.
+func (store *BlobStore) DeletePrefix(prefix string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for key := range store.objects {
+		if strings.HasPrefix(key, prefix) {
+			delete(store.objects, key)
+		}
+	}
+	return nil
+}
+
+// ExportStore keeps personal data exports. Its links use the memory: scheme, since nothing serves them.
+type ExportStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+var _ domain.ExportStore = (*ExportStore)(nil)
+
+func NewExportStore() *ExportStore {
+	return &ExportStore{objects: map[string][]byte{}}
+}
+
+func (store *ExportStore) Put(key string, body []byte) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.objects[key] = body
+	return nil
+}
+
+func (store *ExportStore) PresignGet(key string) (string, error) {
+	return "memory:" + key, nil
+}
+
+// Cdn remembers the paths it was asked to invalidate; there is no cache to drop them from.
+type Cdn struct {
+	mu          sync.Mutex
+	Invalidated []string
+}
+
+var _ domain.CdnRepository = (*Cdn)(nil)
+
+func (cdn *Cdn) Invalidate(paths []string) error {
+	cdn.mu.Lock()
+	defer cdn.mu.Unlock()
+	cdn.Invalidated = append(cdn.Invalidated, paths...)
+	return nil
+}
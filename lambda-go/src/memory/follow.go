@@ -0,0 +1,167 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) PutFollow(follow model.Follow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.follows[follow.UserId] == nil {
+		s.follows[follow.UserId] = map[string]model.Follow{}
+	}
+	var stored model.Follow
+	copyItem(follow, &stored)
+	s.follows[follow.UserId][follow.FolloweeId] = stored
+	return nil
+}
+
+func (s *Store) DeleteFollow(userId string, followeeId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.follows[userId], followeeId)
+	return nil
+}
+
+func (s *Store) IsFollowing(userId string, followeeId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.follows[userId][followeeId]
+	return ok, nil
+}
+
+func (s *Store) GetFollowers(followeeId string) ([]model.Follow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.Follow
+	for _, userId := range rangeKeys(s.follows, false) {
+		if follow, ok := s.follows[userId][followeeId]; ok {
+			result = append(result, follow)
+		}
+	}
+	return result, nil
+}
+
+func (s *Store) ListFollowing(userId string) ([]model.Follow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.Follow
+	for _, followeeId := range rangeKeys(s.follows[userId], false) {
+		result = append(result, s.follows[userId][followeeId])
+	}
+	return result, nil
+}
+
+// NotifyFollowers tells the painter's followers about a published painting. Notification ids are
+// derived from the painting, so calling it again overwrites the same items instead of duplicating them.
+func (s *Store) NotifyFollowers(painting *model.Painting) error {
+	followers, err := s.GetFollowers(painting.UserId)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, follower := range followers {
+		notification := model.Notification{
+			UserId:            follower.UserId,
+			NotificationId:    fmt.Sprintf("%013d-%s-%s", painting.PublishAt, model.NotificationPublished, painting.GetId()),
+			Kind:              model.NotificationPublished,
+			ActorId:           painting.UserId,
+			PaintingUserId:    painting.UserId,
+			PaintingTimestamp: painting.Timestamp,
+			Created:           painting.PublishAt,
+		}
+		if s.notifications[follower.UserId] == nil {
+			s.notifications[follower.UserId] = map[string]model.Notification{}
+		}
+		s.notifications[follower.UserId][notification.NotificationId] = notification
+	}
+	return nil
+}
+
+// ListNotifications returns up to limit notifications of userId older than before, newest first.
+func (s *Store) ListNotifications(userId string, before string, limit int64) ([]model.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.Notification
+	for _, notificationId := range rangeKeys(s.notifications[userId], true) {
+		if limit > 0 && int64(len(result)) == limit {
+			break
+		}
+		if before != "" && notificationId >= before {
+			continue
+		}
+		result = append(result, s.notifications[userId][notificationId])
+	}
+	return result, nil
+}
+
+func (s *Store) DeleteNotifications(userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.notifications, userId)
+	return nil
+}
+
+func (s *Store) PutRelation(relation model.Relation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	relation.RelationKey = model.RelationKey(relation.Kind, relation.TargetId)
+	if s.relations[relation.UserId] == nil {
+		s.relations[relation.UserId] = map[string]model.Relation{}
+	}
+	s.relations[relation.UserId][relation.RelationKey] = relation
+	return nil
+}
+
+func (s *Store) DeleteRelation(userId string, kind model.RelationKind, targetId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.relations[userId], model.RelationKey(kind, targetId))
+	return nil
+}
+
+func (s *Store) HasRelation(userId string, kind model.RelationKind, targetId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.relations[userId][model.RelationKey(kind, targetId)]
+	return ok, nil
+}
+
+// ListRelations returns the users userId has blocked or muted, or both when kind is empty.
+func (s *Store) ListRelations(userId string, kind model.RelationKind) ([]model.Relation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.Relation{}
+	for _, key := range rangeKeys(s.relations[userId], false) {
+		if kind == "" || strings.HasPrefix(key, string(kind)+"#") {
+			result = append(result, s.relations[userId][key])
+		}
+	}
+	return result, nil
+}
+
+// IsBlockedBetween reports whether either user has blocked the other.
+func (s *Store) IsBlockedBetween(userId string, otherId string) (bool, error) {
+	blocked, err := s.HasRelation(userId, model.RelationBlock, otherId)
+	if err != nil || blocked {
+		return blocked, err
+	}
+	return s.HasRelation(otherId, model.RelationBlock, userId)
+}
+
+// ListExcludedAuthors returns everyone userId muted or blocked.
+func (s *Store) ListExcludedAuthors(userId string) ([]string, error) {
+	relations, err := s.ListRelations(userId, "")
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, relation := range relations {
+		result = append(result, relation.TargetId)
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) ReserveIdempotencyKey(record model.IdempotencyRecord, now int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// taken until it expires, unless its request was abandoned.
+	if stored, ok := s.idempotency[record.IdempotencyKey]; ok && stored.ExpiresAt > now && (stored.Status != 0 || stored.IsPending(now)) {
+		return false, nil
+	}
+	var stored model.IdempotencyRecord
+	copyItem(record, &stored)
+	s.idempotency[record.IdempotencyKey] = stored
+	return true, nil
+}
+
+func (s *Store) GetIdempotencyRecord(key string) (model.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var record model.IdempotencyRecord
+	stored, ok := s.idempotency[key]
+	if !ok {
+		return record, domain.ErrNotFound
+	}
+	copyItem(stored, &record)
+	return record, nil
+}
+
+func (s *Store) CompleteIdempotencyRecord(record model.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.idempotency[record.IdempotencyKey]
+	if !ok || stored.Fingerprint != record.Fingerprint || stored.Created != record.Created {
+		return domain.ErrConflict
+	}
+	if len(stored.IdempotencyKey)+len(stored.Fingerprint)+len(record.Body) > maxItemSize {
+		return errItemTooLarge
+	}
+	stored.Status = record.Status
+	stored.Body = append([]byte(nil), record.Body...)
+	s.idempotency[record.IdempotencyKey] = stored
+	return nil
+}
+
+func (s *Store) ReleaseIdempotencyKey(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.idempotency, key)
+	return nil
+}
@@ -0,0 +1,346 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+	"github.com/hirosato/wcs/util"
+)
+
+// painting returns the stored painting, or ok=false when there is none.
+func (s *Store) painting(userId string, timestamp string) (painting model.Painting, ok bool) {
+	stored, ok := s.paintings[userId][timestamp]
+	if ok {
+		copyItem(stored, &painting)
+	}
+	return painting, ok
+}
+
+func (s *Store) putPainting(painting model.Painting) {
+	if s.paintings[painting.UserId] == nil {
+		s.paintings[painting.UserId] = map[string]model.Painting{}
+	}
+	var stored model.Painting
+	copyItem(painting, &stored)
+	s.paintings[painting.UserId][painting.Timestamp] = stored
+}
+
+// allPaintings returns every painting by user and then timestamp.
+func (s *Store) allPaintings() []model.Painting {
+	result := []model.Painting{}
+	for _, userId := range rangeKeys(s.paintings, false) {
+		for _, timestamp := range rangeKeys(s.paintings[userId], false) {
+			painting, _ := s.painting(userId, timestamp)
+			result = append(result, painting)
+		}
+	}
+	return result
+}
+
+func (s *Store) GetPainting(userId string, timestamp string) (model.Painting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	painting, ok := s.painting(userId, timestamp)
+	if !ok {
+		return painting, domain.ErrNotFound
+	}
+	return painting, nil
+}
+
+// GetPaintings loads the referenced paintings keyed by painting id. Paintings that have been deleted
+// are simply missing from the result.
+func (s *Store) GetPaintings(refs []model.PaintingRef) (map[string]model.Painting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := map[string]model.Painting{}
+	for _, ref := range refs {
+		if painting, ok := s.painting(ref.UserId, ref.Timestamp); ok {
+			result[painting.GetId()] = painting
+		}
+	}
+	return result, nil
+}
+
+func (s *Store) GetPaintingById(paintingId string) (model.Painting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, painting := range s.allPaintings() {
+		if paintingId != "" && painting.PaintingId == paintingId {
+			return painting, nil
+		}
+	}
+	return model.Painting{}, domain.ErrNotFound
+}
+
+func (s *Store) AddPainting(painting *model.Painting) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.painting(painting.UserId, painting.Timestamp); ok {
+		return domain.ErrConflict
+	}
+	s.putPainting(*painting)
+	return nil
+}
+
+func (s *Store) SetPaintingId(userId string, timestamp string, paintingId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	painting, ok := s.painting(userId, timestamp)
+	if !ok || painting.PaintingId != "" {
+		return domain.ErrConflict
+	}
+	painting.PaintingId = paintingId
+	s.putPainting(painting)
+	return nil
+}
+
+// UpdatePainting writes the fields the owner edits and publishes when the stored painting is still
+// at painting.Version, bumps the version and updates painting in place.
+func (s *Store) UpdatePainting(painting *model.Painting) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.painting(painting.UserId, painting.Timestamp)
+	if !ok || stored.Version != painting.Version {
+		return domain.ErrConflict
+	}
+	stored.Title = painting.Title
+	stored.Description = painting.Description
+	stored.Visibility = painting.Visibility
+	stored.Draft = painting.Draft
+	stored.PublishAt = painting.PublishAt
+	stored.Schedule = painting.Schedule
+	stored.Updated = painting.Updated
+	stored.Version++
+	s.putPainting(stored)
+	*painting = stored
+	return nil
+}
+
+func (s *Store) DeletePainting(painting *model.Painting) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.paintings[painting.UserId], painting.Timestamp)
+	return nil
+}
+
+// ListUserPaintings returns up to limit paintings of userId older than before, newest first.
+func (s *Store) ListUserPaintings(userId string, before string, limit int64) ([]model.Painting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.Painting
+	for _, timestamp := range rangeKeys(s.paintings[userId], true) {
+		if limit > 0 && int64(len(result)) == limit {
+			break
+		}
+		if before != "" && timestamp >= before {
+			continue
+		}
+		painting, _ := s.painting(userId, timestamp)
+		result = append(result, painting)
+	}
+	return result, nil
+}
+
+// ListDatePaintings pages through the paintings submitted on date, newest first, like the by-date index.
+func (s *Store) ListDatePaintings(date string, before string, limit int64) ([]model.Painting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.Painting
+	for _, painting := range s.allPaintings() {
+		if painting.Date == date && (before == "" || painting.Timestamp < before) {
+			result = append(result, painting)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp > result[j].Timestamp
+	})
+	if limit > 0 && int64(len(result)) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *Store) CountPublicDatePaintings(date string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var count int64
+	for _, painting := range s.allPaintings() {
+		if painting.Date == date && painting.IsPublic() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) ScanPaintings() ([]model.Painting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.allPaintings(), nil
+}
+
+func (s *Store) SetPaintingHidden(userId string, timestamp string, hidden bool, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	painting, ok := s.painting(userId, timestamp)
+	if !ok {
+		return domain.ErrConflict
+	}
+	painting.Hidden = hidden
+	painting.HiddenReason = reason
+	if !hidden {
+		painting.HiddenReason = ""
+	}
+	s.putPainting(painting)
+	return nil
+}
+
+// IncrementPaintingCounter adds delta to one of the painting's counters and puts the painting on
+// the ranking index. Counters never go below zero.
+func (s *Store) IncrementPaintingCounter(userId string, timestamp string, counter string, delta int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	painting, ok := s.painting(userId, timestamp)
+	if !ok {
+		return nil
+	}
+	var value *uint32
+	switch counter {
+	case "Likes":
+		value = &painting.Likes
+	case "Favorits":
+		value = &painting.Favorits
+	case "Comments":
+		value = &painting.Comments
+	case "Views":
+		value = &painting.Views
+	default:
+		return nil
+	}
+	if delta < 0 && int(*value) < -delta {
+		return nil
+	}
+	*value = uint32(int(*value) + delta)
+	painting.Ranking = model.RankingActive
+	painting.LastActive = util.GetUnixMilli()
+	s.putPainting(painting)
+	return nil
+}
+
+func (s *Store) MarkPaintingRanked(userId string, timestamp string, now uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	painting, ok := s.painting(userId, timestamp)
+	if !ok {
+		return nil
+	}
+	painting.Ranking = model.RankingActive
+	painting.LastActive = now
+	s.putPainting(painting)
+	return nil
+}
+
+func (s *Store) ListRankedPaintings() ([]model.Painting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.Painting{}
+	for _, painting := range s.allPaintings() {
+		if painting.Ranking == model.RankingActive {
+			result = append(result, painting)
+		}
+	}
+	return result, nil
+}
+
+// PutPaintingScores stores the painting's trending scores. When the ranking job cleared
+// painting.Ranking the painting also leaves the ranking index, unless a reaction or view came in
+// since it was read.
+func (s *Store) PutPaintingScores(painting *model.Painting) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.painting(painting.UserId, painting.Timestamp)
+	if !ok {
+		return nil
+	}
+	if painting.Ranking == "" {
+		if stored.LastActive != painting.LastActive {
+			return nil
+		}
+		stored.Ranking = ""
+	}
+	stored.TrendingToday = painting.TrendingToday
+	stored.TrendingWeek = painting.TrendingWeek
+	stored.TrendingAll = painting.TrendingAll
+	s.putPainting(stored)
+	return nil
+}
+
+// GetDuePaintings returns drafts whose publish time has come, plus paintings a previous run
+// started to publish but did not finish.
+func (s *Store) GetDuePaintings(now uint64) ([]model.Painting, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due, publishing []model.Painting
+	for _, painting := range s.allPaintings() {
+		switch {
+		case painting.Schedule == model.ScheduleWaiting && painting.PublishAt <= now:
+			due = append(due, painting)
+		case painting.Schedule == model.SchedulePublishing:
+			publishing = append(publishing, painting)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].PublishAt < due[j].PublishAt
+	})
+	sort.SliceStable(publishing, func(i, j int) bool {
+		return publishing[i].PublishAt < publishing[j].PublishAt
+	})
+	return append(due, publishing...), nil
+}
+
+// StartPublishing takes a due draft out of draft state and updates painting in place.
+// It returns false when another run got there first or the owner cancelled the schedule.
+func (s *Store) StartPublishing(painting *model.Painting, now uint64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.painting(painting.UserId, painting.Timestamp)
+	if !ok || stored.Schedule != model.ScheduleWaiting || stored.PublishAt > now {
+		return false, nil
+	}
+	stored.Draft = false
+	stored.Schedule = model.SchedulePublishing
+	stored.Version++
+	s.putPainting(stored)
+	*painting = stored
+	return true, nil
+}
+
+func (s *Store) FinishPublishing(painting *model.Painting) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.painting(painting.UserId, painting.Timestamp)
+	if !ok || stored.Schedule != model.SchedulePublishing {
+		return nil
+	}
+	stored.Schedule = ""
+	stored.Version++
+	s.putPainting(stored)
+	return nil
+}
+
+// CancelSchedule keeps the painting as a draft and updates painting in place. It fails once
+// publishing has started, or when the painting is no longer at painting.Version.
+func (s *Store) CancelSchedule(painting *model.Painting) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.painting(painting.UserId, painting.Timestamp)
+	if !ok || stored.Schedule != model.ScheduleWaiting || stored.Version != painting.Version {
+		return domain.ErrConflict
+	}
+	stored.Schedule = ""
+	stored.PublishAt = 0
+	stored.Version++
+	s.putPainting(stored)
+	*painting = stored
+	return nil
+}
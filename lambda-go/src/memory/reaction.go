@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+// counter attribute of the painting item for each reaction kind.
+var reactionCounters = map[model.ReactionKind]string{
+	model.ReactionLike:     "Likes",
+	model.ReactionFavorite: "Favorits",
+}
+
+// PutReaction records a like or favorite and bumps the painting's counter. It returns false when
+// the user had already reacted the same way.
+func (s *Store) PutReaction(reaction model.Reaction) (bool, error) {
+	s.mu.Lock()
+	reaction.ReactionKey = model.ReactionKey(reaction.Kind, reaction.UserId)
+	if _, ok := s.reactions[reaction.PaintingId][reaction.ReactionKey]; ok {
+		s.mu.Unlock()
+		return false, nil
+	}
+	if s.reactions[reaction.PaintingId] == nil {
+		s.reactions[reaction.PaintingId] = map[string]model.Reaction{}
+	}
+	s.reactions[reaction.PaintingId][reaction.ReactionKey] = reaction
+	s.mu.Unlock()
+	return true, s.IncrementPaintingCounter(reaction.PaintingUserId, reaction.PaintingTimestamp, reactionCounters[reaction.Kind], 1)
+}
+
+// DeleteReaction removes a like or favorite. It returns false when there was nothing to remove.
+func (s *Store) DeleteReaction(painting *model.Painting, kind model.ReactionKind, userId string) (bool, error) {
+	s.mu.Lock()
+	key := model.ReactionKey(kind, userId)
+	if _, ok := s.reactions[painting.GetId()][key]; !ok {
+		s.mu.Unlock()
+		return false, nil
+	}
+	delete(s.reactions[painting.GetId()], key)
+	s.mu.Unlock()
+	return true, s.IncrementPaintingCounter(painting.UserId, painting.Timestamp, reactionCounters[kind], -1)
+}
+
+// ListUserReactions returns the likes and favorites userId gave, the oldest first.
+func (s *Store) ListUserReactions(userId string) ([]model.Reaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.Reaction{}
+	for _, paintingId := range rangeKeys(s.reactions, false) {
+		for _, key := range rangeKeys(s.reactions[paintingId], false) {
+			if reaction := s.reactions[paintingId][key]; reaction.UserId == userId {
+				result = append(result, reaction)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Created < result[j].Created
+	})
+	return result, nil
+}
+
+func (s *Store) PutComment(comment *model.Comment) error {
+	s.mu.Lock()
+	if s.comments[comment.PaintingId] == nil {
+		s.comments[comment.PaintingId] = map[string]model.Comment{}
+	}
+	s.comments[comment.PaintingId][comment.CommentId] = *comment
+	s.mu.Unlock()
+	return s.IncrementPaintingCounter(comment.PaintingUserId, comment.PaintingTimestamp, "Comments", 1)
+}
+
+func (s *Store) GetComment(paintingId string, commentId string) (model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment, ok := s.comments[paintingId][commentId]
+	if !ok {
+		return comment, domain.ErrNotFound
+	}
+	return comment, nil
+}
+
+func (s *Store) ListComments(paintingId string) ([]model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.Comment{}
+	for _, commentId := range rangeKeys(s.comments[paintingId], false) {
+		result = append(result, s.comments[paintingId][commentId])
+	}
+	return result, nil
+}
+
+func (s *Store) DeleteComment(comment *model.Comment) error {
+	s.mu.Lock()
+	delete(s.comments[comment.PaintingId], comment.CommentId)
+	s.mu.Unlock()
+	return s.IncrementPaintingCounter(comment.PaintingUserId, comment.PaintingTimestamp, "Comments", -1)
+}
+
+// ListUserComments returns the comments userId wrote on any painting.
+func (s *Store) ListUserComments(userId string) ([]model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.Comment{}
+	for _, paintingId := range rangeKeys(s.comments, false) {
+		for _, commentId := range rangeKeys(s.comments[paintingId], false) {
+			if comment := s.comments[paintingId][commentId]; comment.UserId == userId {
+				result = append(result, comment)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CommentId < result[j].CommentId
+	})
+	return result, nil
+}
+
+// AnonymizeComment detaches the comment from its author and keeps it, so threads stay readable.
+func (s *Store) AnonymizeComment(comment *model.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.comments[comment.PaintingId][comment.CommentId]; ok {
+		stored.UserId = ""
+		s.comments[comment.PaintingId][comment.CommentId] = stored
+	}
+	return nil
+}
+
+func (s *Store) SetCommentHidden(paintingId string, commentId string, hidden bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.comments[paintingId][commentId]
+	if !ok {
+		return domain.ErrConflict
+	}
+	stored.Hidden = hidden
+	s.comments[paintingId][commentId] = stored
+	return nil
+}
+
+// DeletePaintingActivity removes every reaction and comment on the painting.
+func (s *Store) DeletePaintingActivity(paintingId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reactions, paintingId)
+	delete(s.comments, paintingId)
+	return nil
+}
@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+// PutReport stores the report unless the user already reported the target.
+func (s *Store) PutReport(report model.Report) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.reports[report.ItemId][report.UserId]; ok {
+		return false, nil
+	}
+	if s.reports[report.ItemId] == nil {
+		s.reports[report.ItemId] = map[string]model.Report{}
+	}
+	s.reports[report.ItemId][report.UserId] = report
+	return true, nil
+}
+
+func (s *Store) ListReports(itemId string) ([]model.Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.Report{}
+	for _, userId := range rangeKeys(s.reports[itemId], false) {
+		result = append(result, s.reports[itemId][userId])
+	}
+	return result, nil
+}
+
+// RecordReport adds a report to the target's queue item, creating the item on the first report.
+// An item that was dismissed is opened again.
+func (s *Store) RecordReport(item model.ModerationItem, now uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.moderation[item.ItemId]
+	if !ok {
+		stored = model.ModerationItem{
+			ItemId:            item.ItemId,
+			TargetKind:        item.TargetKind,
+			TargetUserId:      item.TargetUserId,
+			PaintingId:        item.PaintingId,
+			PaintingTimestamp: item.PaintingTimestamp,
+			CommentId:         item.CommentId,
+			State:             model.ModerationOpen,
+			Created:           now,
+		}
+	}
+	stored.LastReported = now
+	stored.ReportCount++
+	if stored.State == model.ModerationDismissed {
+		stored.State = model.ModerationOpen
+		stored.ClaimedBy = ""
+		stored.ClaimedAt = 0
+	}
+	s.moderation[item.ItemId] = stored
+	return nil
+}
+
+func (s *Store) GetModerationItem(itemId string) (model.ModerationItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.moderation[itemId]
+	if !ok {
+		return item, domain.ErrNotFound
+	}
+	return item, nil
+}
+
+// ListModerationItems returns the items in state, the ones reported longest ago first.
+func (s *Store) ListModerationItems(state model.ModerationState) ([]model.ModerationItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []model.ModerationItem{}
+	for _, itemId := range rangeKeys(s.moderation, false) {
+		if item := s.moderation[itemId]; item.State == state {
+			result = append(result, item)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastReported < result[j].LastReported
+	})
+	return result, nil
+}
+
+// updateModerationItem applies update when the stored item passes condition, and returns the
+// item as updated.
+func (s *Store) updateModerationItem(itemId string, condition func(item model.ModerationItem) bool, update func(item *model.ModerationItem)) (model.ModerationItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.moderation[itemId]
+	if !ok || !condition(stored) {
+		return model.ModerationItem{}, domain.ErrConflict
+	}
+	update(&stored)
+	s.moderation[itemId] = stored
+	return stored, nil
+}
+
+// ClaimModerationItem assigns an open item to the moderator unless someone else claimed it first.
+func (s *Store) ClaimModerationItem(itemId string, userId string, now uint64) (model.ModerationItem, error) {
+	return s.updateModerationItem(itemId, func(item model.ModerationItem) bool {
+		return item.State == model.ModerationOpen && (item.ClaimedBy == "" || item.ClaimedBy == userId)
+	}, func(item *model.ModerationItem) {
+		item.ClaimedBy = userId
+		item.ClaimedAt = now
+	})
+}
+
+// ResolveModerationItem closes an item the moderator has claimed.
+func (s *Store) ResolveModerationItem(itemId string, userId string, state model.ModerationState, resolution string, note string, now uint64) (model.ModerationItem, error) {
+	return s.updateModerationItem(itemId, func(item model.ModerationItem) bool {
+		return item.State == model.ModerationOpen && item.ClaimedBy == userId
+	}, func(item *model.ModerationItem) {
+		item.State = state
+		item.Resolution = resolution
+		item.Note = note
+		item.ResolvedBy = userId
+		item.Resolved = now
+	})
+}
+
+// RestoreModerationItem records that an earlier action was undone.
+func (s *Store) RestoreModerationItem(itemId string, userId string, note string, now uint64) (model.ModerationItem, error) {
+	return s.updateModerationItem(itemId, func(item model.ModerationItem) bool {
+		return item.State == model.ModerationActioned
+	}, func(item *model.ModerationItem) {
+		item.State = model.ModerationDismissed
+		item.Resolution = "restore"
+		item.Note = note
+		item.ResolvedBy = userId
+		item.Resolved = now
+	})
+}
+
+// ReopenModerationItem takes back the moderator's resolution when its action could not be taken.
+// The item stays claimed by them.
+func (s *Store) ReopenModerationItem(itemId string, userId string) error {
+	_, err := s.updateModerationItem(itemId, func(item model.ModerationItem) bool {
+		return item.State == model.ModerationActioned && item.ResolvedBy == userId
+	}, func(item *model.ModerationItem) {
+		item.State = model.ModerationOpen
+		item.Resolution = ""
+		item.Note = ""
+		item.ResolvedBy = ""
+		item.Resolved = 0
+	})
+	return err
+}
+
+func (s *Store) PutAuditEntry(entry model.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.audit[entry.Month] == nil {
+		s.audit[entry.Month] = map[string]model.AuditEntry{}
+	}
+	s.audit[entry.Month][entry.AuditId] = entry
+	return nil
+}
+
+// ListAuditEntries returns the month's entries, newest first.
+func (s *Store) ListAuditEntries(month string) ([]model.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.AuditEntry
+	for _, auditId := range rangeKeys(s.audit[month], true) {
+		result = append(result, s.audit[month][auditId])
+	}
+	return result, nil
+}
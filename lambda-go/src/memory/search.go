@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+// SearchIndex serves the public listings from memory the way db.SearchIndex does from Elasticsearch.
+type SearchIndex struct {
+	mu   sync.Mutex
+	docs map[string][]byte
+}
+
+var _ domain.SearchIndex = (*SearchIndex)(nil)
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{docs: map[string][]byte{}}
+}
+
+// documents are kept as the JSON Elasticsearch would keep as _source.
+func (index *SearchIndex) PutEsPainting(painting *model.Painting) error {
+	doc, err := json.Marshal(painting)
+	if err != nil {
+		return err
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.docs[painting.GetId()] = doc
+	return nil
+}
+
+func (index *SearchIndex) DeleteEsPainting(painting *model.Painting) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	delete(index.docs, painting.GetId())
+	return nil
+}
+
+// UpdateEsScores writes the trending scores into the painting's document. Paintings that are not
+// indexed are left alone.
+func (index *SearchIndex) UpdateEsScores(painting *model.Painting) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	doc, ok := index.docs[painting.GetId()]
+	if !ok {
+		return nil
+	}
+	var indexed model.Painting
+	if err := json.Unmarshal(doc, &indexed); err != nil {
+		return err
+	}
+	indexed.TrendingToday = painting.TrendingToday
+	indexed.TrendingWeek = painting.TrendingWeek
+	indexed.TrendingAll = painting.TrendingAll
+	doc, err := json.Marshal(indexed)
+	if err != nil {
+		return err
+	}
+	index.docs[painting.GetId()] = doc
+	return nil
+}
+
+// score returns the painting's score for the trending mode, or 0 for the newest first listing.
+func score(painting model.Painting, trending string) float64 {
+	switch trending {
+	case domain.TrendingToday:
+		return painting.TrendingToday
+	case domain.TrendingWeek:
+		return painting.TrendingWeek
+	case domain.TrendingAll:
+		return painting.TrendingAll
+	default:
+		return 0
+	}
+}
+
+// ListWaterColorSite lists a page of 10 public paintings, without the ones by excludedUserIds.
+func (index *SearchIndex) ListWaterColorSite(offset int, trending string, excludedUserIds []string) []model.Painting {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	excluded := map[string]bool{}
+	for _, userId := range excludedUserIds {
+		excluded[userId] = true
+	}
+	hits := []model.Painting{}
+	for _, id := range rangeKeys(index.docs, false) {
+		var painting model.Painting
+		if err := json.Unmarshal(index.docs[id], &painting); err != nil {
+			continue
+		}
+		if !painting.IsPublic() || excluded[painting.UserId] {
+			continue
+		}
+		hits = append(hits, painting)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if a, b := score(hits[i], trending), score(hits[j], trending); a != b {
+			return a > b
+		}
+		return hits[i].Timestamp > hits[j].Timestamp
+	})
+	if offset >= len(hits) {
+		return []model.Painting{}
+	}
+	hits = hits[offset:]
+	if len(hits) > 10 {
+		hits = hits[:10]
+	}
+	return hits
+}
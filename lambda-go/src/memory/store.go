@@ -0,0 +1,116 @@
+// Package memory keeps everything the site stores in process memory, for running the API without
+// AWS and for tests. It follows the DynamoDB, Elasticsearch and S3 backends closely: the same sort
+// orders and page limits, the same conditional writes, and the same domain.ErrNotFound and
+// domain.ErrConflict.
+package memory
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/guregu/dynamo"
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+// Store implements every repository of the domain package, like db.Store. Items are copied in and
+// out through the DynamoDB marshaller, so callers never share memory with the store and only what
+// DynamoDB would keep survives a write.
+type Store struct {
+	mu               sync.Mutex
+	paintings        map[string]map[string]model.Painting
+	sessions         map[string]model.Session
+	users            map[string]model.User
+	follows          map[string]map[string]model.Follow
+	notifications    map[string]map[string]model.Notification
+	collections      map[string]map[string]model.Collection
+	challenges       map[string]model.Challenge
+	challengeEntries map[string]map[string]model.ChallengeEntry
+	challengeVotes   map[string]map[string]model.ChallengeVote
+	reactions        map[string]map[string]model.Reaction
+	comments         map[string]map[string]model.Comment
+	identities       map[string]model.Identity
+	apiTokens        map[string]model.ApiToken
+	audit            map[string]map[string]model.AuditEntry
+	reports          map[string]map[string]model.Report
+	moderation       map[string]model.ModerationItem
+	relations        map[string]map[string]model.Relation
+	exports          map[string]map[string]model.Export
+	idempotency      map[string]model.IdempotencyRecord
+}
+
+var (
+	_ domain.PaintingRepository     = (*Store)(nil)
+	_ domain.UserRepository         = (*Store)(nil)
+	_ domain.SessionStore           = (*Store)(nil)
+	_ domain.FollowRepository       = (*Store)(nil)
+	_ domain.NotificationRepository = (*Store)(nil)
+	_ domain.ReactionRepository     = (*Store)(nil)
+	_ domain.CollectionRepository   = (*Store)(nil)
+	_ domain.ChallengeRepository    = (*Store)(nil)
+	_ domain.IdentityRepository     = (*Store)(nil)
+	_ domain.TokenRepository        = (*Store)(nil)
+	_ domain.AuditLog               = (*Store)(nil)
+	_ domain.ReportRepository       = (*Store)(nil)
+	_ domain.RelationRepository     = (*Store)(nil)
+	_ domain.ExportRepository       = (*Store)(nil)
+	_ domain.IdempotencyStore       = (*Store)(nil)
+)
+
+func NewStore() *Store {
+	return &Store{
+		paintings:        map[string]map[string]model.Painting{},
+		sessions:         map[string]model.Session{},
+		users:            map[string]model.User{},
+		follows:          map[string]map[string]model.Follow{},
+		notifications:    map[string]map[string]model.Notification{},
+		collections:      map[string]map[string]model.Collection{},
+		challenges:       map[string]model.Challenge{},
+		challengeEntries: map[string]map[string]model.ChallengeEntry{},
+		challengeVotes:   map[string]map[string]model.ChallengeVote{},
+		reactions:        map[string]map[string]model.Reaction{},
+		comments:         map[string]map[string]model.Comment{},
+		identities:       map[string]model.Identity{},
+		apiTokens:        map[string]model.ApiToken{},
+		audit:            map[string]map[string]model.AuditEntry{},
+		reports:          map[string]map[string]model.Report{},
+		moderation:       map[string]model.ModerationItem{},
+		relations:        map[string]map[string]model.Relation{},
+		exports:          map[string]map[string]model.Export{},
+		idempotency:      map[string]model.IdempotencyRecord{},
+	}
+}
+
+// maxItemSize is the most DynamoDB stores in one item, in bytes.
+const maxItemSize = 400 * 1024
+
+// errItemTooLarge is returned, like DynamoDB does, when a write would take an item over maxItemSize.
+var errItemTooLarge = errors.New("item size has exceeded the maximum allowed size")
+
+// copyItem copies in to out the way a write and a later read through DynamoDB would.
+func copyItem(in interface{}, out interface{}) {
+	item, err := dynamo.MarshalItem(in)
+	if err != nil {
+		panic(err)
+	}
+	if err := dynamo.UnmarshalItem(item, out); err != nil {
+		panic(err)
+	}
+}
+
+// rangeKeys returns the keys of a map, such as the items of one partition, in the order a query
+// reads them.
+func rangeKeys(items interface{}, descending bool) []string {
+	keys := []string{}
+	for _, key := range reflect.ValueOf(items).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	if descending {
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	} else {
+		sort.Strings(keys)
+	}
+	return keys
+}
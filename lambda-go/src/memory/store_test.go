@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+func TestStore(t *testing.T) {
+	t.Run("user paintings page newest first", func(t *testing.T) {
+		s := NewStore()
+		for _, timestamp := range []string{"20210101000000001", "20210101000000003", "20210101000000002"} {
+			if err := s.AddPainting(&model.Painting{UserId: "u", Timestamp: timestamp}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		page, _ := s.ListUserPaintings("u", "", 2)
+		if len(page) != 2 || page[0].Timestamp != "20210101000000003" || page[1].Timestamp != "20210101000000002" {
+			t.Fatalf("unexpected first page %+v", page)
+		}
+		page, _ = s.ListUserPaintings("u", page[1].Timestamp, 2)
+		if len(page) != 1 || page[0].Timestamp != "20210101000000001" {
+			t.Fatalf("unexpected second page %+v", page)
+		}
+	})
+
+	t.Run("errors match the DynamoDB store", func(t *testing.T) {
+		s := NewStore()
+		if _, err := s.GetPainting("u", "1"); !errors.Is(err, domain.ErrNotFound) {
+			t.Fatalf("expected not found, got %v", err)
+		}
+		if err := s.CancelSchedule(&model.Painting{UserId: "u", Timestamp: "1"}); !errors.Is(err, domain.ErrConflict) {
+			t.Fatalf("expected a failed condition, got %v", err)
+		}
+		identity := model.Identity{Provider: "memory", Subject: "dev", UserId: "u"}
+		if err := s.LinkIdentity(identity); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.LinkIdentity(identity); !errors.Is(err, domain.ErrConflict) {
+			t.Fatalf("expected a failed condition, got %v", err)
+		}
+	})
+
+	t.Run("reactions count once and counters stay at zero or above", func(t *testing.T) {
+		s := NewStore()
+		painting := model.Painting{UserId: "u", Timestamp: "1"}
+		s.AddPainting(&painting)
+		reaction := model.Reaction{PaintingId: painting.GetId(), Kind: model.ReactionLike, UserId: "v", PaintingUserId: "u", PaintingTimestamp: "1"}
+		for i, want := range []bool{true, false} {
+			if ok, err := s.PutReaction(reaction); err != nil || ok != want {
+				t.Fatalf("put %d: got %v %v", i, ok, err)
+			}
+		}
+		s.IncrementPaintingCounter("u", "1", "Likes", -5)
+		stored, _ := s.GetPainting("u", "1")
+		if stored.Likes != 1 {
+			t.Fatalf("expected 1 like, got %d", stored.Likes)
+		}
+	})
+
+	t.Run("stored items do not share memory with callers", func(t *testing.T) {
+		s := NewStore()
+		collection := model.Collection{UserId: "u", CollectionId: "c", Paintings: []model.PaintingRef{{UserId: "u", Timestamp: "1"}}}
+		s.PutCollection(&collection)
+		collection.Paintings[0].Timestamp = "2"
+		stored, _ := s.GetCollection("u", "c")
+		if stored.Paintings[0].Timestamp != "1" {
+			t.Fatalf("store changed through the caller's slice: %+v", stored)
+		}
+	})
+}
+
+func TestSearchIndex(t *testing.T) {
+	index := NewSearchIndex()
+	index.PutEsPainting(&model.Painting{UserId: "a", Timestamp: "1", TrendingToday: 2})
+	index.PutEsPainting(&model.Painting{UserId: "b", Timestamp: "2"})
+	index.PutEsPainting(&model.Painting{UserId: "c", Timestamp: "3", Draft: true})
+	index.PutEsPainting(&model.Painting{UserId: "d", Timestamp: "4", Visibility: model.VisibilityPrivate})
+
+	if newest := index.ListWaterColorSite(0, "", nil); len(newest) != 2 || newest[0].UserId != "b" {
+		t.Fatalf("unexpected newest listing %+v", newest)
+	}
+	if trending := index.ListWaterColorSite(0, domain.TrendingToday, nil); len(trending) != 2 || trending[0].UserId != "a" {
+		t.Fatalf("unexpected trending listing %+v", trending)
+	}
+	if excluded := index.ListWaterColorSite(0, "", []string{"b"}); len(excluded) != 1 || excluded[0].UserId != "a" {
+		t.Fatalf("excluded author listed %+v", excluded)
+	}
+	if past := index.ListWaterColorSite(10, "", nil); len(past) != 0 {
+		t.Fatalf("expected an empty page, got %+v", past)
+	}
+}
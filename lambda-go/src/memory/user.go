@@ -0,0 +1,220 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/hirosato/wcs/domain"
+	"github.com/hirosato/wcs/model"
+)
+
+func (s *Store) user(userId string) (user model.User, ok bool) {
+	stored, ok := s.users[userId]
+	if ok {
+		copyItem(stored, &user)
+	}
+	return user, ok
+}
+
+func (s *Store) putUser(user model.User) {
+	var stored model.User
+	copyItem(user, &stored)
+	s.users[user.UserId] = stored
+}
+
+// updateUser applies update to an existing user. Like the conditional updates of db.Store, it fails
+// when there is no such user.
+func (s *Store) updateUser(userId string, update func(user *model.User)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.user(userId)
+	if !ok {
+		return domain.ErrConflict
+	}
+	update(&user)
+	s.putUser(user)
+	return nil
+}
+
+func (s *Store) GetUser(userId string) (model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.user(userId)
+	if !ok {
+		return user, domain.ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *Store) PutUser(user model.User) (model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putUser(user)
+	return user, nil
+}
+
+// RefreshUserFromProvider writes the name and avatar a login brings in, leaving every other field
+// alone so edits made in between are kept.
+func (s *Store) RefreshUserFromProvider(user model.User) error {
+	return s.updateUser(user.UserId, func(stored *model.User) {
+		stored.DisplayName = user.DisplayName
+		stored.AvatarURL = user.AvatarURL
+		stored.ProviderAvatarURL = user.ProviderAvatarURL
+	})
+}
+
+// SetUserImages writes the user's uploaded avatar or header.
+func (s *Store) SetUserImages(user model.User, kind model.ProfileImageKind) error {
+	return s.updateUser(user.UserId, func(stored *model.User) {
+		if kind == model.ProfileAvatar {
+			stored.Avatars = user.Avatars
+			stored.AvatarURL = user.AvatarURL
+			stored.AvatarURLEdited = user.AvatarURLEdited
+			stored.ProviderAvatarURL = user.ProviderAvatarURL
+		} else {
+			stored.Headers = user.Headers
+		}
+	})
+}
+
+// UpdateUserProfile writes the fields users edit themselves.
+func (s *Store) UpdateUserProfile(user model.User) error {
+	return s.updateUser(user.UserId, func(stored *model.User) {
+		stored.DisplayName = user.DisplayName
+		stored.DisplayNameEdited = user.DisplayNameEdited
+		stored.Bio = user.Bio
+		stored.Website = user.Website
+		stored.Location = user.Location
+		stored.Language = user.Language
+		stored.FavoriteEquipments = user.FavoriteEquipments
+	})
+}
+
+func (s *Store) SetUserSuspended(userId string, suspended bool, reason string) error {
+	return s.updateUser(userId, func(stored *model.User) {
+		stored.Suspended = suspended
+		stored.SuspendedReason = reason
+		if !suspended {
+			stored.SuspendedReason = ""
+		}
+	})
+}
+
+func (s *Store) SetUserRole(userId string, role model.Role) error {
+	return s.updateUser(userId, func(stored *model.User) {
+		stored.Role = role
+	})
+}
+
+// ScheduleDeletion marks the user for deletion at deleteAt.
+func (s *Store) ScheduleDeletion(userId string, deleteAt uint64) error {
+	return s.updateUser(userId, func(stored *model.User) {
+		stored.Deletion = model.DeletionScheduled
+		stored.DeleteAt = deleteAt
+	})
+}
+
+func (s *Store) CancelDeletion(userId string) error {
+	// unconditional in db.Store, so a missing user is not an error.
+	s.updateUser(userId, func(stored *model.User) {
+		stored.Deletion = ""
+		stored.DeleteAt = 0
+	})
+	return nil
+}
+
+// GetUsersDueForDeletion returns users whose grace period has run out, the earliest first.
+func (s *Store) GetUsersDueForDeletion(now uint64) ([]model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.User
+	for _, userId := range rangeKeys(s.users, false) {
+		user, _ := s.user(userId)
+		if user.Deletion == model.DeletionScheduled && user.DeleteAt <= now {
+			result = append(result, user)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DeleteAt < result[j].DeleteAt
+	})
+	return result, nil
+}
+
+func (s *Store) DeleteUser(userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.users, userId)
+	return nil
+}
+
+func (s *Store) GetSession(sessionId string) (model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result model.Session
+	stored, ok := s.sessions[sessionId]
+	if !ok {
+		return result, domain.ErrNotFound
+	}
+	copyItem(stored, &result)
+	return result, nil
+}
+
+func (s *Store) PutSession(session model.Session) (model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var stored model.Session
+	copyItem(session, &stored)
+	s.sessions[session.SessionId] = stored
+	return session, nil
+}
+
+func (s *Store) TouchSession(session model.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.sessions[session.SessionId]
+	if !ok {
+		return domain.ErrConflict
+	}
+	if stored.Created == 0 {
+		stored.Created = session.Created
+	}
+	stored.LastSeen = session.LastSeen
+	stored.ExpiresAt = session.ExpiresAt
+	s.sessions[session.SessionId] = stored
+	return nil
+}
+
+func (s *Store) SetSessionCsrfToken(sessionId string, token string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.sessions[sessionId]
+	if !ok {
+		return "", domain.ErrConflict
+	}
+	if stored.CsrfToken == "" {
+		stored.CsrfToken = token
+		s.sessions[sessionId] = stored
+	}
+	return stored.CsrfToken, nil
+}
+
+func (s *Store) DeleteSession(sessionId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionId)
+	return nil
+}
+
+// ListUserSessions returns every session logged in as the user.
+func (s *Store) ListUserSessions(userId string) ([]model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []model.Session
+	for _, sessionId := range rangeKeys(s.sessions, false) {
+		if stored := s.sessions[sessionId]; stored.UserId != "" && stored.UserId == userId {
+			var session model.Session
+			copyItem(stored, &session)
+			result = append(result, session)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,29 @@
+package model
+
+// accounts are deleted this long after the user asks, so a change of mind can still be undone.
+const DeletionGracePeriod = 14 * 24 * 60 * 60 * 1000
+
+// Deletion state of a user waiting for their account to be deleted. Other users leave it empty.
+const DeletionScheduled = "scheduled"
+
+// the export bucket drops a ZIP this long after it is written.
+const ExportLifetime = 7 * 24 * 60 * 60 * 1000
+
+type ExportState string
+
+const (
+	ExportPending = ExportState("pending")
+	ExportReady   = ExportState("ready")
+	ExportFailed  = ExportState("failed")
+)
+
+// Export is a ZIP of a user's data, built in the background and kept in the private export bucket.
+type Export struct {
+	UserId   string      `json:"userId" dynamodbav:"UserId"`
+	ExportId string      `json:"exportId" dynamodbav:"ExportId"`
+	State    ExportState `json:"state" dynamodbav:"State"`
+	Key      string      `json:"-" dynamodbav:"Key"`
+	Error    string      `json:"error,omitempty" dynamodbav:"Error"`
+	Created  uint64      `json:"created" dynamodbav:"Created"`
+	Finished uint64      `json:"finished,omitempty" dynamodbav:"Finished"`
+}
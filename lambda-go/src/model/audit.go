@@ -0,0 +1,14 @@
+package model
+
+// AuditEntry records one action taken through the admin API. Entries are partitioned by month so
+// a month can be read in order.
+type AuditEntry struct {
+	Month      string `json:"month" dynamodbav:"Month"`
+	AuditId    string `json:"auditId" dynamodbav:"AuditId"`
+	ActorId    string `json:"actorId" dynamodbav:"ActorId"`
+	Action     string `json:"action" dynamodbav:"Action"`
+	TargetKind string `json:"targetKind" dynamodbav:"TargetKind"`
+	TargetId   string `json:"targetId" dynamodbav:"TargetId"`
+	Detail     string `json:"detail" dynamodbav:"Detail"`
+	Created    uint64 `json:"created" dynamodbav:"Created"`
+}
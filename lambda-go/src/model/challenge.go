@@ -0,0 +1,107 @@
+package model
+
+import "sort"
+
+type ChallengeState string
+
+const (
+	ChallengeUpcoming = ChallengeState("upcoming")
+	ChallengeOpen     = ChallengeState("open")
+	ChallengeVoting   = ChallengeState("voting")
+	ChallengeClosing  = ChallengeState("closing")
+	ChallengeClosed   = ChallengeState("closed")
+)
+
+// Challenge is a themed event. Paintings are submitted between StartAt and EndAt, and the community
+// votes on the entries between EndAt and VoteEndAt. Results are frozen when the challenge is closed.
+type Challenge struct {
+	ChallengeId string            `json:"challengeId" dynamodbav:"ChallengeId"`
+	Title       string            `json:"title" dynamodbav:"Title"`
+	Theme       string            `json:"theme" dynamodbav:"Theme"`
+	StartAt     uint64            `json:"startAt" dynamodbav:"StartAt"`
+	EndAt       uint64            `json:"endAt" dynamodbav:"EndAt"`
+	VoteEndAt   uint64            `json:"voteEndAt" dynamodbav:"VoteEndAt"`
+	Closed      bool              `json:"closed" dynamodbav:"Closed"`
+	Results     []ChallengeResult `json:"results,omitempty" dynamodbav:"Results"`
+	CreatedBy   string            `json:"createdBy" dynamodbav:"CreatedBy"`
+	Created     uint64            `json:"created" dynamodbav:"Created"`
+}
+
+func (challenge *Challenge) State(now uint64) ChallengeState {
+	switch {
+	case challenge.Closed:
+		return ChallengeClosed
+	case now < challenge.StartAt:
+		return ChallengeUpcoming
+	case now < challenge.EndAt:
+		return ChallengeOpen
+	case now < challenge.VoteEndAt:
+		return ChallengeVoting
+	default:
+		return ChallengeClosing
+	}
+}
+
+type ChallengeEntry struct {
+	ChallengeId       string `json:"challengeId" dynamodbav:"ChallengeId"`
+	EntryId           string `json:"entryId" dynamodbav:"EntryId"`
+	PaintingUserId    string `json:"paintingUserId" dynamodbav:"PaintingUserId"`
+	PaintingTimestamp string `json:"paintingTimestamp" dynamodbav:"PaintingTimestamp"`
+	Votes             uint32 `json:"votes" dynamodbav:"Votes"`
+	Created           uint64 `json:"created" dynamodbav:"Created"`
+}
+
+// ChallengeVote exists once per user and entry. EntryKey joins the challenge and entry ids.
+type ChallengeVote struct {
+	EntryKey string `json:"entryKey" dynamodbav:"EntryKey"`
+	UserId   string `json:"userId" dynamodbav:"UserId"`
+	Created  uint64 `json:"created" dynamodbav:"Created"`
+}
+
+func (entry *ChallengeEntry) PaintingRef() PaintingRef {
+	return PaintingRef{UserId: entry.PaintingUserId, Timestamp: entry.PaintingTimestamp}
+}
+
+func ChallengeEntryKey(challengeId string, entryId string) string {
+	return challengeId + "#" + entryId
+}
+
+type ChallengeResult struct {
+	Rank              int    `json:"rank" dynamodbav:"Rank"`
+	EntryId           string `json:"entryId" dynamodbav:"EntryId"`
+	PaintingUserId    string `json:"paintingUserId" dynamodbav:"PaintingUserId"`
+	PaintingTimestamp string `json:"paintingTimestamp" dynamodbav:"PaintingTimestamp"`
+	Votes             uint32 `json:"votes" dynamodbav:"Votes"`
+}
+
+func (result *ChallengeResult) PaintingRef() PaintingRef {
+	return PaintingRef{UserId: result.PaintingUserId, Timestamp: result.PaintingTimestamp}
+}
+
+// RankChallengeEntries orders entries by votes, earlier submissions first on a tie. Tied entries share
+// a rank and the next rank is skipped, so two firsts are followed by a third.
+func RankChallengeEntries(entries []ChallengeEntry) []ChallengeResult {
+	sorted := make([]ChallengeEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Votes != sorted[j].Votes {
+			return sorted[i].Votes > sorted[j].Votes
+		}
+		return sorted[i].Created < sorted[j].Created
+	})
+	results := make([]ChallengeResult, len(sorted))
+	for i, entry := range sorted {
+		rank := i + 1
+		if i > 0 && entry.Votes == sorted[i-1].Votes {
+			rank = results[i-1].Rank
+		}
+		results[i] = ChallengeResult{
+			Rank:              rank,
+			EntryId:           entry.EntryId,
+			PaintingUserId:    entry.PaintingUserId,
+			PaintingTimestamp: entry.PaintingTimestamp,
+			Votes:             entry.Votes,
+		}
+	}
+	return results
+}
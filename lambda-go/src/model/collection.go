@@ -0,0 +1,37 @@
+package model
+
+// PaintingRef points at a painting by its table keys.
+type PaintingRef struct {
+	UserId    string `json:"userId" dynamodbav:"UserId"`
+	Timestamp string `json:"timestamp" dynamodbav:"Timestamp"`
+}
+
+func (ref PaintingRef) GetId() string {
+	return ref.UserId + "-" + ref.Timestamp
+}
+
+func (painting *Painting) AsRef() PaintingRef {
+	return PaintingRef{UserId: painting.UserId, Timestamp: painting.Timestamp}
+}
+
+// Collection is an ordered list of paintings curated by a user, such as a series of their own
+// works or favorites by other painters.
+type Collection struct {
+	UserId       string        `json:"userId" dynamodbav:"UserId"`
+	CollectionId string        `json:"collectionId" dynamodbav:"CollectionId"`
+	Title        string        `json:"title" dynamodbav:"Title"`
+	Description  string        `json:"description" dynamodbav:"Description"`
+	Cover        *PaintingRef  `json:"cover,omitempty" dynamodbav:"Cover"`
+	Paintings    []PaintingRef `json:"paintings" dynamodbav:"Paintings"`
+	Created      uint64        `json:"created" dynamodbav:"Created"`
+	Updated      uint64        `json:"updated" dynamodbav:"Updated"`
+}
+
+func (collection *Collection) IndexOf(ref PaintingRef) int {
+	for i, p := range collection.Paintings {
+		if p == ref {
+			return i
+		}
+	}
+	return -1
+}
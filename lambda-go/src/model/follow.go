@@ -0,0 +1,7 @@
+package model
+
+type Follow struct {
+	UserId     string `json:"userId" dynamodbav:"UserId"`
+	FolloweeId string `json:"followeeId" dynamodbav:"FolloweeId"`
+	Created    uint64 `json:"created" dynamodbav:"Created"`
+}
@@ -0,0 +1,28 @@
+package model
+
+// IdempotencyLifetime is how long the response to a request with an Idempotency-Key is kept, in seconds.
+const IdempotencyLifetime = 24 * 60 * 60
+
+// IdempotencyPendingTimeout is how long a key whose request never finished, because its lambda
+// died, blocks retries, in seconds. It is longer than API Gateway lets a request run.
+const IdempotencyPendingTimeout = 60
+
+// IdempotencyRecord keeps the response to a request sent with an Idempotency-Key header, so a
+// retry of the request gets the same response instead of doing the work again.
+type IdempotencyRecord struct {
+	// the user's id and the key, so one user's key never replays another's response.
+	IdempotencyKey string
+	// sha256 of the method, path and body of the request the key was first sent with.
+	Fingerprint string
+	// zero while the first request is still running.
+	Status int
+	Body   []byte
+	// unix seconds. ExpiresAt is also the table's TTL attribute.
+	Created   int64
+	ExpiresAt int64
+}
+
+// IsPending reports whether the first request with the key may still be running at now.
+func (record IdempotencyRecord) IsPending(now int64) bool {
+	return record.Status == 0 && now < record.Created+IdempotencyPendingTimeout
+}
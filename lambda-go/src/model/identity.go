@@ -0,0 +1,16 @@
+package model
+
+// Identity links an account at an external provider to a user. One user can sign in with several.
+type Identity struct {
+	IdentityKey string `json:"-" dynamodbav:"IdentityKey"`
+	Provider    string `json:"provider" dynamodbav:"Provider"`
+	Subject     string `json:"subject" dynamodbav:"Subject"`
+	UserId      string `json:"userId" dynamodbav:"UserId"`
+	DisplayName string `json:"displayName" dynamodbav:"DisplayName"`
+	AvatarURL   string `json:"avatarUrl" dynamodbav:"AvatarURL"`
+	Created     uint64 `json:"created" dynamodbav:"Created"`
+}
+
+func IdentityKey(provider string, subject string) string {
+	return provider + "#" + subject
+}
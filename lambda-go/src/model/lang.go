@@ -21,3 +21,14 @@ func (lang SupportedLang) String() string {
 		return "Unknown"
 	}
 }
+
+func ParseLang(s string) (SupportedLang, bool) {
+	switch s {
+	case "ja":
+		return JA, true
+	case "en":
+		return EN, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,17 @@
+package model
+
+type NotificationKind string
+
+const (
+	NotificationPublished = NotificationKind("published")
+)
+
+type Notification struct {
+	UserId            string           `json:"userId" dynamodbav:"UserId"`
+	NotificationId    string           `json:"notificationId" dynamodbav:"NotificationId"`
+	Kind              NotificationKind `json:"kind" dynamodbav:"Kind"`
+	ActorId           string           `json:"actorId" dynamodbav:"ActorId"`
+	PaintingUserId    string           `json:"paintingUserId" dynamodbav:"PaintingUserId"`
+	PaintingTimestamp string           `json:"paintingTimestamp" dynamodbav:"PaintingTimestamp"`
+	Created           uint64           `json:"created" dynamodbav:"Created"`
+}
@@ -14,6 +14,34 @@ const (
 	Image4     = ImageKind("4")
 )
 
+type Visibility string
+
+const (
+	VisibilityPublic    = Visibility("public")
+	VisibilityUnlisted  = Visibility("unlisted")
+	VisibilityFollowers = Visibility("followers")
+	VisibilityPrivate   = Visibility("private")
+)
+
+func (visibility Visibility) IsValid() bool {
+	switch visibility {
+	case VisibilityPublic, VisibilityUnlisted, VisibilityFollowers, VisibilityPrivate:
+		return true
+	default:
+		return false
+	}
+}
+
+// Schedule states of a draft waiting for its publish time. Paintings without a schedule leave it empty.
+const (
+	ScheduleWaiting    = "scheduled"
+	SchedulePublishing = "publishing"
+)
+
+// RankingActive marks a painting that had a reaction or view recently enough for the ranking job
+// to rescore it. Other paintings leave Ranking empty.
+const RankingActive = "active"
+
 type PaintingImage struct {
 	UserId     string `json:"user_id"`
 	Timestamp  string `json:"timestamp"`
@@ -25,22 +53,86 @@ type PaintingImage struct {
 }
 
 type Painting struct {
-	UserId             string `json:"user_id"`
-	Timestamp          string `json:"timestamp"`
-	Date               string `json:"date"`
-	Title              string `json:"title"`
-	Description        string `json:"description"`
-	Created            uint64 `json:"created"`
-	Updated            uint64 `json:"updated"`
-	Likes              uint32 `json:"likes"`
-	Favorits           uint32 `json:"favorits"`
-	HasImageCover      bool   `json:"has_image_cover"`
-	HasImage1          bool   `json:"has_image1"`
-	HasImage2          bool   `json:"has_image2"`
-	HasImage3          bool   `json:"has_image3"`
-	HasImage4          bool   `json:"has_image4"`
+	// PaintingId is a ULID that identifies the painting by itself. UserId and Timestamp remain the
+	// item's key. Paintings submitted before it existed get one from the assign-painting-ids job.
+	PaintingId    string     `json:"id"`
+	UserId        string     `json:"user_id"`
+	Timestamp     string     `json:"timestamp"`
+	Date          string     `json:"date"`
+	Title         string     `json:"title"`
+	Description   string     `json:"description"`
+	Created       uint64     `json:"created"`
+	Updated       uint64     `json:"updated"`
+	Likes         uint32     `json:"likes"`
+	Favorits      uint32     `json:"favorits"`
+	Comments      uint32     `json:"comments"`
+	Views         uint32     `json:"views"`
+	HasImageCover bool       `json:"has_image_cover"`
+	HasImage1     bool       `json:"has_image1"`
+	HasImage2     bool       `json:"has_image2"`
+	HasImage3     bool       `json:"has_image3"`
+	HasImage4     bool       `json:"has_image4"`
+	Visibility    Visibility `json:"visibility"`
+	Draft         bool       `json:"draft"`
+	PublishAt     uint64     `json:"publish_at"`
+	Schedule      string     `json:"-"`
+	TrendingToday float64    `json:"trending_today"`
+	TrendingWeek  float64    `json:"trending_week"`
+	TrendingAll   float64    `json:"trending_all"`
+	// Ranking and LastActive key the sparse ranking index, which holds the paintings the ranking job
+	// rescores. LastActive is when the last reaction or view came in.
+	Ranking    string `json:"-"`
+	LastActive uint64 `json:"-"`
+	// set by moderators. A hidden painting is only shown to its owner, whatever its visibility.
+	Hidden       bool   `json:"hidden"`
+	HiddenReason string `json:"hidden_reason"`
+	// Version counts the changes to what the owner edits and publishes, so an edit made from a stale
+	// copy fails instead of overwriting a newer one. Paintings submitted before it existed have 0.
+	Version uint64 `json:"version"`
 }
 
+// GetId returns the composite id the painting's reactions, comments, reports and ES document are
+// filed under. It predates PaintingId and is still accepted wherever a painting id is.
 func (painting *Painting) GetId() string {
 	return painting.UserId + "-" + painting.Timestamp
 }
+
+// GetVisibility treats paintings submitted before visibility existed as public.
+func (painting *Painting) GetVisibility() Visibility {
+	if painting.Visibility == "" {
+		return VisibilityPublic
+	}
+	return painting.Visibility
+}
+
+// IsPublic reports whether the painting belongs in the public ES index.
+func (painting *Painting) IsPublic() bool {
+	return !painting.Draft && !painting.Hidden && painting.GetVisibility() == VisibilityPublic
+}
+
+// IsVisibleToFollowers reports whether publishing the painting should notify the painter's followers.
+func (painting *Painting) IsVisibleToFollowers() bool {
+	visibility := painting.GetVisibility()
+	return !painting.Draft && !painting.Hidden && (visibility == VisibilityPublic || visibility == VisibilityFollowers)
+}
+
+// ImageKinds returns the images uploaded for the painting.
+func (painting *Painting) ImageKinds() []ImageKind {
+	kinds := []ImageKind{}
+	if painting.HasImageCover {
+		kinds = append(kinds, ImageCover)
+	}
+	if painting.HasImage1 {
+		kinds = append(kinds, Image1)
+	}
+	if painting.HasImage2 {
+		kinds = append(kinds, Image2)
+	}
+	if painting.HasImage3 {
+		kinds = append(kinds, Image3)
+	}
+	if painting.HasImage4 {
+		kinds = append(kinds, Image4)
+	}
+	return kinds
+}
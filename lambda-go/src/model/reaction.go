@@ -0,0 +1,35 @@
+package model
+
+type ReactionKind string
+
+const (
+	ReactionLike     = ReactionKind("like")
+	ReactionFavorite = ReactionKind("favorite")
+)
+
+// Reaction is a like or favorite of a painting. There is at most one of each kind per user and painting.
+type Reaction struct {
+	PaintingId        string       `json:"paintingId" dynamodbav:"PaintingId"`
+	ReactionKey       string       `json:"-" dynamodbav:"ReactionKey"`
+	Kind              ReactionKind `json:"kind" dynamodbav:"Kind"`
+	UserId            string       `json:"userId" dynamodbav:"UserId"`
+	PaintingUserId    string       `json:"paintingUserId" dynamodbav:"PaintingUserId"`
+	PaintingTimestamp string       `json:"paintingTimestamp" dynamodbav:"PaintingTimestamp"`
+	Created           uint64       `json:"created" dynamodbav:"Created"`
+}
+
+func ReactionKey(kind ReactionKind, userId string) string {
+	return string(kind) + "#" + userId
+}
+
+type Comment struct {
+	PaintingId        string `json:"paintingId" dynamodbav:"PaintingId"`
+	CommentId         string `json:"commentId" dynamodbav:"CommentId"`
+	UserId            string `json:"userId" dynamodbav:"UserId"`
+	PaintingUserId    string `json:"paintingUserId" dynamodbav:"PaintingUserId"`
+	PaintingTimestamp string `json:"paintingTimestamp" dynamodbav:"PaintingTimestamp"`
+	Body              string `json:"body" dynamodbav:"Body"`
+	Created           uint64 `json:"created" dynamodbav:"Created"`
+	// hidden by a moderator. Hidden comments are kept so the decision can be undone.
+	Hidden bool `json:"-" dynamodbav:"Hidden"`
+}
@@ -0,0 +1,23 @@
+package model
+
+// RelationKind is what one user has set up against another.
+type RelationKind string
+
+const (
+	// the target cannot comment on, like or favorite the user's paintings, or follow them.
+	RelationBlock RelationKind = "block"
+	// the target's paintings are left out of everything the user browses.
+	RelationMute RelationKind = "mute"
+)
+
+type Relation struct {
+	UserId      string       `json:"userId" dynamodbav:"UserId"`
+	RelationKey string       `json:"-" dynamodbav:"RelationKey"`
+	Kind        RelationKind `json:"kind" dynamodbav:"Kind"`
+	TargetId    string       `json:"targetId" dynamodbav:"TargetId"`
+	Created     uint64       `json:"created" dynamodbav:"Created"`
+}
+
+func RelationKey(kind RelationKind, targetId string) string {
+	return string(kind) + "#" + targetId
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+type ReportTargetKind string
+
+const (
+	ReportPainting ReportTargetKind = "painting"
+	ReportComment  ReportTargetKind = "comment"
+	ReportUser     ReportTargetKind = "user"
+)
+
+type ReportReason string
+
+const (
+	ReasonSpam       ReportReason = "spam"
+	ReasonHarassment ReportReason = "harassment"
+	ReasonSexual     ReportReason = "sexual"
+	ReasonCopyright  ReportReason = "copyright"
+	ReasonOther      ReportReason = "other"
+)
+
+func (reason ReportReason) IsValid() bool {
+	switch reason {
+	case ReasonSpam, ReasonHarassment, ReasonSexual, ReasonCopyright, ReasonOther:
+		return true
+	}
+	return false
+}
+
+type ModerationState string
+
+const (
+	ModerationOpen      ModerationState = "open"
+	ModerationActioned  ModerationState = "actioned"
+	ModerationDismissed ModerationState = "dismissed"
+)
+
+// Report is one user's flag on a target. A user can report the same target only once.
+type Report struct {
+	ItemId  string       `json:"itemId" dynamodbav:"ItemId"`
+	UserId  string       `json:"userId" dynamodbav:"UserId"`
+	Reason  ReportReason `json:"reason" dynamodbav:"Reason"`
+	Text    string       `json:"text" dynamodbav:"Text"`
+	Created uint64       `json:"created" dynamodbav:"Created"`
+}
+
+// ModerationItem collects every report on one target into a single entry of the moderation queue.
+type ModerationItem struct {
+	ItemId     string           `json:"itemId" dynamodbav:"ItemId"`
+	TargetKind ReportTargetKind `json:"targetKind" dynamodbav:"TargetKind"`
+	// the reported user, or the author of the reported painting or comment.
+	TargetUserId      string          `json:"targetUserId" dynamodbav:"TargetUserId"`
+	PaintingTimestamp string          `json:"paintingTimestamp,omitempty" dynamodbav:"PaintingTimestamp"`
+	PaintingId        string          `json:"paintingId,omitempty" dynamodbav:"PaintingId"`
+	CommentId         string          `json:"commentId,omitempty" dynamodbav:"CommentId"`
+	State             ModerationState `json:"state" dynamodbav:"State"`
+	ReportCount       int             `json:"reportCount" dynamodbav:"ReportCount"`
+	ClaimedBy         string          `json:"claimedBy" dynamodbav:"ClaimedBy"`
+	ClaimedAt         uint64          `json:"claimedAt" dynamodbav:"ClaimedAt"`
+	// what the moderator did: hide, suspend, dismiss or restore.
+	Resolution   string `json:"resolution" dynamodbav:"Resolution"`
+	Note         string `json:"note" dynamodbav:"Note"`
+	ResolvedBy   string `json:"resolvedBy" dynamodbav:"ResolvedBy"`
+	Resolved     uint64 `json:"resolved" dynamodbav:"Resolved"`
+	Created      uint64 `json:"created" dynamodbav:"Created"`
+	LastReported uint64 `json:"lastReported" dynamodbav:"LastReported"`
+}
+
+// TargetId identifies the target for people: the painting or comment id, or the user id.
+func (item ModerationItem) TargetId() string {
+	switch item.TargetKind {
+	case ReportPainting:
+		return item.PaintingId
+	case ReportComment:
+		return item.PaintingId + "/" + item.CommentId
+	default:
+		return item.TargetUserId
+	}
+}
+
+// ModerationItemId is the same for every report on a target, which is what deduplicates them.
+func ModerationItemId(kind ReportTargetKind, targetId string) string {
+	sum := sha256.Sum256([]byte(string(kind) + "#" + targetId))
+	return hex.EncodeToString(sum[:16])
+}
@@ -1,27 +1,42 @@
 package model
 
-import "github.com/gomodule/oauth1/oauth"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
 
 type Session struct {
-	SessionId  string
-	UserId     string
-	TempToken  string
-	TempSecret string
-	Token      string
-	Secret     string
+	SessionId string
+	UserId    string
+	// sign-in in progress: the provider it was started with and what its callback is checked
+	// against. Twitter uses TempToken and TempSecret, OAuth2 providers OAuthState and OAuthVerifier.
+	OAuthProvider string
+	TempToken     string
+	TempSecret    string
+	OAuthState    string
+	OAuthVerifier string
+	// unix seconds. ExpiresAt is also the table's TTL attribute, so DynamoDB removes expired
+	// sessions by itself, although it may take a while.
+	Created   int64
+	LastSeen  int64
+	ExpiresAt int64
+	// client the session was logged in from. A request from another user agent cannot use it.
+	UserAgent string
+	IpAddress string
+	// synchronizer token the SPA echoes back in X-CSRF-Token on every mutating request.
+	CsrfToken string
 }
 
-func (s Session) IsValid() bool {
-	return s.Token != "" && s.Secret != ""
+// Handle identifies the session to its owner without revealing the cookie value.
+func (s Session) Handle() string {
+	sum := sha256.Sum256([]byte(s.SessionId))
+	return hex.EncodeToString(sum[:8])
 }
 
-func (s Session) IsLoggedIn() bool {
-	return s.Token != "" && s.Secret != ""
+func (s Session) IsExpired(now int64) bool {
+	return s.ExpiresAt != 0 && now >= s.ExpiresAt
 }
 
-func (s Session) AsOauthCredentials() (result *oauth.Credentials) {
-	return &oauth.Credentials{
-		Token:  s.Token,
-		Secret: s.Secret,
-	}
+func (s Session) IsLoggedIn() bool {
+	return s.UserId != ""
 }
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// TokenScope limits what a personal access token can be used for.
+type TokenScope string
+
+const (
+	ScopeRead           TokenScope = "read"
+	ScopeWritePaintings TokenScope = "write:paintings"
+	ScopeUploadImages   TokenScope = "upload:images"
+)
+
+func (scope TokenScope) IsValid() bool {
+	switch scope {
+	case ScopeRead, ScopeWritePaintings, ScopeUploadImages:
+		return true
+	}
+	return false
+}
+
+// ApiToken is a personal access token for scripts. Only the hash of the token is stored; the token
+// itself is shown once, when it is created.
+type ApiToken struct {
+	TokenHash string       `json:"-" dynamodbav:"TokenHash"`
+	TokenId   string       `json:"tokenId" dynamodbav:"TokenId"`
+	UserId    string       `json:"userId" dynamodbav:"UserId"`
+	Name      string       `json:"name" dynamodbav:"Name"`
+	Scopes    []TokenScope `json:"scopes" dynamodbav:"Scopes"`
+	Created   uint64       `json:"created" dynamodbav:"Created"`
+	ExpiresAt uint64       `json:"expiresAt" dynamodbav:"ExpiresAt"`
+	LastUsed  uint64       `json:"lastUsed" dynamodbav:"LastUsed"`
+}
+
+func HashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (token ApiToken) IsExpired(now uint64) bool {
+	return now >= token.ExpiresAt
+}
+
+func (token ApiToken) HasScope(scope TokenScope) bool {
+	for _, s := range token.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
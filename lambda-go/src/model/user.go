@@ -1,7 +1,104 @@
 package model
 
+// Role decides what a user may do beyond managing their own content.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+func (role Role) IsValid() bool {
+	return role == RoleUser || role == RoleModerator || role == RoleAdmin
+}
+
+func (role Role) rank() int {
+	switch role {
+	case RoleModerator:
+		return 1
+	case RoleAdmin:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Includes reports whether role grants everything other does. Admins can do what moderators can.
+func (role Role) Includes(other Role) bool {
+	return role.rank() >= other.rank()
+}
+
 type User struct {
 	UserId      string `json:"userId" dynamodbav:"UserId"`
 	DisplayName string `json:"displayName" dynamodbav:"DisplayName"`
 	AvatarURL   string `json:"avatarUrl" dynamodbav:"AvatarURL"`
+	Role        Role   `json:"role" dynamodbav:"Role"`
+	// suspended users can still be looked at but cannot sign in or act.
+	Suspended       bool   `json:"suspended" dynamodbav:"Suspended"`
+	SuspendedReason string `json:"suspendedReason,omitempty" dynamodbav:"SuspendedReason"`
+	// profile fields the user edits.
+	Bio      string `json:"bio" dynamodbav:"Bio"`
+	Website  string `json:"website" dynamodbav:"Website"`
+	Location string `json:"location" dynamodbav:"Location"`
+	Language string `json:"language" dynamodbav:"Language"`
+	// keys of paper and pigments in the equipment catalog.
+	FavoriteEquipments []int32 `json:"favoriteEquipments" dynamodbav:"FavoriteEquipments"`
+	// DisplayName and AvatarURL come from the sign-in provider until the user changes them.
+	DisplayNameEdited bool `json:"-" dynamodbav:"DisplayNameEdited"`
+	AvatarURLEdited   bool `json:"-" dynamodbav:"AvatarURLEdited"`
+	// the provider's avatar, which AvatarURL falls back to when an uploaded avatar is removed.
+	ProviderAvatarURL string `json:"-" dynamodbav:"ProviderAvatarURL"`
+	// uploaded images by variant name. AvatarURL points at the medium avatar.
+	Avatars map[string]string `json:"avatars,omitempty" dynamodbav:"Avatars"`
+	Headers map[string]string `json:"headers,omitempty" dynamodbav:"Headers"`
+	// set while the account waits for deletion. Deletion is DeletionScheduled then, for the index.
+	Deletion string `json:"-" dynamodbav:"Deletion"`
+	DeleteAt uint64 `json:"deleteAt,omitempty" dynamodbav:"DeleteAt"`
+}
+
+// RefreshFromIdentity takes the provider's current name and avatar, except where the user has set
+// their own.
+func (user *User) RefreshFromIdentity(identity Identity) {
+	user.ProviderAvatarURL = identity.AvatarURL
+	if !user.DisplayNameEdited {
+		user.DisplayName = identity.DisplayName
+	}
+	if !user.AvatarURLEdited {
+		user.AvatarURL = identity.AvatarURL
+	}
+}
+
+// GetRole treats users created before roles existed as plain users.
+func (user User) GetRole() Role {
+	if user.Role == "" {
+		return RoleUser
+	}
+	return user.Role
+}
+
+// ProfileImageKind is an image users upload for their profile.
+type ProfileImageKind string
+
+const (
+	ProfileAvatar = ProfileImageKind("avatar")
+	ProfileHeader = ProfileImageKind("header")
+)
+
+type ImageVariant struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Variants lists the sizes an upload is scaled to. They share the aspect ratio of the first one.
+func (kind ProfileImageKind) Variants() []ImageVariant {
+	switch kind {
+	case ProfileAvatar:
+		return []ImageVariant{{"large", 400, 400}, {"medium", 128, 128}, {"small", 48, 48}}
+	case ProfileHeader:
+		return []ImageVariant{{"large", 1500, 500}, {"small", 600, 200}}
+	default:
+		return nil
+	}
 }
@@ -2,124 +2,227 @@ package session
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 	"log"
+	"net"
 	"net/http"
-	"sync"
+	"strings"
 	"time"
 
-	"github.com/hirosato/wcs/db"
+	"github.com/awslabs/aws-lambda-go-api-proxy/core"
+	"github.com/hirosato/wcs/domain"
 	"github.com/hirosato/wcs/env"
 	"github.com/hirosato/wcs/model"
 )
 
-var (
-	mu       sync.Mutex
-	sessions = make(map[string]map[string]interface{})
+const (
+	// a session that is not used for this long expires.
+	idleTimeout = 30 * 24 * time.Hour
+	// no session lives longer than this, however often it is used.
+	absoluteTimeout = 180 * 24 * time.Hour
+	// LastSeen and the sliding expiry are only written back this often, to keep reads cheap.
+	touchInterval = time.Hour
 )
 
-// Get returns the session data for the request client.
-func Get(r *http.Request) (s map[string]interface{}) {
-	if c, _ := r.Cookie("session"); c != nil && c.Value != "" {
-		mu.Lock()
-		s = sessions[c.Value]
-		mu.Unlock()
-	}
-	if s == nil {
-		s = make(map[string]interface{})
+// Manager keeps sessions in the store and their ids in the browser's cookie.
+type Manager struct {
+	store        domain.SessionStore
+	cookieDomain string
+	secure       bool
+}
+
+func NewManager(store domain.SessionStore, config env.Config) *Manager {
+	return &Manager{
+		store:        store,
+		cookieDomain: config.CookieDomain,
+		secure:       !config.IsLocal(),
 	}
-	return s
 }
 
-func GetSession(r *http.Request) (session model.Session) {
+func (m *Manager) GetSession(r *http.Request) (session model.Session) {
 	var err error
 	c, _ := r.Cookie("session")
 	// ブラウザからセッションは送られてきている
 	if c != nil && c.Value != "" {
-		session, err = db.GetSession(c.Value)
+		session, err = m.store.GetSession(c.Value)
 
 		// DBでは消されている。
 		if err != nil {
 			log.Printf("returning empty session object for %s since there is no data in db", c)
 			return model.Session{}
-		} else {
-			return session
 		}
+		now := time.Now()
+		// TTLの削除は遅れることがあるので、期限切れはここでも弾く。
+		if session.IsExpired(now.Unix()) {
+			log.Printf("returning empty session object for %s since it has expired", c)
+			m.store.DeleteSession(session.SessionId)
+			return model.Session{}
+		}
+		// 別のクライアントから持ち出されたセッションは使わせない。
+		if session.UserAgent != "" && session.UserAgent != r.UserAgent() {
+			log.Printf("returning empty session object for %s since the user agent does not match", c)
+			return model.Session{}
+		}
+		if now.Sub(time.Unix(session.LastSeen, 0)) > touchInterval {
+			touch(&session, now)
+			err := m.store.TouchSession(session)
+			// ログアウトなどで、読んだ後に消されている。
+			if errors.Is(err, domain.ErrConflict) {
+				log.Printf("returning empty session object for %s since it was deleted meanwhile", c)
+				return model.Session{}
+			}
+			if err != nil {
+				log.Printf("failed to touch session %s: %s", session.SessionId, err.Error())
+			}
+		}
+		return session
 	}
 	log.Printf("returning empty session object for %s. request is from host:%s request:%s", c, r.Host, r.RequestURI)
 	return model.Session{}
 }
 
-func SetSession(w http.ResponseWriter, r *http.Request, session model.Session) (err error) {
+func newKey() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+// CsrfToken returns the session's CSRF token, creating it on first use. It returns
+// domain.ErrConflict when the session was deleted since it was read, as by logging out.
+func (m *Manager) CsrfToken(session *model.Session) (string, error) {
+	if session.CsrfToken != "" {
+		return session.CsrfToken, nil
+	}
+	token, err := newKey()
+	if err != nil {
+		return "", err
+	}
+	// another request may have given the session a token meanwhile, which is kept.
+	token, err = m.store.SetSessionCsrfToken(session.SessionId, token)
+	if err != nil {
+		return "", err
+	}
+	session.CsrfToken = token
+	return token, nil
+}
+
+// VerifyCsrfToken compares the token sent with a request with the one stored on its session.
+func VerifyCsrfToken(session model.Session, token string) bool {
+	return session.CsrfToken != "" && subtle.ConstantTimeCompare([]byte(session.CsrfToken), []byte(token)) == 1
+}
+
+// touch slides the idle expiry forward, but never past the absolute one.
+func touch(session *model.Session, now time.Time) {
+	if session.Created == 0 {
+		session.Created = now.Unix()
+	}
+	session.LastSeen = now.Unix()
+	session.ExpiresAt = now.Add(idleTimeout).Unix()
+	if absolute := time.Unix(session.Created, 0).Add(absoluteTimeout).Unix(); absolute < session.ExpiresAt {
+		session.ExpiresAt = absolute
+	}
+}
+
+func (m *Manager) SetSession(w http.ResponseWriter, r *http.Request, session model.Session) (err error) {
 	key := ""
 	if c, _ := r.Cookie("session"); c != nil {
 		key = c.Value
 	}
+	now := time.Now()
 	if key == "" || session.SessionId != key {
-		var buf [16]byte
-		_, err = rand.Read(buf[:])
+		key, err = newKey()
 		if err != nil {
 			return err
 		}
-		key = hex.EncodeToString(buf[:])
 		session.SessionId = key
-		_, err = db.PutSession(session)
+		session.Created = now.Unix()
+		touch(&session, now)
+		_, err = m.store.PutSession(session)
 		if err != nil {
 			return err
 		}
-		domain := "watercolor.site"
-		if env.IsLocal {
-			domain = ""
-		}
 		http.SetCookie(w, &http.Cookie{
 			Name:     "session",
 			Path:     "/",
-			Domain:   domain,
-			Secure:   !env.IsLocal,
+			Domain:   m.cookieDomain,
+			Secure:   m.secure,
 			HttpOnly: true,
 			Value:    key,
-			Expires:  time.Unix(5000000000, 0),
+			Expires:  time.Unix(session.Created, 0).Add(absoluteTimeout),
 		})
 		return nil
 	}
-	_, err = db.PutSession(session)
+	touch(&session, now)
+	_, err = m.store.PutSession(session)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// Save saves session for the request client.
-func Save(w http.ResponseWriter, r *http.Request, s map[string]interface{}) error {
-	key := ""
-	if c, _ := r.Cookie("session"); c != nil {
-		key = c.Value
-	}
-	if len(s) == 0 {
-		if key != "" {
-			mu.Lock()
-			delete(sessions, key)
-			mu.Unlock()
+// RotateSession saves the session under a fresh id bound to the request client, and deletes the
+// session the browser came with. Call it whenever the session gets more privileges, such as on login.
+func (m *Manager) RotateSession(w http.ResponseWriter, r *http.Request, session model.Session) error {
+	if c, _ := r.Cookie("session"); c != nil && c.Value != "" {
+		if err := m.store.DeleteSession(c.Value); err != nil {
+			log.Printf("failed to delete the old session: %s", err.Error())
 		}
-		return nil
 	}
-	if key == "" {
-		var buf [16]byte
-		_, err := rand.Read(buf[:])
-		if err != nil {
+	session.SessionId = ""
+	session.UserAgent = r.UserAgent()
+	session.IpAddress = ClientIP(r)
+	return m.SetSession(w, r, session)
+}
+
+// ClientIP is the caller's address as API Gateway saw it. Without API Gateway it is the last
+// X-Forwarded-For entry, which the proxy in front appended, or else the connection's. The earlier
+// entries are whatever the client sent and are never used.
+func ClientIP(r *http.Request) string {
+	if gateway, ok := core.GetAPIGatewayContextFromContext(r.Context()); ok && gateway.Identity.SourceIP != "" {
+		return gateway.Identity.SourceIP
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		return strings.TrimSpace(hops[len(hops)-1])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// ClearSession deletes the session of the request client and tells the browser to drop its cookie.
+func (m *Manager) ClearSession(w http.ResponseWriter, r *http.Request) error {
+	var err error
+	if c, _ := r.Cookie("session"); c != nil && c.Value != "" {
+		err = m.store.DeleteSession(c.Value)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Path:     "/",
+		Domain:   m.cookieDomain,
+		Secure:   m.secure,
+		HttpOnly: true,
+		Value:    "",
+		MaxAge:   -1,
+	})
+	return err
+}
+
+// ClearUserSessions logs the user out everywhere by deleting every session of theirs.
+func (m *Manager) ClearUserSessions(userId string) error {
+	sessions, err := m.store.ListUserSessions(userId)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if err := m.store.DeleteSession(s.SessionId); err != nil {
 			return err
 		}
-		key = hex.EncodeToString(buf[:])
-		http.SetCookie(w, &http.Cookie{
-			Name:     "session",
-			Path:     "/",
-			Secure:   !env.IsLocal,
-			HttpOnly: true,
-			Value:    key,
-		})
 	}
-	mu.Lock()
-	sessions[key] = s
-	mu.Unlock()
 	return nil
 }
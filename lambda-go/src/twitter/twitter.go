@@ -2,133 +2,96 @@ package twitter
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 
 	"github.com/gomodule/oauth1/oauth"
-	"github.com/hirosato/wcs/env"
 	"github.com/hirosato/wcs/model"
-	"github.com/hirosato/wcs/session"
 )
 
-const (
-	tempCredKey  = "tempCred"
-	tokenCredKey = "tokenCred"
-)
-
-var oauthClient = oauth.Client{
-	TemporaryCredentialRequestURI: "https://api.twitter.com/oauth/request_token",
-	ResourceOwnerAuthorizationURI: "https://api.twitter.com/oauth/authorize",
-	TokenRequestURI:               "https://api.twitter.com/oauth/access_token",
+// Provider signs in with Twitter's OAuth 1.0a.
+type Provider struct {
+	client *oauth.Client
 }
 
-func init() {
-	oauthClient.Credentials.Token = "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXX"
-	oauthClient.Credentials.Secret = "XXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXXX"
-	oauthClient.ResourceOwnerAuthorizationURI = "https://api.twitter.com/oauth/authenticate"
+// NewProvider returns the provider for the app with the given consumer key and secret.
+func NewProvider(consumerKey string, consumerSecret string) Provider {
+	return Provider{client: &oauth.Client{
+		TemporaryCredentialRequestURI: "https://api.twitter.com/oauth/request_token",
+		ResourceOwnerAuthorizationURI: "https://api.twitter.com/oauth/authenticate",
+		TokenRequestURI:               "https://api.twitter.com/oauth/access_token",
+		Credentials: oauth.Credentials{
+			Token:  consumerKey,
+			Secret: consumerSecret,
+		},
+	}}
 }
 
-func getCallbackURL() string {
-	return env.GetApiUrl() + "/twitter/callback"
+func (Provider) Name() string {
+	return "twitter"
 }
 
-func ServeSignin(w http.ResponseWriter, r *http.Request) (alreadySignedIn bool, redirectUrl string, err error) {
-	callback := getCallbackURL()
-	s := session.GetSession(r)
-	if s.IsValid() {
-		return true, "", nil
-	}
-	tempCred, err := oauthClient.RequestTemporaryCredentials(nil, callback, nil)
+func (p Provider) Begin(s *model.Session, callbackURL string) (string, error) {
+	tempCred, err := p.client.RequestTemporaryCredentials(nil, callbackURL, nil)
 	if err != nil {
-		http.Error(w, "Error getting temp cred, "+err.Error(), http.StatusInternalServerError)
-		return false, "", err
+		return "", err
 	}
+	s.OAuthProvider = "twitter"
 	s.TempToken = tempCred.Token
 	s.TempSecret = tempCred.Secret
-	if err := session.SetSession(w, r, s); err != nil {
-		http.Error(w, "Error saving session , "+err.Error(), http.StatusInternalServerError)
-		return false, "", err
-	}
-	return false, oauthClient.AuthorizationURL(tempCred, nil), nil
+	return p.client.AuthorizationURL(tempCred, nil), nil
 }
 
-type Account struct {
-	ID              string `json:"id_str"`
-	ScreenName      string `json:"screen_name"`
-	ProfileImageURL string `json:"profile_image_url_https"`
-}
-
-func (account Account) AsUser() model.User {
-	return model.User{
-		UserId:      account.ID,
-		DisplayName: account.ScreenName,
-		AvatarURL:   account.ProfileImageURL,
-	}
-}
-
-func ServeOAuthCallback(w http.ResponseWriter, r *http.Request) {
-	s := session.GetSession(r)
+// Complete exchanges the temporary credentials of the pre-login session for the user's token and
+// reads the account with it. The token itself is not kept.
+func (p Provider) Complete(s *model.Session, r *http.Request, callbackURL string) (model.Identity, error) {
 	tempCred := oauth.Credentials{
 		Token:  s.TempToken,
 		Secret: s.TempSecret,
 	}
-	if tempCred.Token != r.FormValue("oauth_token") {
-		http.Error(w, "Unknown oauth_token.", http.StatusInternalServerError)
-		return
+	if s.OAuthProvider != "twitter" || tempCred.Token == "" || tempCred.Token != r.FormValue("oauth_token") {
+		return model.Identity{}, errors.New("unknown oauth_token")
 	}
-	tokenCred, _, err := oauthClient.RequestToken(nil, &tempCred, r.FormValue("oauth_verifier"))
+	tokenCred, _, err := p.client.RequestToken(nil, &tempCred, r.FormValue("oauth_verifier"))
 	if err != nil {
-		http.Error(w, "Error getting request token, "+err.Error(), http.StatusInternalServerError)
-		return
+		return model.Identity{}, err
 	}
-	err = session.SetSession(w, r, model.Session{
-		SessionId:  s.SessionId,
-		TempToken:  ``,
-		TempSecret: ``,
-		Token:      tokenCred.Token,
-		Secret:     tokenCred.Secret,
-	})
+	account, err := getAccount(p.client, tokenCred)
 	if err != nil {
-		http.Error(w, "Error saving session , "+err.Error(), http.StatusInternalServerError)
-		return
+		return model.Identity{}, err
 	}
+	return account.AsIdentity(), nil
 }
 
-func GetTwitterUserInfo(w http.ResponseWriter, r *http.Request) (*Account, error) {
-	var user *Account = &Account{}
-	s := session.GetSession(r)
+type Account struct {
+	ID              string `json:"id_str"`
+	ScreenName      string `json:"screen_name"`
+	ProfileImageURL string `json:"profile_image_url_https"`
+}
 
-	if !s.IsValid() {
-		return nil, nil
+func (account Account) AsIdentity() model.Identity {
+	return model.Identity{
+		Provider:    "twitter",
+		Subject:     account.ID,
+		DisplayName: account.ScreenName,
+		AvatarURL:   account.ProfileImageURL,
 	}
+}
 
-	resp, err := oauthClient.Get(nil, s.AsOauthCredentials(), "https://api.twitter.com/1.1/account/verify_credentials.json", url.Values{})
+func getAccount(client *oauth.Client, cred *oauth.Credentials) (*Account, error) {
+	resp, err := client.Get(nil, cred, "https://api.twitter.com/1.1/account/verify_credentials.json", url.Values{})
 	if err != nil {
 		return nil, err
 	}
-
-	if resp.StatusCode >= 500 {
-		return nil, err //errors.New("Twitter is unavailable")
-	}
-
+	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
-		return nil, err //errors.New("Twitter request is invalid")
-	}
-
-	err = json.NewDecoder(resp.Body).Decode(user)
-	if err != nil {
-		return user, err
+		return nil, fmt.Errorf("twitter returned %s", resp.Status)
 	}
-
-	return user, nil
-}
-
-func ServeLogout(w http.ResponseWriter, r *http.Request) {
-	s := session.Get(r)
-	delete(s, tokenCredKey)
-	if err := session.Save(w, r, s); err != nil {
-		http.Error(w, "Error saving session , "+err.Error(), http.StatusInternalServerError)
-		return
+	var account Account
+	if err := json.NewDecoder(resp.Body).Decode(&account); err != nil {
+		return nil, err
 	}
-	http.Redirect(w, r, "/", http.StatusFound)
+	return &account, nil
 }
@@ -1,7 +1,11 @@
 package util
 
 import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"math/big"
 	"time"
 )
 
@@ -13,3 +17,54 @@ func GetDateAndTimestamp() (string, string) {
 	millisec := int64(t.Nanosecond()) / int64(time.Millisecond)
 	return t.Format(dateformat), t.Format(datetimeformat) + fmt.Sprintf("%03d", millisec)
 }
+
+func GetUnixMilli() uint64 {
+	return uint64(time.Now().UnixNano() / int64(time.Millisecond))
+}
+
+// TimestampToUnixMilli converts a timestamp made by GetDateAndTimestamp back to unix milliseconds.
+func TimestampToUnixMilli(timestamp string) (uint64, error) {
+	if len(timestamp) < len(datetimeformat) {
+		return 0, fmt.Errorf("malformed timestamp: %s", timestamp)
+	}
+	t, err := time.Parse(datetimeformat, timestamp[:len(datetimeformat)])
+	if err != nil {
+		return 0, err
+	}
+	var millisec uint64
+	if _, err := fmt.Sscanf(timestamp[len(datetimeformat):], "%03d", &millisec); err != nil {
+		return 0, err
+	}
+	return uint64(t.Unix())*1000 + millisec, nil
+}
+
+// NewId returns a random id for records that have no natural key.
+func NewId() string {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// crockford is the base32 alphabet of ULIDs. Its characters sort in the order of their values.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewSortableId returns a ULID made at unixMilli: 26 characters that sort by time, and randomly
+// among ids of the same millisecond.
+func NewSortableId(unixMilli uint64) string {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], unixMilli<<16)
+	if _, err := rand.Read(buf[6:]); err != nil {
+		panic(err)
+	}
+	// 26 characters of 5 bits hold the 128 bits with 2 to spare, so the first is at most 7.
+	n := new(big.Int).SetBytes(buf[:])
+	mask := big.NewInt(31)
+	id := make([]byte, 26)
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i] = crockford[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 5)
+	}
+	return string(id)
+}
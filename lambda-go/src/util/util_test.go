@@ -0,0 +1,20 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewSortableId(t *testing.T) {
+	// the time part is checked against the reference implementation.
+	id := NewSortableId(1469918176385)
+	if len(id) != 26 || !strings.HasPrefix(id, "01ARYZ6S41") {
+		t.Fatalf("unexpected id %s", id)
+	}
+	if other := NewSortableId(1469918176385); other == id {
+		t.Fatalf("ids of the same millisecond collided: %s", id)
+	}
+	if later := NewSortableId(1469918176386); later <= id {
+		t.Fatalf("%s does not sort after %s", later, id)
+	}
+}